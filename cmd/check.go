@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/knadh/koanf/v2"
+)
+
+const selfCheckTimeout = 30 * time.Second
+
+var requiredConfigKeys = []string{
+	"api.address",
+	"api.public_key",
+	"postgres.federation_dsn",
+	"chain.id",
+	"chain.rpc_endpoint",
+	"chain.balances_scanner",
+}
+
+// featureQueries are the queries only run by an optional feature, keyed by
+// query name with the config keys that must all be set for the feature to
+// use it. Their tables may not exist when the feature is off, so the self
+// check skips them.
+var featureQueries = map[string][]string{
+	"revoked-tokens": {"denylist.enable", "denylist.postgres"},
+}
+
+type checkResult struct {
+	name string
+	err  error
+}
+
+// checkCmd runs the self check against the loaded config and exits non-zero
+// if anything is misconfigured.
+func checkCmd() int {
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+
+	if !runSelfCheck(ctx, ko, os.Stdout) {
+		return 1
+	}
+	return 0
+}
+
+// runSelfCheck validates the config, public key and queries file, prepares
+// every query of an enabled feature against Postgres and verifies the RPC
+// node, then writes a report to w. It returns false if any check failed.
+func runSelfCheck(ctx context.Context, k *koanf.Koanf, w io.Writer) bool {
+	var results []checkResult
+
+	configErr := checkConfigKeys(k)
+	results = append(results, checkResult{name: "config", err: configErr})
+	if configErr != nil {
+		// Everything below needs a complete config.
+		return printCheckReport(w, results)
+	}
+
+	_, err := util.LoadSigningKey(k.String("api.public_key"))
+	results = append(results, checkResult{name: "public key", err: err})
+
+	pgQueries, err := loadQueries(queriesFlag)
+	results = append(results, checkResult{name: "queries file", err: err})
+
+	if pgQueries != nil {
+		results = append(results, checkQueries(ctx, k, pgQueries)...)
+	}
+
+	results = append(results, checkChain(ctx, k)...)
+
+	return printCheckReport(w, results)
+}
+
+func checkConfigKeys(k *koanf.Koanf) error {
+	var errs []error
+
+	for _, key := range requiredConfigKeys {
+		if !k.Exists(key) || k.String(key) == "" {
+			errs = append(errs, fmt.Errorf("missing %s", key))
+		}
+	}
+
+	if k.Bool("api.tls.enable") && (k.String("api.tls.cert_file") == "" || k.String("api.tls.key_file") == "") {
+		errs = append(errs, errors.New("api.tls.cert_file and api.tls.key_file are required when TLS is enabled"))
+	}
+
+	if k.Bool("tracing.enable") && !slices.Contains([]string{util.TracingExporterOTLP, util.TracingExporterFile}, k.String("tracing.exporter")) {
+		errs = append(errs, fmt.Errorf("unknown tracing.exporter %q", k.String("tracing.exporter")))
+	}
+
+	return errors.Join(errs...)
+}
+
+func checkQueries(ctx context.Context, k *koanf.Koanf, pgQueries *data.PgQueries) []checkResult {
+	pgChainDataStore, err := newPgChainDataStore(k, pgQueries)
+	if err != nil {
+		return []checkResult{{name: "postgres", err: err}}
+	}
+	defer pgChainDataStore.Close()
+
+	if err := pgChainDataStore.Ping(ctx); err != nil {
+		return []checkResult{{name: "postgres", err: err}}
+	}
+
+	failed, err := pgChainDataStore.PrepareQueries(ctx, disabledFeatureQueries(k))
+	if err != nil {
+		return []checkResult{{name: "postgres", err: err}}
+	}
+
+	results := []checkResult{{name: "postgres"}}
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		results = append(results, checkResult{name: "query " + name, err: failed[name]})
+	}
+	if len(failed) == 0 {
+		results = append(results, checkResult{name: "queries prepared"})
+	}
+
+	return results
+}
+
+// disabledFeatureQueries returns the feature queries whose feature is off.
+func disabledFeatureQueries(k *koanf.Koanf) []string {
+	var disabled []string
+	for name, keys := range featureQueries {
+		if slices.ContainsFunc(keys, func(key string) bool { return !k.Bool(key) }) {
+			disabled = append(disabled, name)
+		}
+	}
+	slices.Sort(disabled)
+	return disabled
+}
+
+func checkChain(ctx context.Context, k *koanf.Koanf) []checkResult {
+	chainData := newChainProvider(k)
+
+	chainIDResult := checkResult{name: "rpc chain id"}
+	chainID, err := chainData.ChainID(ctx)
+	if err != nil {
+		chainIDResult.err = err
+	} else if expected := k.Int64("chain.id"); chainID != uint64(expected) {
+		chainIDResult.err = fmt.Errorf("rpc reports chain id %d, config has %d", chainID, expected)
+	}
+
+	scannerResult := checkResult{name: "balance scanner contract"}
+	code, err := chainData.ContractCode(ctx, k.String("chain.balances_scanner"))
+	if err != nil {
+		scannerResult.err = err
+	} else if len(code) == 0 {
+		scannerResult.err = fmt.Errorf("no contract code at %s", k.String("chain.balances_scanner"))
+	}
+
+	return []checkResult{chainIDResult, scannerResult}
+}
+
+func printCheckReport(w io.Writer, results []checkResult) bool {
+	ok := true
+	for _, result := range results {
+		if result.err != nil {
+			ok = false
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", result.name, result.err)
+		} else {
+			fmt.Fprintf(w, "[ OK ] %s\n", result.name)
+		}
+	}
+
+	if ok {
+		fmt.Fprintln(w, "self check passed")
+	} else {
+		fmt.Fprintln(w, "self check failed")
+	}
+
+	return ok
+}
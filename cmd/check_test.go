@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/v2"
+)
+
+func newTestConfig(t *testing.T, values map[string]any) *koanf.Koanf {
+	t.Helper()
+
+	k := koanf.New(".")
+	for key, value := range values {
+		if err := k.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return k
+}
+
+func validTestConfig() map[string]any {
+	return map[string]any{
+		"api.address":             ":5006",
+		"api.public_key":          "key",
+		"postgres.federation_dsn": "postgres://localhost/federation",
+		"chain.id":                1337,
+		"chain.rpc_endpoint":      "http://localhost:8545",
+		"chain.balances_scanner":  "0xF62107c53a5b18646E823a21ed531ED934B1CE9E",
+	}
+}
+
+func TestCheckConfigKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  map[string]any
+		remove  string
+		wantErr []string
+	}{
+		{name: "valid"},
+		{name: "missing key", remove: "chain.rpc_endpoint", wantErr: []string{"missing chain.rpc_endpoint"}},
+		{name: "empty key", change: map[string]any{"api.public_key": ""}, wantErr: []string{"missing api.public_key"}},
+		{name: "tls without files", change: map[string]any{"api.tls.enable": true}, wantErr: []string{"api.tls.cert_file"}},
+		{name: "tls with files", change: map[string]any{"api.tls.enable": true, "api.tls.cert_file": "cert.pem", "api.tls.key_file": "key.pem"}},
+		{name: "unknown tracing exporter", change: map[string]any{"tracing.enable": true, "tracing.exporter": "jaeger"}, wantErr: []string{`unknown tracing.exporter "jaeger"`}},
+		{name: "tracing exporter ignored when disabled", change: map[string]any{"tracing.exporter": "jaeger"}},
+		{
+			name:    "every error reported",
+			remove:  "api.address",
+			change:  map[string]any{"tracing.enable": true, "tracing.exporter": "jaeger"},
+			wantErr: []string{"missing api.address", "unknown tracing.exporter"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := validTestConfig()
+			delete(values, tt.remove)
+			for key, value := range tt.change {
+				values[key] = value
+			}
+
+			err := checkConfigKeys(newTestConfig(t, values))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("checkConfigKeys() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkConfigKeys() = nil, want %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("checkConfigKeys() = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintCheckReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []checkResult
+		want    string
+		wantOk  bool
+	}{
+		{
+			name:    "passed",
+			results: []checkResult{{name: "config"}, {name: "postgres"}},
+			want:    "[ OK ] config\n[ OK ] postgres\nself check passed\n",
+			wantOk:  true,
+		},
+		{
+			name:    "failed",
+			results: []checkResult{{name: "config"}, {name: "query pool-details", err: errors.New("relation does not exist")}},
+			want:    "[ OK ] config\n[FAIL] query pool-details: relation does not exist\nself check failed\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if ok := printCheckReport(&b, tt.results); ok != tt.wantOk {
+				t.Errorf("printCheckReport() = %v, want %v", ok, tt.wantOk)
+			}
+			if b.String() != tt.want {
+				t.Errorf("report = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDisabledFeatureQueries(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   []string
+	}{
+		{name: "denylist disabled", want: []string{"revoked-tokens"}},
+		{name: "file denylist", values: map[string]any{"denylist.enable": true}, want: []string{"revoked-tokens"}},
+		{name: "postgres denylist", values: map[string]any{"denylist.enable": true, "denylist.postgres": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := disabledFeatureQueries(newTestConfig(t, tt.values)); !slices.Equal(got, tt.want) {
+				t.Errorf("disabledFeatureQueries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ussd-data-service/internal/api"
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	model "github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+const cliTimeout = 30 * time.Second
+
+type (
+	// cliEnv is what every operator subcommand gets: the same data sources
+	// the API server uses, built from the same config.
+	cliEnv struct {
+		pg    *data.PgChainData
+		chain *data.Chain
+		out   io.Writer
+		json  bool
+	}
+
+	cliCommand struct {
+		usage string
+		nArgs int
+		run   func(ctx context.Context, env *cliEnv, args []string) error
+	}
+)
+
+var cliCommands = map[string]cliCommand{
+	"holdings": {usage: "holdings <address>", nArgs: 1, run: holdingsCmd},
+	"history":  {usage: "history <address>", nArgs: 1, run: historyCmd},
+	"limit":    {usage: "limit <pool> <from token> <to token> <address>", nArgs: 4, run: limitCmd},
+	"quote":    {usage: "quote <pool> <from token> <to token> <output amount>", nArgs: 4, run: quoteCmd},
+	"pool":     {usage: "pool <address|symbol>", nArgs: 1, run: poolCmd},
+}
+
+// cliCmd runs an operator subcommand directly against Postgres and the RPC
+// node, bypassing the HTTP API.
+func cliCmd(name string, args []string) int {
+	cmd := cliCommands[name]
+
+	jsonOut, cmdArgs, err := parseCLIArgs(name, args, os.Stderr)
+	if err != nil {
+		return 2
+	}
+
+	pgQueries, err := loadQueries(queriesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load queries: %v\n", err)
+		return 1
+	}
+
+	pgChainDataStore, err := newPgChainDataStore(ko, pgQueries)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not initialize postgres store: %v\n", err)
+		return 1
+	}
+	defer pgChainDataStore.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	env := &cliEnv{
+		pg:    pgChainDataStore,
+		chain: newChainProvider(ko),
+		out:   os.Stdout,
+		json:  jsonOut,
+	}
+	if err := cmd.run(ctx, env, cmdArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+
+	return 0
+}
+
+// parseCLIArgs parses a subcommand's flags and checks its argument count,
+// writing the usage to output when they are wrong.
+func parseCLIArgs(name string, args []string, output io.Writer) (bool, []string, error) {
+	cmd := cliCommands[name]
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	jsonFlag := fs.Bool("json", false, "Output JSON instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [-json] %s\n", name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return false, nil, err
+	}
+	if fs.NArg() != cmd.nArgs {
+		fs.Usage()
+		return false, nil, fmt.Errorf("%s takes %d arguments, got %d", name, cmd.nArgs, fs.NArg())
+	}
+
+	return *jsonFlag, fs.Args(), nil
+}
+
+func holdingsCmd(ctx context.Context, env *cliEnv, args []string) error {
+	address, err := normalizeAddress(args[0])
+	if err != nil {
+		return err
+	}
+
+	tokenHoldings, err := env.pg.TokenHoldings(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	holdings, err := env.chain.MergeTokenBalances(ctx, tokenHoldings, address)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.writeJSON(holdings)
+	}
+
+	rows := make([][]any, 0, len(holdings))
+	for _, h := range holdings {
+		rows = append(rows, []any{h.TokenSymbol, h.TokenAddress, h.TokenDecimals, h.Balance})
+	}
+	return env.writeTable([]string{"SYMBOL", "TOKEN", "DECIMALS", "BALANCE"}, rows)
+}
+
+func historyCmd(ctx context.Context, env *cliEnv, args []string) error {
+	address, err := normalizeAddress(args[0])
+	if err != nil {
+		return err
+	}
+
+	transfers, err := env.pg.Last10Tx(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.writeJSON(transfers)
+	}
+
+	rows := make([][]any, 0, len(transfers))
+	for _, tx := range transfers {
+		rows = append(rows, []any{
+			tx.DateBlock.Format(time.RFC3339),
+			tx.TokenSymbol,
+			tx.Sender,
+			tx.Recipient,
+			tx.TransferValue,
+			tx.Success,
+			tx.TxHash,
+		})
+	}
+	return env.writeTable([]string{"DATE", "SYMBOL", "SENDER", "RECIPIENT", "VALUE", "SUCCESS", "TX HASH"}, rows)
+}
+
+func limitCmd(ctx context.Context, env *cliEnv, args []string) error {
+	addresses, err := normalizeAddresses(args...)
+	if err != nil {
+		return err
+	}
+
+	maxSwapInput, err := api.SwapLimit(ctx, lo, env.pg, env.chain, api.PoolLimits{
+		PoolAddress: addresses[0],
+		FromToken:   addresses[1],
+		ToToken:     addresses[2],
+		UserAddress: addresses[3],
+	})
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.writeJSON(map[string]any{
+			"max": maxSwapInput.String(),
+		})
+	}
+
+	return env.writeTable([]string{"POOL", "FROM", "TO", "ADDRESS", "MAX"}, [][]any{
+		{addresses[0], addresses[1], addresses[2], addresses[3], maxSwapInput.String()},
+	})
+}
+
+func quoteCmd(ctx context.Context, env *cliEnv, args []string) error {
+	addresses, err := normalizeAddresses(args[:3]...)
+	if err != nil {
+		return err
+	}
+
+	outputAmount := new(big.Int)
+	if _, ok := outputAmount.SetString(args[3], 10); !ok {
+		return fmt.Errorf("invalid amount %q", args[3])
+	}
+
+	inputAmount, err := api.ReverseQuote(ctx, lo, env.pg, api.ReverseQuoteParams{
+		PoolAddress: addresses[0],
+		FromToken:   addresses[1],
+		ToToken:     addresses[2],
+		Amount:      outputAmount.String(),
+	}, outputAmount)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.writeJSON(map[string]any{
+			"inputAmount":  inputAmount.String(),
+			"outputAmount": outputAmount.String(),
+		})
+	}
+
+	return env.writeTable([]string{"POOL", "FROM", "TO", "INPUT", "OUTPUT"}, [][]any{
+		{addresses[0], addresses[1], addresses[2], inputAmount.String(), outputAmount.String()},
+	})
+}
+
+// poolCmd accepts either a pool address or a pool symbol, like the two pool
+// detail endpoints.
+func poolCmd(ctx context.Context, env *cliEnv, args []string) error {
+	var (
+		poolDetails *model.PoolDetails
+		err         error
+	)
+
+	if common.IsHexAddress(args[0]) {
+		address := common.HexToAddress(args[0]).Hex()
+		poolDetails, err = env.pg.PoolDetails(ctx, address)
+		if err == nil && poolDetails == nil {
+			poolDetails, err = env.chain.PoolDetails(ctx, address)
+		}
+	} else {
+		poolDetails, err = env.pg.PoolReverseDetails(ctx, args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if poolDetails == nil {
+		return errors.New("pool not found")
+	}
+
+	allowedTokens, err := env.pg.PoolAllowedTokens(ctx, poolDetails.PoolContractAdrress)
+	if err != nil {
+		return err
+	}
+
+	if env.json {
+		return env.writeJSON(map[string]any{
+			"poolDetails":   poolDetails,
+			"allowedTokens": allowedTokens,
+		})
+	}
+
+	if err := env.writeTable([]string{"NAME", "SYMBOL", "POOL", "LIMITER", "REGISTRY"}, [][]any{
+		{poolDetails.PoolName, poolDetails.PoolSymbol, poolDetails.PoolContractAdrress, poolDetails.LimiterAddress, poolDetails.VoucherRegistry},
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(env.out)
+	rows := make([][]any, 0, len(allowedTokens))
+	for _, t := range allowedTokens {
+		rows = append(rows, []any{t.TokenSymbol, t.TokenAddress, t.TokenDecimals})
+	}
+	return env.writeTable([]string{"SYMBOL", "TOKEN", "DECIMALS"}, rows)
+}
+
+// normalizeAddress accepts an address in any letter case and returns its
+// checksummed form, which is what the queries and the API expect.
+func normalizeAddress(address string) (string, error) {
+	if !common.IsHexAddress(address) {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	return common.HexToAddress(address).Hex(), nil
+}
+
+func normalizeAddresses(addresses ...string) ([]string, error) {
+	normalized := make([]string, len(addresses))
+	for i, address := range addresses {
+		var err error
+		if normalized[i], err = normalizeAddress(address); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
+
+func (env *cliEnv) writeJSON(v any) error {
+	enc := json.NewEncoder(env.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (env *cliEnv) writeTable(header []string, rows [][]any) error {
+	tw := tabwriter.NewWriter(env.out, 0, 0, 2, ' ', 0)
+	for i, h := range header {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, h)
+	}
+	fmt.Fprintln(tw)
+
+	for _, row := range rows {
+		for i, col := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, col)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
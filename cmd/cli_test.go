@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+const (
+	testPool  = "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439"
+	testToken = "0xF62107c53a5b18646E823a21ed531ED934B1CE9E"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{address: testPool, want: testPool},
+		{address: strings.ToLower(testPool), want: testPool},
+		{address: "0x" + strings.ToUpper(testPool[2:]), want: testPool},
+		{address: testPool[:40], wantErr: true},
+		{address: "kbr", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got, err := normalizeAddress(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeAddress() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCLIArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      string
+		args     []string
+		wantJSON bool
+		wantArgs []string
+		wantErr  bool
+	}{
+		{name: "table", cmd: "history", args: []string{testPool}, wantArgs: []string{testPool}},
+		{name: "json", cmd: "history", args: []string{"-json", testPool}, wantJSON: true, wantArgs: []string{testPool}},
+		{name: "flag after argument", cmd: "history", args: []string{testPool, "-json"}, wantErr: true},
+		{name: "missing argument", cmd: "history", wantErr: true},
+		{name: "too many arguments", cmd: "pool", args: []string{"KBR", "KBR"}, wantErr: true},
+		{name: "four arguments", cmd: "quote", args: []string{testPool, testToken, testToken, "100"}, wantArgs: []string{testPool, testToken, testToken, "100"}},
+		{name: "unknown flag", cmd: "holdings", args: []string{"-csv", testPool}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var usage bytes.Buffer
+			gotJSON, gotArgs, err := parseCLIArgs(tt.cmd, tt.args, &usage)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCLIArgs() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(usage.String(), "usage: "+tt.cmd) {
+					t.Errorf("usage = %q, want the %s usage", usage.String(), tt.cmd)
+				}
+				return
+			}
+			if gotJSON != tt.wantJSON || !slices.Equal(gotArgs, tt.wantArgs) {
+				t.Errorf("parseCLIArgs() = %t, %q, want %t, %q", gotJSON, gotArgs, tt.wantJSON, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCLICommands(t *testing.T) {
+	lo = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	fake, pg := pgtest.NewServer(t, "../queries.sql")
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	fake.Set("last-10-tx", api.Last10TxResponse{}, &api.Last10TxResponse{
+		Sender: testPool, Recipient: testToken, TransferValue: "1000000", ContractAddress: testToken,
+		TxHash: "0x01", DateBlock: day, TokenSymbol: "SRF", TokenDecimals: "6", Success: true,
+	})
+	pool := &api.PoolDetails{PoolName: "Kibera Pool", PoolSymbol: "KBR", PoolContractAdrress: testPool, LimiterAddress: testToken, VoucherRegistry: testToken}
+	fake.Set("pool-details", api.PoolDetails{}, pool)
+	fake.Set("pool-reverse-details", api.PoolDetails{}, pool)
+	fake.Set("pool-allowed-tokens", api.TokenHoldings{}, &api.TokenHoldings{TokenAddress: testToken, TokenSymbol: "SRF", TokenDecimals: "6"})
+	fake.Set("pool-token-swap-rates", api.TokenSwapRates{}, &api.TokenSwapRates{InRate: 10_000, OutRate: 10_000, InDecimals: 6, OutDecimals: 6})
+
+	tests := []struct {
+		name    string
+		cmd     string
+		args    []string
+		json    bool
+		want    string
+		wantErr string
+	}{
+		{
+			name: "history table",
+			cmd:  "history",
+			args: []string{strings.ToLower(testPool)},
+			want: "DATE                  SYMBOL  SENDER                                      RECIPIENT                                   VALUE    SUCCESS  TX HASH\n" +
+				"2025-03-01T12:00:00Z  SRF     " + testPool + "  " + testToken + "  1000000  true     0x01\n",
+		},
+		{
+			name: "history json",
+			cmd:  "history",
+			args: []string{testPool},
+			json: true,
+			want: `[
+  {
+    "sender": "` + testPool + `",
+    "recipient": "` + testToken + `",
+    "transferValue": "1000000",
+    "contractAddress": "` + testToken + `",
+    "txHash": "0x01",
+    "dateBlock": "2025-03-01T12:00:00Z",
+    "tokenSymbol": "SRF",
+    "tokenDecimals": "6",
+    "success": true
+  }
+]
+`,
+		},
+		{name: "history invalid address", cmd: "history", args: []string{"0x01"}, wantErr: `invalid address "0x01"`},
+		{
+			name: "pool by symbol table",
+			cmd:  "pool",
+			args: []string{"KBR"},
+			want: "NAME         SYMBOL  POOL                                        LIMITER                                     REGISTRY\n" +
+				"Kibera Pool  KBR     " + testPool + "  " + testToken + "  " + testToken + "\n\n" +
+				"SYMBOL  TOKEN                                       DECIMALS\n" +
+				"SRF     " + testToken + "  6\n",
+		},
+		{
+			name: "quote table",
+			cmd:  "quote",
+			args: []string{testPool, testToken, testToken, "100"},
+			want: "POOL                                        FROM                                        TO                                          INPUT  OUTPUT\n" +
+				testPool + "  " + testToken + "  " + testToken + "  100    100\n",
+		},
+		{
+			name: "quote json",
+			cmd:  "quote",
+			args: []string{testPool, testToken, testToken, "100"},
+			json: true,
+			want: "{\n  \"inputAmount\": \"100\",\n  \"outputAmount\": \"100\"\n}\n",
+		},
+		{name: "quote invalid amount", cmd: "quote", args: []string{testPool, testToken, testToken, "1e6"}, wantErr: `invalid amount "1e6"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			env := &cliEnv{pg: pg, out: &out, json: tt.json}
+
+			err := cliCommands[tt.cmd].run(context.Background(), env, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -28,10 +28,14 @@ var (
 	queriesFlag string
 
 	lo *slog.Logger
+	// ko is the startup config. It is never written after init, a reload
+	// applies the new config to the running components instead.
 	ko *koanf.Koanf
 )
 
-func init() {
+// bootstrap parses the flags and loads the logger and config. It runs from
+// main rather than init so the package can be tested.
+func bootstrap() {
 	flag.StringVar(&confFlag, "config", "config.toml", "Config file location")
 	flag.StringVar(&queriesFlag, "queries", "queries.sql", "Queries file location")
 	flag.Parse()
@@ -39,35 +43,126 @@ func init() {
 	lo = util.InitLogger()
 	ko = util.InitConfig(lo, confFlag)
 
-	lo.Info("starting ussd data service", "build", build)
+	if level := ko.String("log.level"); level != "" {
+		if err := util.SetLogLevel(level); err != nil {
+			lo.Error("could not set log level", "error", err)
+			os.Exit(1)
+		}
+	}
 }
 
 func main() {
+	bootstrap()
+
+	switch flag.Arg(0) {
+	case "":
+	case "check":
+		os.Exit(checkCmd())
+	default:
+		if _, ok := cliCommands[flag.Arg(0)]; ok {
+			os.Exit(cliCmd(flag.Arg(0), flag.Args()[1:]))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	lo.Info("starting ussd data service", "build", build)
+
 	var wg sync.WaitGroup
 	ctx, stop := notifyShutdown()
 
+	if ko.Bool("startup.self_check") {
+		checkCtx, cancel := context.WithTimeout(ctx, selfCheckTimeout)
+		ok := runSelfCheck(checkCtx, ko, os.Stderr)
+		cancel()
+		if !ok {
+			lo.Error("startup self check failed")
+			os.Exit(1)
+		}
+	}
+
+	shutdownTracer := func(context.Context) error { return nil }
+	if ko.Bool("tracing.enable") {
+		var err error
+		shutdownTracer, err = util.InitTracer(util.TracingOpts{
+			Build:        build,
+			Exporter:     ko.MustString("tracing.exporter"),
+			OTLPEndpoint: ko.String("tracing.otlp_endpoint"),
+			OTLPInsecure: ko.Bool("tracing.otlp_insecure"),
+			File:         ko.String("tracing.file"),
+			SampleRatio:  ko.Float64("tracing.sample_ratio"),
+		})
+		if err != nil {
+			lo.Error("could not initialize tracing", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	pgQueries, err := loadQueries(queriesFlag)
 	if err != nil {
 		lo.Error("could not load queries", "error", err)
 		os.Exit(1)
 	}
 
-	pgChainDataStore, err := data.NewPgChainDataSource(data.PgChainDataOpts{
-		Logg:    lo,
-		DSN:     ko.MustString("postgres.federation_dsn"),
-		Queries: pgQueries,
-	})
+	pgChainDataStore, err := newPgChainDataStore(ko, pgQueries)
 	if err != nil {
 		lo.Error("could not initialize postgres store", "error", err)
 		os.Exit(1)
 	}
 
-	chainData := data.NewChainProvider(data.ChainOpts{
-		ChainID:         ko.MustInt64("chain.id"),
-		RPCEndpoint:     ko.MustString("chain.rpc_endpoint"),
-		BalancesScanner: ko.MustString("chain.balances_scanner"),
-		Logg:            lo,
-	})
+	chainData := newChainProvider(ko)
+
+	var denylist *data.Denylist
+	if ko.Bool("denylist.enable") {
+		denylistOpts := data.DenylistOpts{
+			Logg:            lo,
+			File:            ko.String("denylist.file"),
+			RefreshInterval: ko.Duration("denylist.refresh_interval"),
+		}
+		if ko.Bool("denylist.postgres") {
+			denylistOpts.PgDataSource = pgChainDataStore
+		}
+
+		denylist = data.NewDenylist(denylistOpts)
+		if err := denylist.Refresh(ctx); err != nil {
+			lo.Error("could not load token denylist", "error", err)
+			os.Exit(1)
+		}
+		go denylist.Start(ctx)
+	}
+
+	var indexerMonitor *data.IndexerMonitor
+	if ko.Bool("indexer.monitor") {
+		indexerMonitor = data.NewIndexerMonitor(data.IndexerMonitorOpts{
+			Logg:            lo,
+			PgDataSource:    pgChainDataStore,
+			ChainDataSource: chainData,
+			Interval:        ko.Duration("indexer.interval"),
+			MaxLagBlocks:    uint64(ko.Int64("indexer.max_lag_blocks")),
+		})
+		go indexerMonitor.Start(ctx)
+	}
+
+	var registryCache *data.RegistryCache
+	if ko.Bool("registry_cache.enable") {
+		registryCache = data.NewRegistryCache(data.RegistryCacheOpts{
+			Logg:               lo,
+			PgDataSource:       pgChainDataStore,
+			ChainDataSource:    chainData,
+			Registries:         ko.Strings("registry_cache.registries"),
+			DiscoverRegistries: ko.Bool("registry_cache.discover"),
+			File:               ko.String("registry_cache.file"),
+			RefreshInterval:    ko.Duration("registry_cache.refresh_interval"),
+			BatchSize:          ko.Int("registry_cache.batch_size"),
+		})
+		// A stale or unreadable cache file only delays serving until the
+		// first refresh completes.
+		if err := registryCache.Load(); err != nil {
+			lo.Error("could not load registry cache file", "error", err)
+		}
+		go registryCache.Start(ctx)
+	}
 
 	publicKey, err := util.LoadSigningKey(ko.MustString("api.public_key"))
 	if err != nil {
@@ -75,14 +170,38 @@ func main() {
 		os.Exit(1)
 	}
 
-	apiServer := api.New(api.APIOpts{
+	apiServer, err := api.New(api.APIOpts{
 		VerifyingKey:    publicKey,
 		EnableMetrics:   ko.Bool("metrics.enable"),
 		ListenAddress:   ko.MustString("api.address"),
 		PgDataSource:    pgChainDataStore,
 		ChainDataSource: chainData,
+		Denylist:        denylist,
+		IndexerMonitor:  indexerMonitor,
+		RegistryCache:   registryCache,
+		MaxHeadAge:      ko.Duration("health.max_head_age"),
+		RequestTimeout:  ko.Duration("api.timeouts.default"),
+		RouteTimeouts:   routeTimeouts(ko),
 		Logg:            lo,
+
+		ReadTimeout:       ko.Duration("api.read_timeout"),
+		ReadHeaderTimeout: ko.Duration("api.read_header_timeout"),
+		WriteTimeout:      ko.Duration("api.write_timeout"),
+		IdleTimeout:       ko.Duration("api.idle_timeout"),
+		MaxHeaderBytes:    ko.Int("api.max_header_bytes"),
+
+		EnableTLS:       ko.Bool("api.tls.enable"),
+		TLSCertFile:     ko.String("api.tls.cert_file"),
+		TLSKeyFile:      ko.String("api.tls.key_file"),
+		TLSClientCAFile: ko.String("api.tls.client_ca_file"),
+		TLSClientNames:  ko.Strings("api.tls.client_names"),
 	})
+	if err != nil {
+		lo.Error("could not initialize API server", "error", err)
+		os.Exit(1)
+	}
+
+	go handleReload(ctx, apiServer, pgChainDataStore)
 
 	wg.Add(1)
 	go func() {
@@ -103,6 +222,11 @@ func main() {
 		if err := apiServer.Stop(shutdownCtx); err != nil {
 			lo.Error("failed to stop HTTP server", "err", fmt.Sprintf("%T", err))
 		}
+		pgChainDataStore.Close()
+		// Flush after the server has drained so in-flight request spans are exported.
+		if err := shutdownTracer(shutdownCtx); err != nil {
+			lo.Error("failed to flush traces", "error", err)
+		}
 	}()
 
 	go func() {
@@ -125,6 +249,104 @@ func notifyShutdown() (context.Context, context.CancelFunc) {
 	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 }
 
+// handleReload re-reads the config and queries on SIGHUP. Everything is
+// validated before anything is applied so a failed reload keeps the old state.
+func handleReload(ctx context.Context, apiServer *api.API, pgChainDataStore *data.PgChainData) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			lo.Info("reload signal received")
+			if err := reload(apiServer, pgChainDataStore); err != nil {
+				lo.Error("reload failed, keeping previous config and queries", "error", err)
+				continue
+			}
+			lo.Info("config and queries reloaded")
+		}
+	}
+}
+
+func reload(apiServer *api.API, pgChainDataStore *data.PgChainData) error {
+	newKo, err := util.LoadConfig(confFlag)
+	if err != nil {
+		return err
+	}
+
+	pgQueries, err := loadQueries(queriesFlag)
+	if err != nil {
+		return fmt.Errorf("could not load queries: %w", err)
+	}
+
+	publicKey, err := util.LoadSigningKey(newKo.String("api.public_key"))
+	if err != nil {
+		return fmt.Errorf("could not load public key: %w", err)
+	}
+
+	// An empty level restores the default, like at startup
+	if level := newKo.String("log.level"); level != "" {
+		if err := util.SetLogLevel(level); err != nil {
+			return err
+		}
+	} else {
+		util.ResetLogLevel()
+	}
+
+	pgChainDataStore.SetQueries(pgQueries)
+	apiServer.SetVerifyingKey(publicKey)
+	apiServer.SetRequestTimeouts(newKo.Duration("api.timeouts.default"), routeTimeouts(newKo))
+
+	return nil
+}
+
+func routeTimeouts(k *koanf.Koanf) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration)
+	for route := range k.StringMap("api.timeouts.routes") {
+		timeouts[route] = k.Duration("api.timeouts.routes." + route)
+	}
+	return timeouts
+}
+
+func newPgChainDataStore(k *koanf.Koanf, pgQueries *data.PgQueries) (*data.PgChainData, error) {
+	queryTimeouts := make(map[string]time.Duration)
+	for name := range k.StringMap("postgres.query_timeouts") {
+		queryTimeouts[name] = k.Duration("postgres.query_timeouts." + name)
+	}
+
+	return data.NewPgChainDataSource(data.PgChainDataOpts{
+		Logg:        lo,
+		DSN:         k.MustString("postgres.federation_dsn"),
+		ReplicaDSNs: k.Strings("postgres.replica_dsns"),
+		Queries:     pgQueries,
+		Pool: data.PgPoolOpts{
+			MaxConns:          int32(k.Int("postgres.max_conns")),
+			MinConns:          int32(k.Int("postgres.min_conns")),
+			MaxConnLifetime:   k.Duration("postgres.max_conn_lifetime"),
+			MaxConnIdleTime:   k.Duration("postgres.max_conn_idle_time"),
+			HealthCheckPeriod: k.Duration("postgres.health_check_period"),
+			StatementTimeout:  k.Duration("postgres.statement_timeout"),
+		},
+		QueryTimeouts: queryTimeouts,
+
+		SlowQueryThreshold: k.Duration("instrumentation.slow_query_threshold"),
+	})
+}
+
+func newChainProvider(k *koanf.Koanf) *data.Chain {
+	return data.NewChainProvider(data.ChainOpts{
+		ChainID:         k.MustInt64("chain.id"),
+		RPCEndpoint:     k.MustString("chain.rpc_endpoint"),
+		BalancesScanner: k.MustString("chain.balances_scanner"),
+		Logg:            lo,
+
+		SlowCallThreshold: k.Duration("instrumentation.slow_rpc_threshold"),
+	})
+}
+
 func loadQueries(queriesPath string) (*data.PgQueries, error) {
 	parsedQueries, err := goyesql.ParseFile(queriesPath)
 	if err != nil {
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	daysQueryParam      = "days"
+	metricQueryParam    = "metric"
+	referenceQueryParam = "reference"
+
+	defaultPoolAnalyticsDays  = 7
+	defaultPoolAnalyticsLimit = 5
+)
+
+type (
+	// PoolAnalyticsQuery selects the window (whole UTC days ending today), the
+	// ranking metric and the number of pools. Reference values volumes in a
+	// reference token and is required by the volume metric, Pool restricts the
+	// analytics to a single pool.
+	PoolAnalyticsQuery struct {
+		Days      int    `validate:"min=1,max=90"`
+		Limit     int    `validate:"min=1,max=50"`
+		Metric    string `validate:"oneof=swaps users volume"`
+		Reference string `validate:"omitempty,eth_addr_checksum"`
+		Pool      string `validate:"omitempty,eth_addr_checksum"`
+	}
+
+	// poolActivityAcc accumulates a pool's rows before they are ranked.
+	poolActivityAcc struct {
+		activity *api.PoolActivity
+		volume   *big.Int
+		tokens   map[string]*poolTokenAcc
+		days     []*poolDayAcc
+	}
+
+	poolTokenAcc struct {
+		token  *api.PoolTokenVolume
+		volume *big.Int
+	}
+
+	poolDayAcc struct {
+		day    *api.PoolActivityDay
+		volume *big.Int
+	}
+)
+
+func (a *API) poolAnalyticsQuery(req bunrouter.Request) (PoolAnalyticsQuery, error) {
+	query := req.URL.Query()
+	q := PoolAnalyticsQuery{
+		Days:      defaultPoolAnalyticsDays,
+		Limit:     defaultPoolAnalyticsLimit,
+		Metric:    query.Get(metricQueryParam),
+		Reference: query.Get(referenceQueryParam),
+		Pool:      query.Get(poolQueryParam),
+	}
+	if q.Metric == "" {
+		q.Metric = api.PoolMetricSwaps
+	}
+
+	if v := query.Get(daysQueryParam); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil {
+			return q, badInput("Invalid days")
+		}
+		q.Days = days
+	}
+
+	if v := query.Get(limitQueryParam); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return q, badInput("Invalid limit")
+		}
+		q.Limit = limit
+	}
+
+	if err := a.validator.Validate(q); err != nil {
+		return q, badInput("Analytics validation failed")
+	}
+	if q.Metric == api.PoolMetricVolume && q.Reference == "" {
+		return q, badInput("The volume metric requires a reference token")
+	}
+
+	return q, nil
+}
+
+// poolAnalytics backs the v1 and v2 analytics routes.
+func (a *API) poolAnalytics(req bunrouter.Request) (*api.PoolAnalytics, error) {
+	q, err := a.poolAnalyticsQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var reference *api.TokenDetails
+	if q.Reference != "" {
+		reference, err = a.pgDataSource.TokenDetails(req.Context(), q.Reference)
+		if err != nil {
+			return nil, err
+		}
+		if reference == nil {
+			return nil, notFound("Reference token not found")
+		}
+	}
+
+	return PoolAnalytics(req.Context(), a.logg, a.pgDataSource, q, reference, time.Now())
+}
+
+func (a *API) poolAnalyticsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	analytics, err := a.poolAnalytics(req)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, http.StatusOK, api.OKResponse{
+		Ok:          true,
+		Description: "Pool analytics",
+		Result: map[string]any{
+			"analytics": analytics,
+		},
+		Freshness: a.freshness(w),
+	})
+}
+
+// PoolAnalytics ranks pools by their swap activity over the last q.Days UTC
+// days (today included) and returns daily buckets for each. Volumes are swap
+// inputs, valued in the reference token with the same exchange rates as
+// PortfolioValue when reference is set.
+func PoolAnalytics(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, q PoolAnalyticsQuery, reference *api.TokenDetails, now time.Time) (*api.PoolAnalytics, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	to := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -q.Days)
+
+	rows, err := pg.PoolActivityDaily(ctx, from, q.Pool)
+	if err != nil {
+		logg.Debug("Failed to get pool activity", "error", err)
+		return nil, err
+	}
+
+	users, err := pg.PoolActivityUsers(ctx, from, q.Pool)
+	if err != nil {
+		logg.Debug("Failed to get pool users", "error", err)
+		return nil, err
+	}
+
+	var rates []*api.TokenValuationRate
+	if reference != nil {
+		var tokenAddresses []string
+		seen := make(map[string]struct{})
+		for _, r := range rows {
+			if _, ok := seen[r.TokenAddress]; !ok {
+				seen[r.TokenAddress] = struct{}{}
+				tokenAddresses = append(tokenAddresses, r.TokenAddress)
+			}
+		}
+
+		rates, err = pg.TokenValuationRates(ctx, tokenAddresses, reference.TokenAddress)
+		if err != nil {
+			logg.Debug("Failed to get token valuation rates", "error", err)
+			return nil, err
+		}
+	}
+	logg.Debug("Pool activity fetched", "rows", len(rows), "users", len(users), "rates", len(rates))
+
+	pools, err := buildPoolAnalytics(rows, users, rates, reference, q, from)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &api.PoolAnalytics{
+		Metric: q.Metric,
+		From:   from,
+		To:     to,
+		Pools:  pools,
+	}
+	if reference != nil {
+		analytics.ReferenceTokenAddress = reference.TokenAddress
+		analytics.ReferenceTokenSymbol = reference.TokenSymbol
+		analytics.ReferenceTokenDecimals = reference.TokenDecimals
+	}
+
+	return analytics, nil
+}
+
+// buildPoolAnalytics aggregates the daily rows per pool, fills days without
+// swaps and returns the q.Limit best pools by q.Metric. Ties are broken by
+// swap count, then by pool address.
+func buildPoolAnalytics(rows []*api.PoolActivityRow, users []*api.PoolActivityUsers, rates []*api.TokenValuationRate, reference *api.TokenDetails, q PoolAnalyticsQuery, from time.Time) ([]*api.PoolActivity, error) {
+	ratesByToken := make(map[string]*api.TokenValuationRate, len(rates))
+	for _, r := range rates {
+		ratesByToken[r.TokenAddress] = r
+	}
+
+	// price values amount of a token in the reference token, false when it
+	// can't be priced
+	price := func(tokenAddress string, tokenDecimals uint8, amount *big.Int) (*big.Int, bool) {
+		if reference == nil {
+			return nil, false
+		}
+		if tokenAddress == reference.TokenAddress {
+			return amount, true
+		}
+		rate, ok := ratesByToken[tokenAddress]
+		if !ok {
+			return nil, false
+		}
+		return CalculateValue(amount, rate.TokenRate, rate.ReferenceRate, tokenDecimals, reference.TokenDecimals), true
+	}
+
+	var accs []*poolActivityAcc
+	pools := make(map[string]*poolActivityAcc)
+	pool := func(address, name, symbol string) *poolActivityAcc {
+		if acc, ok := pools[address]; ok {
+			return acc
+		}
+		acc := &poolActivityAcc{
+			activity: &api.PoolActivity{PoolAddress: address, PoolName: name, PoolSymbol: symbol},
+			volume:   new(big.Int),
+			tokens:   make(map[string]*poolTokenAcc),
+			days:     make([]*poolDayAcc, q.Days),
+		}
+		for i := range acc.days {
+			acc.days[i] = &poolDayAcc{
+				day:    &api.PoolActivityDay{Date: from.AddDate(0, 0, i)},
+				volume: new(big.Int),
+			}
+		}
+		pools[address] = acc
+		accs = append(accs, acc)
+		return acc
+	}
+
+	for _, r := range rows {
+		volume, ok := new(big.Int).SetString(r.Volume, 10)
+		if !ok {
+			return nil, internalError("Invalid swap volume")
+		}
+
+		acc := pool(r.PoolAddress, r.PoolName, r.PoolSymbol)
+		acc.activity.SwapCount += r.SwapCount
+
+		token, ok := acc.tokens[r.TokenAddress]
+		if !ok {
+			token = &poolTokenAcc{
+				token: &api.PoolTokenVolume{
+					TokenAddress:  r.TokenAddress,
+					TokenSymbol:   r.TokenSymbol,
+					TokenDecimals: r.TokenDecimals,
+				},
+				volume: new(big.Int),
+			}
+			acc.tokens[r.TokenAddress] = token
+		}
+		token.token.SwapCount += r.SwapCount
+		token.volume.Add(token.volume, volume)
+
+		if i, ok := dayIndex(r.Day, from, q.Days); ok {
+			day := acc.days[i]
+			day.day.SwapCount += r.SwapCount
+			if value, ok := price(r.TokenAddress, r.TokenDecimals, volume); ok {
+				day.volume.Add(day.volume, value)
+			}
+		}
+	}
+
+	for _, u := range users {
+		acc, ok := pools[u.PoolAddress]
+		if !ok {
+			continue
+		}
+		if u.Day == nil {
+			acc.activity.UniqueUsers = u.UniqueUsers
+		} else if i, ok := dayIndex(*u.Day, from, q.Days); ok {
+			acc.days[i].day.UniqueUsers = u.UniqueUsers
+		}
+	}
+
+	for _, acc := range accs {
+		for _, token := range acc.tokens {
+			token.token.Volume = token.volume.String()
+			if value, ok := price(token.token.TokenAddress, token.token.TokenDecimals, token.volume); ok {
+				token.token.Priced = true
+				token.token.Value = value.String()
+				acc.volume.Add(acc.volume, value)
+			}
+			acc.activity.Tokens = append(acc.activity.Tokens, token.token)
+		}
+		slices.SortFunc(acc.activity.Tokens, func(a, b *api.PoolTokenVolume) int {
+			return cmp.Or(
+				cmp.Compare(b.SwapCount, a.SwapCount),
+				strings.Compare(a.TokenAddress, b.TokenAddress),
+			)
+		})
+
+		acc.activity.Days = make([]*api.PoolActivityDay, len(acc.days))
+		for i, day := range acc.days {
+			if reference != nil {
+				day.day.Volume = day.volume.String()
+			}
+			acc.activity.Days[i] = day.day
+		}
+		if reference != nil {
+			acc.activity.Volume = acc.volume.String()
+		}
+	}
+
+	slices.SortFunc(accs, func(a, b *poolActivityAcc) int {
+		var byMetric int
+		switch q.Metric {
+		case api.PoolMetricUsers:
+			byMetric = cmp.Compare(b.activity.UniqueUsers, a.activity.UniqueUsers)
+		case api.PoolMetricVolume:
+			byMetric = b.volume.Cmp(a.volume)
+		}
+		return cmp.Or(
+			byMetric,
+			cmp.Compare(b.activity.SwapCount, a.activity.SwapCount),
+			strings.Compare(a.activity.PoolAddress, b.activity.PoolAddress),
+		)
+	})
+
+	if len(accs) > q.Limit {
+		accs = accs[:q.Limit]
+	}
+
+	activities := make([]*api.PoolActivity, len(accs))
+	for i, acc := range accs {
+		activities[i] = acc.activity
+	}
+	return activities, nil
+}
+
+// dayIndex returns the bucket of a UTC day in a window of days starting at
+// from.
+func dayIndex(day, from time.Time, days int) (int, bool) {
+	i := int(day.Sub(from) / (24 * time.Hour))
+	if day.Before(from) || i >= days {
+		return 0, false
+	}
+	return i, true
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestBuildPoolAnalytics(t *testing.T) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := func(i int) time.Time { return from.AddDate(0, 0, i) }
+	reference := &api.TokenDetails{TokenAddress: "0xUSD", TokenSymbol: "USD", TokenDecimals: 6}
+
+	rows := []*api.PoolActivityRow{
+		{PoolAddress: "0xP1", PoolSymbol: "P1", Day: day(0), TokenAddress: "0xUSD", TokenDecimals: 6, SwapCount: 1, Volume: "5000000"},
+		{PoolAddress: "0xP1", PoolSymbol: "P1", Day: day(2), TokenAddress: "0xUSD", TokenDecimals: 6, SwapCount: 1, Volume: "500000"},
+		{PoolAddress: "0xP2", PoolSymbol: "P2", Day: day(0), TokenAddress: "0xSRF", TokenDecimals: 6, SwapCount: 2, Volume: "2000000"},
+		{PoolAddress: "0xP2", PoolSymbol: "P2", Day: day(1), TokenAddress: "0xSRF", TokenDecimals: 6, SwapCount: 1, Volume: "1000000"},
+		{PoolAddress: "0xP2", PoolSymbol: "P2", Day: day(1), TokenAddress: "0xXYZ", TokenDecimals: 6, SwapCount: 1, Volume: "9000000"},
+	}
+	users := []*api.PoolActivityUsers{
+		{PoolAddress: "0xP1", UniqueUsers: 2},
+		{PoolAddress: "0xP1", Day: ptr(day(0)), UniqueUsers: 1},
+		{PoolAddress: "0xP1", Day: ptr(day(2)), UniqueUsers: 1},
+		{PoolAddress: "0xP2", UniqueUsers: 1},
+		{PoolAddress: "0xP2", Day: ptr(day(0)), UniqueUsers: 1},
+		{PoolAddress: "0xP2", Day: ptr(day(1)), UniqueUsers: 1},
+	}
+	// 1 SRF is worth 2 USD, XYZ is not priced
+	rates := []*api.TokenValuationRate{
+		{TokenAddress: "0xSRF", PoolAddress: "0xP2", TokenRate: 20_000, ReferenceRate: 10_000},
+	}
+
+	tests := []struct {
+		name      string
+		metric    string
+		reference *api.TokenDetails
+		limit     int
+		wantPools []string
+	}{
+		{name: "swaps", metric: api.PoolMetricSwaps, limit: 5, wantPools: []string{"0xP2", "0xP1"}},
+		{name: "users", metric: api.PoolMetricUsers, limit: 5, wantPools: []string{"0xP1", "0xP2"}},
+		{name: "volume", metric: api.PoolMetricVolume, reference: reference, limit: 5, wantPools: []string{"0xP2", "0xP1"}},
+		{name: "limit", metric: api.PoolMetricUsers, limit: 1, wantPools: []string{"0xP1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := PoolAnalyticsQuery{Days: 3, Limit: tt.limit, Metric: tt.metric}
+			pools, err := buildPoolAnalytics(rows, users, rates, tt.reference, q, from)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, p := range pools {
+				got = append(got, p.PoolAddress)
+				if len(p.Days) != q.Days {
+					t.Errorf("%s has %d days, want %d", p.PoolAddress, len(p.Days), q.Days)
+				}
+				if tt.reference == nil && p.Volume != "" {
+					t.Errorf("%s volume = %q without a reference token", p.PoolAddress, p.Volume)
+				}
+			}
+			if len(got) != len(tt.wantPools) {
+				t.Fatalf("pools = %v, want %v", got, tt.wantPools)
+			}
+			for i := range got {
+				if got[i] != tt.wantPools[i] {
+					t.Fatalf("pools = %v, want %v", got, tt.wantPools)
+				}
+			}
+		})
+	}
+
+	t.Run("buckets and values", func(t *testing.T) {
+		q := PoolAnalyticsQuery{Days: 3, Limit: 5, Metric: api.PoolMetricVolume}
+		pools, err := buildPoolAnalytics(rows, users, rates, reference, q, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := pools[0]
+		if p2.SwapCount != 4 || p2.UniqueUsers != 1 || p2.Volume != "6000000" {
+			t.Errorf("P2 swaps %d users %d volume %s, want 4 1 6000000", p2.SwapCount, p2.UniqueUsers, p2.Volume)
+		}
+
+		wantDays := []struct {
+			swaps  int64
+			volume string
+		}{{2, "4000000"}, {2, "2000000"}, {0, "0"}}
+		for i, want := range wantDays {
+			d := p2.Days[i]
+			if !d.Date.Equal(day(i)) || d.SwapCount != want.swaps || d.Volume != want.volume {
+				t.Errorf("day %d = %s %d %s, want %s %d %s", i, d.Date, d.SwapCount, d.Volume, day(i), want.swaps, want.volume)
+			}
+		}
+
+		tokens := map[string]*api.PoolTokenVolume{}
+		for _, token := range p2.Tokens {
+			tokens[token.TokenAddress] = token
+		}
+		if srf := tokens["0xSRF"]; !srf.Priced || srf.Volume != "3000000" || srf.Value != "6000000" {
+			t.Errorf("SRF = %+v", srf)
+		}
+		if xyz := tokens["0xXYZ"]; xyz.Priced || xyz.Value != "" {
+			t.Errorf("XYZ = %+v, want unpriced", xyz)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/grassrootseconomics/ussd-data-service/internal/data"
@@ -22,77 +24,250 @@ type (
 		Logg            *slog.Logger
 		PgDataSource    *data.PgChainData
 		ChainDataSource *data.Chain
+		Denylist        *data.Denylist
+		IndexerMonitor  *data.IndexerMonitor
+		RegistryCache   *data.RegistryCache
+		// RequestTimeout is the default per request deadline, RouteTimeouts
+		// overrides it by route template (e.g. /api/v1/holdings/:address)
+		RequestTimeout time.Duration
+		RouteTimeouts  map[string]time.Duration
+		// MaxHeadAge is how old the RPC head block may be before readiness fails
+		MaxHeadAge time.Duration
+
+		ReadTimeout       time.Duration
+		ReadHeaderTimeout time.Duration
+		WriteTimeout      time.Duration
+		IdleTimeout       time.Duration
+		MaxHeaderBytes    int
+
+		EnableTLS       bool
+		TLSCertFile     string
+		TLSKeyFile      string
+		TLSClientCAFile string
+		// TLSClientNames optionally restricts client certificate auth to these common names
+		TLSClientNames []string
 	}
 
 	API struct {
 		validator       httputil.ValidatorProvider
-		verifyingKey    crypto.PublicKey
+		verifyingKey    atomic.Pointer[crypto.PublicKey]
 		router          *bunrouter.Router
 		server          *http.Server
 		logg            *slog.Logger
 		pgDataSource    *data.PgChainData
 		chainDataSource *data.Chain
+		denylist        *data.Denylist
+		indexerMonitor  *data.IndexerMonitor
+		registryCache   *data.RegistryCache
+		clientNames     []string
+		maxHeadAge      time.Duration
+		timeouts        atomic.Pointer[requestTimeouts]
+	}
+
+	// route is a GET route of an API version, relative to the version prefix.
+	route struct {
+		path    string
+		handler bunrouter.HandlerFunc
+	}
+
+	requestTimeouts struct {
+		defaultTimeout time.Duration
+		routes         map[string]time.Duration
 	}
 )
 
 const (
-	apiVersion = "/api/v1"
-	slaTimeout = 10 * time.Second
+	apiVersion   = "/api/v1"
+	apiV2Version = "/api/v2"
+	slaTimeout   = 10 * time.Second
+	// statementTimeout bounds statement exports, which stream a whole range
+	statementTimeout = 2 * time.Minute
+
+	defaultReadTimeout       = 15 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultWriteTimeout      = slaTimeout + 5*time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultMaxHeaderBytes    = 16 << 10
 )
 
-func New(o APIOpts) *API {
+func New(o APIOpts) (*API, error) {
 	api := &API{
 		validator:       httputil.NewValidator(""),
-		verifyingKey:    o.VerifyingKey,
 		logg:            o.Logg,
 		pgDataSource:    o.PgDataSource,
 		chainDataSource: o.ChainDataSource,
+		denylist:        o.Denylist,
+		indexerMonitor:  o.IndexerMonitor,
+		registryCache:   o.RegistryCache,
+		clientNames:     o.TLSClientNames,
+		maxHeadAge:      valueOrDefault(o.MaxHeadAge, defaultMaxHeadAge),
 		router: bunrouter.New(
 			bunrouter.WithNotFoundHandler(notFoundHandler),
 			bunrouter.WithMethodNotAllowedHandler(methodNotAllowedHandler),
 		),
 	}
 
+	api.SetVerifyingKey(o.VerifyingKey)
+	api.SetRequestTimeouts(o.RequestTimeout, o.RouteTimeouts)
+
 	if o.EnableMetrics {
 		api.router.GET("/metrics", metricsHandler)
 	}
 
+	api.router.GET("/health/live", liveHandler)
+	api.router.GET("/health/ready", api.readyHandler)
+	api.router.GET("/openapi.json", openAPIHandler)
+
 	api.router.WithGroup(apiVersion, func(g *bunrouter.Group) {
-		if os.Getenv("DEV") != "" {
-			g = g.Use(reqlog.NewMiddleware())
+		g = api.useMiddlewares(g, o.EnableMetrics, errResponse)
+		for _, r := range api.v1Routes() {
+			g.GET(r.path, r.handler)
 		}
+	})
 
-		g = g.Use(api.authMiddleware)
-
-		g.GET("/transfers/last10/:address", api.last10TxHandler)
-		g.GET("/holdings/:address", api.tokenHoldingsHandler)
-		g.GET("/token/:address", api.tokenDetailsHandler)
-		g.GET("/pool/:address", api.poolDetailsHandler)
-		g.GET("/pool/reverse/:symbol", api.poolReverseDetailsHandler)
-		g.GET("/pool/top", api.topPoolsHandlder)
-		g.GET("/pool/:pool/from/:address", api.poolSwapFromVouchersList)
-		g.GET("/pool/:pool/check/:address", api.poolSwapFromCheck)
-		g.GET("/pool/:pool/to/", api.poolSwapToVouchersList)
-		g.GET("/alias/:alias", api.aliasHandler)
-		g.GET("/credit-send/:pool/:from/:to/:address", api.creditSendHandler)
-		g.GET("/pool/reverse-quote/:pool/:from/:to/:amount", api.reverseQuoteHandler)
-		// Legacy routes, remove in the future
-		g.GET("/pool/:pool/limit/:from/:to/:address", api.poolMaxLimit)
-		g.GET("/absolute-credit/:pool/:token/:address", api.poolBalanceHandler)
-		g.GET("/relative-credit/:pool/:from/:to/:address", api.poolMaxLimit)
+	api.router.WithGroup(apiV2Version, func(g *bunrouter.Group) {
+		g = api.useMiddlewares(g, o.EnableMetrics, v2ErrResponse)
+		for _, r := range api.v2Routes() {
+			g.GET(r.path, r.handler)
+		}
 	})
 
 	api.server = &http.Server{
-		Addr:    o.ListenAddress,
-		Handler: api.router,
+		Addr:              o.ListenAddress,
+		Handler:           api.router,
+		ReadTimeout:       valueOrDefault(o.ReadTimeout, defaultReadTimeout),
+		ReadHeaderTimeout: valueOrDefault(o.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		WriteTimeout:      valueOrDefault(o.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       valueOrDefault(o.IdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes:    valueOrDefault(o.MaxHeaderBytes, defaultMaxHeaderBytes),
+		ErrorLog:          slog.NewLogLogger(o.Logg.Handler(), slog.LevelWarn),
+	}
+
+	if o.EnableTLS {
+		tlsConfig, err := api.buildTLSConfig(o)
+		if err != nil {
+			return nil, err
+		}
+		api.server.TLSConfig = tlsConfig
+	}
+
+	return api, nil
+}
+
+func (a *API) v1Routes() []route {
+	return []route{
+		{path: "/transfers/last10/:address", handler: a.last10TxHandler},
+		{path: "/holdings/:address", handler: a.tokenHoldingsHandler},
+		{path: "/holdings/:address/value/:token", handler: a.portfolioValueHandler},
+		{path: "/statement/:address", handler: a.statementHandler},
+		{path: "/token/search", handler: a.tokenSearchHandler},
+		{path: "/token/:address", handler: a.tokenDetailsHandler},
+		{path: "/registry/:address/tokens", handler: a.registryTokensHandler},
+		{path: "/pool/:address", handler: a.poolDetailsHandler},
+		{path: "/pool/:address/state", handler: a.poolStateHandler},
+		{path: "/pool/reverse/:symbol", handler: a.poolReverseDetailsHandler},
+		{path: "/pool/top", handler: a.topPoolsHandlder},
+		{path: "/pool/analytics", handler: a.poolAnalyticsHandler},
+		{path: "/pool/search", handler: a.poolSearchHandler},
+		{path: "/pool/:pool/from/:address", handler: a.poolSwapFromVouchersList},
+		{path: "/pool/:pool/check/:address", handler: a.poolSwapFromCheck},
+		{path: "/pool/:pool/to/", handler: a.poolSwapToVouchersList},
+		{path: "/alias/:alias", handler: a.aliasHandler},
+		{path: "/credit-send/:pool/:from/:to/:address", handler: a.creditSendHandler},
+		{path: "/pool/reverse-quote/:pool/:from/:to/:amount", handler: a.reverseQuoteHandler},
+		// Legacy routes, remove in the future
+		{path: "/pool/:pool/limit/:from/:to/:address", handler: a.poolMaxLimit},
+		{path: "/absolute-credit/:pool/:token/:address", handler: a.poolBalanceHandler},
+		{path: "/relative-credit/:pool/:from/:to/:address", handler: a.poolMaxLimit},
+	}
+}
+
+func (a *API) v2Routes() []route {
+	return []route{
+		{path: "/transfers/:address", handler: a.v2TransfersHandler},
+		{path: "/holdings/:address", handler: a.v2HoldingsHandler},
+		{path: "/holdings/:address/value/:token", handler: a.v2PortfolioValueHandler},
+		{path: "/statements/:address", handler: a.statementHandler},
+		{path: "/tokens/search", handler: a.v2TokenSearchHandler},
+		{path: "/tokens/:address", handler: a.v2TokenHandler},
+		{path: "/registries/:address/tokens", handler: a.v2RegistryTokensHandler},
+		{path: "/pools/top", handler: a.v2TopPoolsHandler},
+		{path: "/pools/analytics", handler: a.v2PoolAnalyticsHandler},
+		{path: "/pools/search", handler: a.v2PoolSearchHandler},
+		{path: "/pools/symbol/:symbol", handler: a.v2PoolBySymbolHandler},
+		{path: "/pools/:pool", handler: a.v2PoolHandler},
+		{path: "/pools/:pool/state", handler: a.v2PoolStateHandler},
+		{path: "/pools/:pool/swap-from/:address", handler: a.v2SwapFromHandler},
+		{path: "/pools/:pool/swap-from-allowed/:token", handler: a.v2SwapFromAllowedHandler},
+		{path: "/pools/:pool/swap-to", handler: a.v2SwapToHandler},
+		{path: "/pools/:pool/limit/:from/:to/:address", handler: a.v2SwapLimitHandler},
+		{path: "/pools/:pool/quote/:from/:to/:amount", handler: a.v2QuoteHandler},
+		{path: "/pools/:pool/credit/:token/:address", handler: a.v2CreditHandler},
+		{path: "/pools/:pool/credit-send/:from/:to/:address", handler: a.v2CreditSendHandler},
+		{path: "/aliases/:alias", handler: a.v2AliasHandler},
 	}
+}
+
+// SetVerifyingKey swaps the JWT verifying key, e.g. on config reload.
+func (a *API) SetVerifyingKey(key crypto.PublicKey) {
+	a.verifyingKey.Store(&key)
+}
+
+// defaultRouteTimeouts are the deadlines of routes that outlive the default
+// one, configured route timeouts take precedence.
+var defaultRouteTimeouts = map[string]time.Duration{
+	apiVersion + "/statement/:address":    statementTimeout,
+	apiV2Version + "/statements/:address": statementTimeout,
+}
+
+// SetRequestTimeouts swaps the default and per route request deadlines.
+func (a *API) SetRequestTimeouts(defaultTimeout time.Duration, routes map[string]time.Duration) {
+	merged := maps.Clone(defaultRouteTimeouts)
+	maps.Copy(merged, routes)
 
-	return api
+	a.timeouts.Store(&requestTimeouts{
+		defaultTimeout: valueOrDefault(defaultTimeout, slaTimeout),
+		routes:         merged,
+	})
+}
+
+// useMiddlewares applies the middlewares shared by every API version, with
+// errors written in the version's format.
+func (a *API) useMiddlewares(g *bunrouter.Group, enableMetrics bool, writeErr errWriter) *bunrouter.Group {
+	g = g.Use(a.requestIDMiddleware)
+
+	if os.Getenv("DEV") != "" {
+		g = g.Use(reqlog.NewMiddleware())
+	}
+
+	g = g.Use(tracingMiddleware)
+
+	if enableMetrics {
+		g = g.Use(requestMetricsMiddleware)
+	}
+
+	g = g.Use(a.timeoutMiddleware)
+	g = g.Use(a.errorMiddleware(writeErr))
+
+	return g.Use(a.authMiddleware)
+}
+
+// Handler returns the router, e.g. to serve the API in-process in tests.
+func (a *API) Handler() http.Handler {
+	return a.router
 }
 
 func (a *API) Start() error {
-	a.logg.Info("API server starting", "address", a.server.Addr)
-	if err := a.server.ListenAndServe(); err != http.ErrServerClosed {
+	a.logg.Info("API server starting", "address", a.server.Addr, "tls", a.server.TLSConfig != nil)
+
+	var err error
+	if a.server.TLSConfig != nil {
+		// Certificates are served by TLSConfig.GetCertificate.
+		err = a.server.ListenAndServeTLS("", "")
+	} else {
+		err = a.server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		return err
 	}
 	return nil
@@ -101,3 +276,11 @@ func (a *API) Start() error {
 func (a *API) Stop(ctx context.Context) error {
 	return a.server.Shutdown(ctx)
 }
+
+func valueOrDefault[T comparable](v T, fallback T) T {
+	var zero T
+	if v == zero {
+		return fallback
+	}
+	return v
+}
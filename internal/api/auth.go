@@ -3,13 +3,15 @@ package api
 import (
 	"net/http"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang-jwt/jwt/v5/request"
 	model "github.com/grassrootseconomics/ussd-data-service/pkg/api"
-	"github.com/kamikazechaser/common/httputil"
 	"github.com/uptrace/bunrouter"
 )
 
+var revokedTokensCounter = metrics.NewCounter("api_auth_revoked_tokens_total")
+
 type JWTCustomClaims struct {
 	PublicKey string `json:"publicKey"`
 	Service   bool   `json:"service"`
@@ -18,44 +20,43 @@ type JWTCustomClaims struct {
 
 func (a *API) authMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		// Internal callers presenting a verified client certificate skip JWT auth.
+		if commonName, ok := a.verifiedClientCert(req.TLS); ok {
+			a.logger(req).Debug("client certificate auth", "cn", commonName)
+			return next(w, req)
+		}
+
 		if h := req.Header.Get("Authorization"); h != "" {
 			token, err := request.ParseFromRequest(req.Request, request.AuthorizationHeaderExtractor, func(t *jwt.Token) (interface{}, error) {
 				if t.Method.Alg() != jwt.SigningMethodEdDSA.Alg() {
 					return nil, jwt.ErrTokenUnverifiable
 				}
-				return a.verifyingKey, nil
+				return *a.verifyingKey.Load(), nil
 			}, request.WithClaims(&JWTCustomClaims{}))
 
 			if err != nil {
-				a.logg.Error("JWT validation failed", "error", err)
-				return httputil.JSON(w, http.StatusBadRequest, model.ErrResponse{
-					Ok:          false,
-					Description: "JWT validation failed",
-				})
+				a.logger(req).Error("JWT validation failed", "error", err)
+				return &apiError{status: http.StatusBadRequest, code: model.ErrCodeInvalidToken, description: "JWT validation failed", err: err}
 			}
 
 			if !token.Valid {
-				return httputil.JSON(w, http.StatusUnauthorized, model.ErrResponse{
-					Ok:          false,
-					Description: "Invalid token",
-				})
+				return unauthorized(model.ErrCodeInvalidToken, "Invalid token")
 			}
 
-			if claims, ok := token.Claims.(JWTCustomClaims); ok {
-				if !claims.Service {
-					return httputil.JSON(w, http.StatusUnauthorized, model.ErrResponse{
-						Ok:          false,
-						Description: "Only service level keys allowed",
-					})
-				}
+			claims, ok := token.Claims.(*JWTCustomClaims)
+			if !ok || !claims.Service {
+				return unauthorized(model.ErrCodeInvalidToken, "Only service level keys allowed")
+			}
+
+			if a.denylist != nil && a.denylist.IsRevoked(claims.ID, claims.Subject) {
+				revokedTokensCounter.Inc()
+				a.logger(req).Warn("revoked token rejected", "jti", claims.ID, "subject", claims.Subject)
+				return unauthorized(model.ErrCodeTokenRevoked, "Token has been revoked")
 			}
 
 			return next(w, req)
 		} else {
-			return httputil.JSON(w, http.StatusUnauthorized, model.ErrResponse{
-				Ok:          false,
-				Description: "Authorization token is required",
-			})
+			return unauthorized(model.ErrCodeAuthRequired, "Authorization token is required")
 		}
 	}
 }
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+)
+
+const (
+	testAddress        = "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439"
+	testInvalidAddress = "0x5523058cdffe5f3c1eadadd5015e55c6e00fb439"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logg := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	file := filepath.Join(t.TempDir(), "denylist.json")
+	if err := os.WriteFile(file, []byte(`[{"jti": "revoked"}, {"subject": "banned"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	denylist := data.NewDenylist(data.DenylistOpts{Logg: logg, File: file})
+	if err := denylist.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(APIOpts{VerifyingKey: pub, Logg: logg, Denylist: denylist})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(claims JWTCustomClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name       string
+		claims     JWTCustomClaims
+		wantStatus int
+	}{
+		{name: "service token", claims: JWTCustomClaims{Service: true}, wantStatus: http.StatusOK},
+		{name: "non service token", claims: JWTCustomClaims{}, wantStatus: http.StatusUnauthorized},
+		{name: "revoked jti", claims: JWTCustomClaims{Service: true, RegisteredClaims: jwt.RegisteredClaims{ID: "revoked"}}, wantStatus: http.StatusUnauthorized},
+		{name: "revoked subject", claims: JWTCustomClaims{Service: true, RegisteredClaims: jwt.RegisteredClaims{Subject: "banned"}}, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/alias/alice", nil)
+			req.Header.Set("Authorization", "Bearer "+sign(tt.claims))
+			rec := httptest.NewRecorder()
+			a.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+// newTestAPI returns an API with a generated verifying key and a service
+// token signed with it.
+func newTestAPI(t *testing.T, o APIOpts) (*API, string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o.VerifyingKey = pub
+	o.Logg = slog.New(slog.NewTextHandler(io.Discard, nil))
+	a, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, JWTCustomClaims{Service: true}).SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, token
+}
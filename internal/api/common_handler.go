@@ -2,22 +2,50 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
 	model "github.com/grassrootseconomics/ussd-data-service/pkg/api"
 	"github.com/kamikazechaser/common/httputil"
 	"github.com/uptrace/bunrouter"
 )
 
-func notFoundHandler(w http.ResponseWriter, _ bunrouter.Request) error {
-	return httputil.JSON(w, http.StatusNotFound, model.ErrResponse{
+// errWriter writes an apiError in a version's error format.
+type errWriter func(w http.ResponseWriter, req bunrouter.Request, apiErr *apiError) error
+
+func notFoundHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	return errWriterFor(req)(w, req, &apiError{status: http.StatusNotFound, code: model.ErrCodeNotFound, description: "Not found"})
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	return errWriterFor(req)(w, req, &apiError{status: http.StatusMethodNotAllowed, code: model.ErrCodeMethodNotAllowed, description: "Method not allowed"})
+}
+
+// errWriterFor picks the error format for requests that matched no route.
+func errWriterFor(req bunrouter.Request) errWriter {
+	if strings.HasPrefix(req.URL.Path, apiV2Version+"/") {
+		return v2ErrResponse
+	}
+	return errResponse
+}
+
+func errResponse(w http.ResponseWriter, req bunrouter.Request, apiErr *apiError) error {
+	return httputil.JSON(w, apiErr.status, model.ErrResponse{
 		Ok:          false,
-		Description: "Not found",
+		Code:        apiErr.code,
+		Description: apiErr.description,
+		RequestID:   util.RequestIDFromContext(req.Context()),
 	})
 }
 
-func methodNotAllowedHandler(w http.ResponseWriter, _ bunrouter.Request) error {
-	return httputil.JSON(w, http.StatusMethodNotAllowed, model.ErrResponse{
-		Ok:          false,
-		Description: "Method not allowed",
+func v2ErrResponse(w http.ResponseWriter, req bunrouter.Request, apiErr *apiError) error {
+	return httputil.JSON(w, apiErr.status, model.V2ErrResponse{
+		Ok: false,
+		Error: model.V2Error{
+			Status:    apiErr.status,
+			Code:      apiErr.code,
+			Message:   apiErr.description,
+			RequestID: util.RequestIDFromContext(req.Context()),
+		},
 	})
 }
@@ -55,6 +55,11 @@ type (
 		Amount      string `validate:"required"`                   // Desired output amount
 	}
 
+	PortfolioValueParams struct {
+		Address        string `validate:"required,eth_addr_checksum"`
+		ReferenceToken string `validate:"required,eth_addr_checksum"`
+	}
+
 	AliasParam struct {
 		// TODO: Add extra validations here
 		Alias string `validate:"required"`
@@ -67,10 +72,7 @@ func (a *API) last10TxHandler(w http.ResponseWriter, req bunrouter.Request) erro
 	}
 
 	if err := a.validator.Validate(r); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
 	}
 
 	last10Tx, err := a.pgDataSource.Last10Tx(req.Context(), r.Address)
@@ -84,6 +86,7 @@ func (a *API) last10TxHandler(w http.ResponseWriter, req bunrouter.Request) erro
 		Result: map[string]any{
 			"transfers": last10Tx,
 		},
+		Freshness: a.freshness(w),
 	})
 }
 
@@ -93,10 +96,12 @@ func (a *API) tokenHoldingsHandler(w http.ResponseWriter, req bunrouter.Request)
 	}
 
 	if err := a.validator.Validate(r); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
+	}
+
+	q, err := a.holdingsQuery(req)
+	if err != nil {
+		return err
 	}
 
 	tokenHoldings, err := a.pgDataSource.TokenHoldings(req.Context(), r.Address)
@@ -104,10 +109,11 @@ func (a *API) tokenHoldingsHandler(w http.ResponseWriter, req bunrouter.Request)
 		return err
 	}
 
-	filteredHoldings, err := a.chainDataSource.MergeTokenBalances(req.Context(), tokenHoldings, r.Address)
+	filteredHoldings, partial, err := a.mergeBalancesWithinSLA(req, tokenHoldings, r.Address, q.IncludeEmpty)
 	if err != nil {
 		return err
 	}
+	sortHoldings(filteredHoldings, q.Sort)
 
 	return httputil.JSON(w, http.StatusOK, api.OKResponse{
 		Ok:          true,
@@ -115,6 +121,25 @@ func (a *API) tokenHoldingsHandler(w http.ResponseWriter, req bunrouter.Request)
 		Result: map[string]any{
 			"holdings": filteredHoldings,
 		},
+		Freshness: a.freshness(w),
+		Partial:   partial,
+	})
+}
+
+func (a *API) portfolioValueHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	valuation, partial, err := a.portfolioValue(req)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, http.StatusOK, api.OKResponse{
+		Ok:          true,
+		Description: "Token holdings valued in the reference token",
+		Result: map[string]any{
+			"valuation": valuation,
+		},
+		Freshness: a.freshness(w),
+		Partial:   partial,
 	})
 }
 
@@ -124,14 +149,11 @@ func (a *API) tokenDetailsHandler(w http.ResponseWriter, req bunrouter.Request)
 	}
 
 	if err := a.validator.Validate(r); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
 	}
 	tokenDetails, err := a.pgDataSource.TokenDetails(req.Context(), r.Address)
 	if err != nil {
-		a.logg.Error("Failed to get token details", "error", err)
+		a.logger(req).Error("Failed to get token details", "error", err)
 		return err
 	}
 
@@ -161,10 +183,7 @@ func (a *API) poolDetailsHandler(w http.ResponseWriter, req bunrouter.Request) e
 	}
 
 	if err := a.validator.Validate(r); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
 	}
 
 	poolDetails, err := a.pgDataSource.PoolDetails(req.Context(), r.Address)
@@ -194,23 +213,17 @@ func (a *API) poolReverseDetailsHandler(w http.ResponseWriter, req bunrouter.Req
 	}
 
 	if err := a.validator.Validate(r); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
 	}
 
 	poolDetails, err := a.pgDataSource.PoolReverseDetails(req.Context(), r.Symbol)
 	if err != nil {
-		a.logg.Debug("Failed to get pool details", "error", err)
+		a.logger(req).Debug("Failed to get pool details", "error", err)
 		return err
 	}
 
 	if poolDetails == nil {
-		return httputil.JSON(w, http.StatusNotFound, api.ErrResponse{
-			Ok:          false,
-			Description: "Pool not found",
-		})
+		return notFound("Pool not found")
 	}
 
 	return httputil.JSON(w, http.StatusOK, api.OKResponse{
@@ -225,7 +238,7 @@ func (a *API) poolReverseDetailsHandler(w http.ResponseWriter, req bunrouter.Req
 func (a *API) topPoolsHandlder(w http.ResponseWriter, req bunrouter.Request) error {
 	topPools, err := a.pgDataSource.TopPools(req.Context())
 	if err != nil {
-		a.logg.Debug("Failed to get pool details", "error", err)
+		a.logger(req).Debug("Failed to get pool details", "error", err)
 		return err
 	}
 
@@ -235,6 +248,7 @@ func (a *API) topPoolsHandlder(w http.ResponseWriter, req bunrouter.Request) err
 		Result: map[string]any{
 			"topPools": topPools,
 		},
+		Freshness: a.freshness(w),
 	})
 }
 
@@ -245,23 +259,22 @@ func (a *API) poolSwapFromVouchersList(w http.ResponseWriter, req bunrouter.Requ
 	}
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
+	}
+
+	q, err := a.holdingsQuery(req)
+	if err != nil {
+		return err
 	}
 
 	poolDetails, err := a.pgDataSource.PoolDetails(req.Context(), u.PoolAddress)
 	if err != nil {
-		a.logg.Debug("Failed to get pool details", "error", err)
+		a.logger(req).Debug("Failed to get pool details", "error", err)
 		return err
 	}
 
 	if poolDetails == nil {
-		return httputil.JSON(w, http.StatusNotFound, api.ErrResponse{
-			Ok:          false,
-			Description: "Pool not found",
-		})
+		return notFound("Pool not found")
 	}
 
 	filtered, err := a.pgDataSource.PoolAllowedTokensForUser(req.Context(), u.UserAddress, u.PoolAddress)
@@ -269,10 +282,11 @@ func (a *API) poolSwapFromVouchersList(w http.ResponseWriter, req bunrouter.Requ
 		return err
 	}
 
-	filteredHoldings, err := a.chainDataSource.MergeTokenBalances(req.Context(), filtered, u.UserAddress)
+	filteredHoldings, partial, err := a.mergeBalancesWithinSLA(req, filtered, u.UserAddress, q.IncludeEmpty)
 	if err != nil {
 		return err
 	}
+	sortHoldings(filteredHoldings, q.Sort)
 
 	return httputil.JSON(w, http.StatusOK, api.OKResponse{
 		Ok:          true,
@@ -280,6 +294,8 @@ func (a *API) poolSwapFromVouchersList(w http.ResponseWriter, req bunrouter.Requ
 		Result: map[string]any{
 			"filtered": filteredHoldings,
 		},
+		Freshness: a.freshness(w),
+		Partial:   partial,
 	})
 }
 
@@ -290,15 +306,12 @@ func (a *API) poolSwapFromCheck(w http.ResponseWriter, req bunrouter.Request) er
 	}
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
 	}
 
 	isAllowed, err := a.pgDataSource.PoolTokenAllowed(req.Context(), u.PoolAddress, u.TokenAddress)
 	if err != nil {
-		a.logg.Debug("Failed to check if token is allowed in pool", "error", err)
+		a.logger(req).Debug("Failed to check if token is allowed in pool", "error", err)
 		return err
 	}
 
@@ -318,10 +331,7 @@ func (a *API) poolSwapToVouchersList(w http.ResponseWriter, req bunrouter.Reques
 	isStablesQueryOnly := req.URL.Query().Get("stables") == "true"
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
+		return badInput("Address validation failed")
 	}
 
 	if isStablesQueryOnly {
@@ -336,6 +346,7 @@ func (a *API) poolSwapToVouchersList(w http.ResponseWriter, req bunrouter.Reques
 			Result: map[string]any{
 				"filtered": stables,
 			},
+			Freshness: a.freshness(w),
 		})
 	}
 
@@ -344,7 +355,7 @@ func (a *API) poolSwapToVouchersList(w http.ResponseWriter, req bunrouter.Reques
 		return err
 	}
 
-	filteredHoldings, err := a.chainDataSource.MergeTokenBalances(req.Context(), allTokens, u.Address)
+	filteredHoldings, partial, err := a.mergeBalancesWithinSLA(req, allTokens, u.Address, false)
 	if err != nil {
 		return err
 	}
@@ -355,6 +366,8 @@ func (a *API) poolSwapToVouchersList(w http.ResponseWriter, req bunrouter.Reques
 		Result: map[string]any{
 			"filtered": filteredHoldings,
 		},
+		Freshness: a.freshness(w),
+		Partial:   partial,
 	})
 }
 
@@ -365,81 +378,16 @@ func (a *API) poolMaxLimit(w http.ResponseWriter, req bunrouter.Request) error {
 		FromToken:   req.Param("from"),
 		ToToken:     req.Param("to"),
 	}
-	a.logg.Debug("Pool max limit request", "pool", u.PoolAddress, "user", u.UserAddress, "from", u.FromToken, "to", u.ToToken)
+	a.logger(req).Debug("Pool max limit request", "pool", u.PoolAddress, "user", u.UserAddress, "from", u.FromToken, "to", u.ToToken)
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
-	}
-
-	swapRates, err := a.pgDataSource.PoolTokenSwapRates(req.Context(), u.PoolAddress, u.FromToken, u.ToToken)
-	if err != nil {
-		a.logg.Debug("Failed to get token swap rates", "error", err)
-		return err
-	}
-
-	if swapRates == nil {
-		return httputil.JSON(w, http.StatusNotFound, api.ErrResponse{
-			Ok:          false,
-			Description: "Swap rates not found for the specified pool and tokens",
-		})
+		return badInput("Address validation failed")
 	}
 
-	if swapRates.InRate == 0 {
-		swapRates.OutRate = 10_000
-	}
-	if swapRates.OutRate == 0 {
-		swapRates.OutRate = 10_000
-	}
-
-	a.logg.Debug("Swap rates found", "inRate", swapRates.InRate, "outRate", swapRates.OutRate,
-		"inDecimals", swapRates.InDecimals, "outDecimals", swapRates.OutDecimals,
-		"inTokenLimit", swapRates.InTokenLimit, "outTokenLimit", swapRates.OutTokenLimit)
-
-	// Get user balance and pool balance from chain
-	userInBalance, poolInBalance, poolOutBalance, err := a.chainDataSource.GetSwapBalances(
-		req.Context(),
-		u.UserAddress,
-		u.PoolAddress,
-		u.FromToken,
-		u.ToToken,
-	)
+	maxSwapInput, err := SwapLimit(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, u)
 	if err != nil {
 		return err
 	}
-	a.logg.Debug("Swap balances found", "userInBalance", userInBalance.String(),
-		"poolInBalance", poolInBalance.String(), "poolOutBalance", poolOutBalance.String())
-
-	// Convert the token limit from database string to *big.Int
-	inTokenLimit := new(big.Int)
-	if _, ok := inTokenLimit.SetString(swapRates.InTokenLimit, 10); !ok {
-		return httputil.JSON(w, http.StatusInternalServerError, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid token limit format",
-		})
-	}
-
-	outTokenLimit := new(big.Int)
-	if _, ok := outTokenLimit.SetString(swapRates.OutTokenLimit, 10); !ok {
-		return httputil.JSON(w, http.StatusInternalServerError, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid token limit format",
-		})
-	}
-
-	maxSwapInput := a.chainDataSource.MaxSwapInput(
-		userInBalance,
-		inTokenLimit,
-		outTokenLimit,
-		poolInBalance,
-		poolOutBalance,
-		swapRates.InRate,
-		swapRates.OutRate,
-		swapRates.InDecimals,
-		swapRates.OutDecimals,
-	)
 
 	return httputil.JSON(w, http.StatusOK, api.OKResponse{
 		Ok:          true,
@@ -459,51 +407,14 @@ func (a *API) poolBalanceHandler(w http.ResponseWriter, req bunrouter.Request) e
 	}
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
-	}
-
-	poolLimit, err := a.pgDataSource.PoolTokenLimit(req.Context(), u.PoolAddress, u.TokenAddress)
-	if err != nil {
-		a.logg.Debug("Failed to get pool token limit", "error", err)
-		return err
+		return badInput("Address validation failed")
 	}
 
-	poolLimitBig := new(big.Int)
-	if _, ok := poolLimitBig.SetString(poolLimit, 10); !ok {
-		return httputil.JSON(w, http.StatusInternalServerError, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid pool limit format",
-		})
-	}
-
-	userBalance, err := a.chainDataSource.TokenBalance(req.Context(), u.UserAddress, u.TokenAddress)
+	absoluteCredit, err := AbsoluteCredit(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, u)
 	if err != nil {
 		return err
 	}
 
-	remainingLimit := new(big.Int).Sub(poolLimitBig, userBalance)
-	a.logg.Debug("Pool balance calculation",
-		"poolLimit", poolLimitBig.String(),
-		"userBalance", userBalance.String(),
-		"remainingLimit", remainingLimit.String())
-
-	// Calculate absoluteCredit = min(userBalance, poolLimit - userBalance)
-	// Examples:
-	// - If user has 50 SRF and pool limit is 60: credit = min(50, 60-50) = min(50, 10) = 10
-	// - If user has 10 SRF and pool limit is 1000000: credit = min(10, 1000000-10) = min(10, 999990) = 10
-	// Your credit can't be higher than your current balance
-	absoluteCredit := new(big.Int)
-	if userBalance.Cmp(remainingLimit) <= 0 {
-		// User balance is smaller or equal, so credit = user balance
-		absoluteCredit.Set(userBalance)
-	} else {
-		// Remaining limit is smaller, so credit = remaining limit
-		absoluteCredit.Set(remainingLimit)
-	}
-
 	var absoluteCreditString string
 	if absoluteCredit.Sign() >= 0 {
 		absoluteCreditString = "+" + absoluteCredit.String()
@@ -526,10 +437,7 @@ func (a *API) aliasHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	}
 
 	if err := a.validator.Validate(r); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Alias validation failed",
-		})
+		return badInput("Alias validation failed")
 	}
 
 	aliasAddress, err := a.pgDataSource.ResolveAlias(req.Context(), r.Alias)
@@ -553,101 +461,16 @@ func (a *API) creditSendHandler(w http.ResponseWriter, req bunrouter.Request) er
 		FromToken:   req.Param("from"), // SAT
 		ToToken:     req.Param("to"),   // RAT
 	}
-	a.logg.Debug("Credit Send request", "pool", u.PoolAddress, "user", u.UserAddress, "from", u.FromToken, "to", u.ToToken)
+	a.logger(req).Debug("Credit Send request", "pool", u.PoolAddress, "user", u.UserAddress, "from", u.FromToken, "to", u.ToToken)
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Address validation failed",
-		})
-	}
-
-	swapRates, err := a.pgDataSource.PoolTokenSwapRates(req.Context(), u.PoolAddress, u.FromToken, u.ToToken)
-	if err != nil {
-		a.logg.Debug("Failed to get token swap rates", "error", err)
-		return err
+		return badInput("Address validation failed")
 	}
 
-	if swapRates == nil {
-		return httputil.JSON(w, http.StatusNotFound, api.ErrResponse{
-			Ok:          false,
-			Description: "Swap rates not found for the specified pool and tokens",
-		})
-	}
-
-	if swapRates.InRate == 0 {
-		swapRates.InRate = 10_000
-	}
-	if swapRates.OutRate == 0 {
-		swapRates.OutRate = 10_000
-	}
-
-	a.logg.Debug("Swap rates found", "inRate", swapRates.InRate, "outRate", swapRates.OutRate,
-		"inDecimals", swapRates.InDecimals, "outDecimals", swapRates.OutDecimals,
-		"inTokenLimit", swapRates.InTokenLimit, "outTokenLimit", swapRates.OutTokenLimit)
-
-	userInBalance, poolInBalance, poolOutBalance, err := a.chainDataSource.GetSwapBalances(
-		req.Context(),
-		u.UserAddress,
-		u.PoolAddress,
-		u.FromToken,
-		u.ToToken,
-	)
+	maxInSAT, maxInRAT, err := CreditSendLimits(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, u)
 	if err != nil {
 		return err
 	}
-	a.logg.Debug("Swap balances found", "userInBalance", userInBalance.String(),
-		"poolInBalance", poolInBalance.String(), "poolOutBalance", poolOutBalance.String())
-
-	inTokenLimit := new(big.Int)
-	if _, ok := inTokenLimit.SetString(swapRates.InTokenLimit, 10); !ok {
-		return httputil.JSON(w, http.StatusInternalServerError, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid token limit format",
-		})
-	}
-
-	outTokenLimit := new(big.Int)
-	if _, ok := outTokenLimit.SetString(swapRates.OutTokenLimit, 10); !ok {
-		return httputil.JSON(w, http.StatusInternalServerError, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid token limit format",
-		})
-	}
-
-	maxInSAT := a.chainDataSource.MaxSwapInput(
-		userInBalance,
-		inTokenLimit,
-		outTokenLimit,
-		poolInBalance,
-		poolOutBalance,
-		swapRates.InRate,
-		swapRates.OutRate,
-		swapRates.InDecimals,
-		swapRates.OutDecimals,
-	)
-
-	// maxInRAT = maxInSAT * (inRate / outRate) * (10^outDecimals / 10^inDecimals)
-	// This is the inverse of the MaxSwap calculation
-	bigInRate := new(big.Int).SetUint64(swapRates.InRate)
-	bigOutRate := new(big.Int).SetUint64(swapRates.OutRate)
-
-	pow10In := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(swapRates.InDecimals)), nil)
-	pow10Out := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(swapRates.OutDecimals)), nil)
-
-	numerator := new(big.Int).Mul(maxInSAT, bigInRate)
-	numerator.Mul(numerator, pow10Out)
-
-	denominator := new(big.Int).Mul(bigOutRate, pow10In)
-
-	var maxInRAT *big.Int
-	if denominator.Sign() == 0 {
-		maxInRAT = big.NewInt(0)
-	} else {
-		maxInRAT = new(big.Int).Div(numerator, denominator)
-	}
-
-	a.logg.Debug("Credit Send calculation", "maxInSAT", maxInSAT.String(), "maxInRAT", maxInRAT.String())
 
 	return httputil.JSON(w, http.StatusOK, api.OKResponse{
 		Ok:          true,
@@ -666,57 +489,22 @@ func (a *API) reverseQuoteHandler(w http.ResponseWriter, req bunrouter.Request)
 		ToToken:     req.Param("to"),
 		Amount:      req.Param("amount"),
 	}
-	a.logg.Debug("Reverse quote request", "pool", u.PoolAddress, "from", u.FromToken, "to", u.ToToken, "amount", u.Amount)
+	a.logger(req).Debug("Reverse quote request", "pool", u.PoolAddress, "from", u.FromToken, "to", u.ToToken, "amount", u.Amount)
 
 	if err := a.validator.Validate(u); err != nil {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Parameter validation failed",
-		})
+		return badInput("Parameter validation failed")
 	}
 
 	outputAmount := new(big.Int)
 	if _, ok := outputAmount.SetString(u.Amount, 10); !ok {
-		return httputil.JSON(w, http.StatusBadRequest, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid amount format",
-		})
+		return badInput("Invalid amount format")
 	}
 
-	swapRates, err := a.pgDataSource.PoolTokenSwapRates(req.Context(), u.PoolAddress, u.FromToken, u.ToToken)
+	inputAmount, err := ReverseQuote(req.Context(), a.logg, a.pgDataSource, u, outputAmount)
 	if err != nil {
-		a.logg.Debug("Failed to get token swap rates", "error", err)
 		return err
 	}
 
-	if swapRates == nil {
-		return httputil.JSON(w, http.StatusNotFound, api.ErrResponse{
-			Ok:          false,
-			Description: "Swap rates not found for the specified pool and tokens",
-		})
-	}
-
-	if swapRates.InRate == 0 {
-		swapRates.InRate = 10_000
-	}
-	if swapRates.OutRate == 0 {
-		swapRates.OutRate = 10_000
-	}
-
-	a.logg.Debug("Swap rates found", "inRate", swapRates.InRate, "outRate", swapRates.OutRate,
-		"inDecimals", swapRates.InDecimals, "outDecimals", swapRates.OutDecimals)
-
-	inputAmount := CalculateReverseQuote(outputAmount, swapRates.InRate, swapRates.OutRate, swapRates.InDecimals, swapRates.OutDecimals)
-
-	if inputAmount == nil {
-		return httputil.JSON(w, http.StatusInternalServerError, api.ErrResponse{
-			Ok:          false,
-			Description: "Invalid swap rate configuration",
-		})
-	}
-
-	a.logg.Debug("Reverse quote calculation", "outputAmount", outputAmount.String(), "inputAmount", inputAmount.String())
-
 	return httputil.JSON(w, http.StatusOK, api.OKResponse{
 		Ok:          true,
 		Description: "Required input amount for desired output",
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lmittmann/w3"
+	"github.com/uptrace/bunrouter"
+)
+
+// statusClientClosedRequest is the non-standard status (borrowed from nginx)
+// used when the caller went away before we could respond.
+const statusClientClosedRequest = 499
+
+// apiError is a handler error that maps to an HTTP status and a stable error
+// code. Any other error returned by a handler is classified by classifyError.
+type apiError struct {
+	status      int
+	code        string
+	description string
+	err         error
+}
+
+func (e *apiError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %v", e.description, e.err)
+	}
+	return e.description
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+func badInput(description string) error {
+	return &apiError{status: http.StatusBadRequest, code: api.ErrCodeBadInput, description: description}
+}
+
+func notFound(description string) error {
+	return &apiError{status: http.StatusNotFound, code: api.ErrCodeNotFound, description: description}
+}
+
+func unauthorized(code string, description string) error {
+	return &apiError{status: http.StatusUnauthorized, code: code, description: description}
+}
+
+func internalError(description string) error {
+	return &apiError{status: http.StatusInternalServerError, code: api.ErrCodeInternal, description: description}
+}
+
+// classifyError maps an error from the data layer to a failure class.
+func classifyError(err error) *apiError {
+	var (
+		apiErr   *apiError
+		batchErr w3.CallErrors
+		pgErr    *pgconn.PgError
+	)
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, context.Canceled):
+		return &apiError{status: statusClientClosedRequest, code: api.ErrCodeCancelled, description: "Request cancelled", err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &apiError{status: http.StatusGatewayTimeout, code: api.ErrCodeTimeout, description: "Request timed out", err: err}
+	case errors.As(err, &batchErr):
+		return &apiError{status: http.StatusBadGateway, code: api.ErrCodeUpstreamRPCReverted, description: "Upstream RPC call reverted", err: err}
+	case errors.Is(err, data.ErrRPCUnavailable):
+		return &apiError{status: http.StatusBadGateway, code: api.ErrCodeUpstreamRPCUnavailable, description: "Upstream RPC unavailable", err: err}
+	case errors.As(err, &pgErr):
+		return &apiError{status: http.StatusInternalServerError, code: api.ErrCodeDatabaseError, description: "Database query failed", err: err}
+	case isPgConnError(err):
+		return &apiError{status: http.StatusServiceUnavailable, code: api.ErrCodeDatabaseUnavailable, description: "Database unavailable", err: err}
+	default:
+		return &apiError{status: http.StatusInternalServerError, code: api.ErrCodeInternal, description: "Internal server error", err: err}
+	}
+}
+
+func isPgConnError(err error) bool {
+	return data.IsPgConnError(err) || pgconn.Timeout(err)
+}
+
+// errorMiddleware turns handler errors into a JSON error response, written by
+// the group's errWriter, so callers never receive an empty or non-JSON failure.
+func (a *API) errorMiddleware(write errWriter) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			err := next(w, req)
+			if err == nil {
+				return nil
+			}
+
+			apiErr := classifyError(err)
+			if apiErr.status >= http.StatusInternalServerError {
+				a.logger(req).Error("request failed", "route", req.Route(), "code", apiErr.code, "error", err)
+			} else {
+				a.logger(req).Debug("request rejected", "route", req.Route(), "code", apiErr.code, "error", err)
+			}
+
+			return write(w, req, apiErr)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lmittmann/w3"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "typed error",
+			err:        notFound("Pool not found"),
+			wantStatus: http.StatusNotFound,
+			wantCode:   api.ErrCodeNotFound,
+		},
+		{
+			name:       "deadline exceeded",
+			err:        fmt.Errorf("query: %w", context.DeadlineExceeded),
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   api.ErrCodeTimeout,
+		},
+		{
+			name:       "cancelled",
+			err:        context.Canceled,
+			wantStatus: statusClientClosedRequest,
+			wantCode:   api.ErrCodeCancelled,
+		},
+		{
+			name:       "rpc revert",
+			err:        w3.CallErrors{nil, errors.New("execution reverted")},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   api.ErrCodeUpstreamRPCReverted,
+		},
+		{
+			name:       "rpc down",
+			err:        fmt.Errorf("%w: %w", data.ErrRPCUnavailable, errors.New("connection refused")),
+			wantStatus: http.StatusBadGateway,
+			wantCode:   api.ErrCodeUpstreamRPCUnavailable,
+		},
+		{
+			name:       "sql error",
+			err:        &pgconn.PgError{Code: "42P01", Message: "relation does not exist"},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   api.ErrCodeDatabaseError,
+		},
+		{
+			name:       "unknown",
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   api.ErrCodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err)
+			if got.status != tt.wantStatus || got.code != tt.wantCode {
+				t.Errorf("classifyError() = %d %s, want %d %s", got.status, got.code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+const (
+	indexedBlockHeader = "X-Indexed-Block"
+	indexerLagHeader   = "X-Indexer-Lag-Blocks"
+)
+
+// freshness sets the indexer freshness headers and returns the indicator to
+// embed in DB backed responses. It is nil when the monitor is disabled or has
+// not sampled yet.
+func (a *API) freshness(w http.ResponseWriter) *api.Freshness {
+	if a.indexerMonitor == nil {
+		return nil
+	}
+
+	freshness := a.indexerMonitor.Freshness()
+	if freshness == nil {
+		return nil
+	}
+
+	w.Header().Set(indexedBlockHeader, strconv.FormatUint(freshness.IndexedBlock, 10))
+	w.Header().Set(indexerLagHeader, strconv.FormatUint(freshness.LagBlocks, 10))
+
+	return freshness
+}
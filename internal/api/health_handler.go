@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	readinessTimeout     = 5 * time.Second
+	defaultMaxHeadAge    = time.Minute
+	healthStatusOK       = "ok"
+	healthStatusDegraded = "degraded"
+)
+
+type readinessCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+func liveHandler(w http.ResponseWriter, _ bunrouter.Request) error {
+	return httputil.JSON(w, http.StatusOK, api.HealthResponse{
+		Ok:     true,
+		Status: healthStatusOK,
+	})
+}
+
+// readyHandler runs every dependency check concurrently and reports each
+// check's result and latency. Any failing check fails readiness.
+func (a *API) readyHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := a.readinessChecks()
+	results := make([]*api.HealthCheck, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			startedAt := time.Now()
+			detail, err := check.run(ctx)
+			results[i] = &api.HealthCheck{
+				Name:      check.name,
+				Ok:        err == nil,
+				LatencyMs: float64(time.Since(startedAt).Microseconds()) / 1000,
+				Detail:    detail,
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp := api.HealthResponse{
+		Ok:     true,
+		Status: healthStatusOK,
+		Checks: results,
+	}
+	for _, result := range results {
+		if !result.Ok {
+			resp.Ok = false
+			resp.Status = healthStatusDegraded
+			a.logger(req).Warn("readiness check failed", "check", result.Name, "error", result.Error)
+		}
+	}
+
+	status := http.StatusOK
+	if !resp.Ok {
+		status = http.StatusServiceUnavailable
+	}
+
+	return httputil.JSON(w, status, resp)
+}
+
+func (a *API) readinessChecks() []readinessCheck {
+	return []readinessCheck{
+		{
+			name: "postgres",
+			run: func(ctx context.Context) (string, error) {
+				return "", a.pgDataSource.Ping(ctx)
+			},
+		},
+		{
+			name: "rpc_chain_id",
+			run: func(ctx context.Context) (string, error) {
+				chainID, err := a.chainDataSource.ChainID(ctx)
+				if err != nil {
+					return "", err
+				}
+				if expected := a.chainDataSource.ExpectedChainID(); chainID != uint64(expected) {
+					return "", fmt.Errorf("rpc chain id %d does not match configured chain id %d", chainID, expected)
+				}
+				return fmt.Sprintf("chain id %d", chainID), nil
+			},
+		},
+		{
+			name: "rpc_head",
+			run: func(ctx context.Context) (string, error) {
+				blockNumber, blockTime, err := a.chainDataSource.HeadBlock(ctx)
+				if err != nil {
+					return "", err
+				}
+				age := time.Since(blockTime).Truncate(time.Second)
+				detail := fmt.Sprintf("block %d, %s old", blockNumber, age)
+				if age > a.maxHeadAge {
+					return detail, fmt.Errorf("head block is older than %s", a.maxHeadAge)
+				}
+				return detail, nil
+			},
+		},
+	}
+}
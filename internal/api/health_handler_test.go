@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/internal/rpctest"
+	model "github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestLiveHandler(t *testing.T) {
+	a, err := New(APIOpts{Logg: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		rpcChainID uint64
+		headAge    time.Duration
+		pgDown     bool
+		wantStatus int
+		wantFailed string
+	}{
+		{name: "ready", rpcChainID: 1, headAge: 10 * time.Second, wantStatus: http.StatusOK},
+		{name: "chain id mismatch", rpcChainID: 2, headAge: 10 * time.Second, wantStatus: http.StatusServiceUnavailable, wantFailed: "rpc_chain_id"},
+		{name: "stale head", rpcChainID: 1, headAge: 2 * time.Minute, wantStatus: http.StatusServiceUnavailable, wantFailed: "rpc_head"},
+		{name: "postgres down", rpcChainID: 1, headAge: 10 * time.Second, pgDown: true, wantStatus: http.StatusServiceUnavailable, wantFailed: "postgres"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logg := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			rpc := rpctest.NewServer(t)
+			rpc.SetResult("eth_chainId", hexutil.Uint64(tt.rpcChainID))
+			rpc.SetResult("eth_getBlockByNumber", &types.Header{
+				Number:     big.NewInt(100),
+				Time:       uint64(time.Now().Add(-tt.headAge).Unix()),
+				Difficulty: new(big.Int),
+			})
+
+			pgServer, pg := pgtest.NewServer(t, "../../queries.sql")
+			if tt.pgDown {
+				pgServer.Close()
+			}
+
+			a, err := New(APIOpts{
+				Logg:            logg,
+				PgDataSource:    pg,
+				ChainDataSource: data.NewChainProvider(data.ChainOpts{ChainID: 1, RPCEndpoint: rpc.URL(), Logg: logg}),
+				MaxHeadAge:      time.Minute,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			a.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var resp model.HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Checks) != 3 {
+				t.Fatalf("checks = %d, want 3", len(resp.Checks))
+			}
+			for _, check := range resp.Checks {
+				if wantOk := check.Name != tt.wantFailed; check.Ok != wantOk {
+					t.Errorf("check %s ok = %t, want %t (%s)", check.Name, check.Ok, wantOk, check.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	includeEmptyQueryParam = "include_empty"
+	sortQueryParam         = "sort"
+)
+
+// HoldingsQuery holds the list options shared by the holdings and pool swap
+// from routes.
+type HoldingsQuery struct {
+	IncludeEmpty bool
+	Sort         string `validate:"omitempty,oneof=stable recent balance symbol"`
+}
+
+func (a *API) holdingsQuery(req bunrouter.Request) (HoldingsQuery, error) {
+	var q HoldingsQuery
+
+	if v := req.URL.Query().Get(includeEmptyQueryParam); v != "" {
+		includeEmpty, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, badInput("Invalid include_empty value")
+		}
+		q.IncludeEmpty = includeEmpty
+	}
+
+	q.Sort = req.URL.Query().Get(sortQueryParam)
+	if err := a.validator.Validate(q); err != nil {
+		return q, badInput("Invalid sort order")
+	}
+
+	return q, nil
+}
+
+// sortHoldings orders holdings in place. Ties keep the query's order, which is
+// also what an empty order returns.
+func sortHoldings(holdings []*api.TokenHoldings, order string) {
+	var cmp func(a, b *api.TokenHoldings) int
+
+	switch order {
+	case api.HoldingsSortStable:
+		cmp = func(a, b *api.TokenHoldings) int {
+			if c := a.StableRank - b.StableRank; c != 0 {
+				return c
+			}
+			return b.LastInteraction.Compare(a.LastInteraction)
+		}
+	case api.HoldingsSortRecent:
+		cmp = func(a, b *api.TokenHoldings) int {
+			return b.LastInteraction.Compare(a.LastInteraction)
+		}
+	case api.HoldingsSortBalance:
+		cmp = func(a, b *api.TokenHoldings) int {
+			return compareBalances(b, a)
+		}
+	case api.HoldingsSortSymbol:
+		cmp = func(a, b *api.TokenHoldings) int {
+			return strings.Compare(strings.ToLower(a.TokenSymbol), strings.ToLower(b.TokenSymbol))
+		}
+	default:
+		return
+	}
+
+	slices.SortStableFunc(holdings, cmp)
+}
+
+// compareBalances compares balances in whole tokens by scaling each balance
+// by the other token's decimals. Unparsable balances (e.g. partial results)
+// sort as zero.
+func compareBalances(a, b *api.TokenHoldings) int {
+	aBalance, aDecimals := parseBalance(a)
+	bBalance, bDecimals := parseBalance(b)
+
+	aScaled := new(big.Int).Mul(aBalance, new(big.Int).Exp(big.NewInt(10), big.NewInt(bDecimals), nil))
+	bScaled := new(big.Int).Mul(bBalance, new(big.Int).Exp(big.NewInt(10), big.NewInt(aDecimals), nil))
+
+	return aScaled.Cmp(bScaled)
+}
+
+func parseBalance(h *api.TokenHoldings) (*big.Int, int64) {
+	balance, ok := new(big.Int).SetString(h.Balance, 10)
+	if !ok {
+		balance = new(big.Int)
+	}
+
+	decimals, err := strconv.ParseInt(h.TokenDecimals, 10, 64)
+	if err != nil {
+		decimals = 0
+	}
+
+	return balance, decimals
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestSortHoldings(t *testing.T) {
+	now := time.Now()
+	holdings := func() []*api.TokenHoldings {
+		return []*api.TokenHoldings{
+			{TokenSymbol: "srf", TokenDecimals: "6", Balance: "5000000", StableRank: 4, LastInteraction: now.Add(-time.Hour)},
+			{TokenSymbol: "cUSD", TokenDecimals: "18", Balance: "2000000000000000000", StableRank: 1, LastInteraction: now.Add(-48 * time.Hour)},
+			{TokenSymbol: "MBAO", TokenDecimals: "6", Balance: "0", StableRank: 4, LastInteraction: now, Empty: true},
+			{TokenSymbol: "cKES", TokenDecimals: "6", Balance: "", StableRank: 3, LastInteraction: now.Add(-2 * time.Hour)},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		order string
+		want  []string
+	}{
+		{name: "default keeps order", order: "", want: []string{"srf", "cUSD", "MBAO", "cKES"}},
+		{name: "stable", order: api.HoldingsSortStable, want: []string{"cUSD", "cKES", "MBAO", "srf"}},
+		{name: "recent", order: api.HoldingsSortRecent, want: []string{"MBAO", "srf", "cKES", "cUSD"}},
+		{name: "balance in whole tokens", order: api.HoldingsSortBalance, want: []string{"srf", "cUSD", "MBAO", "cKES"}},
+		{name: "symbol ignores case", order: api.HoldingsSortSymbol, want: []string{"cKES", "cUSD", "MBAO", "srf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := holdings()
+			sortHoldings(h, tt.order)
+
+			got := make([]string, len(h))
+			for i, holding := range h {
+				got[i] = holding.TokenSymbol
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sortHoldings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/uptrace/bunrouter"
+)
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// requestMetricsMiddleware records request counts, latencies and in-flight
+// requests per route. Metrics are labelled with the route template (e.g.
+// /api/v1/holdings/:address) to keep label cardinality bounded.
+func requestMetricsMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		route := req.Route()
+		startedAt := time.Now()
+
+		inFlight := metrics.GetOrCreateGauge(fmt.Sprintf(`http_requests_in_flight{route=%q}`, route), nil)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		err := next(rec, req)
+
+		status := rec.status
+		if status == 0 {
+			if err != nil {
+				status = http.StatusInternalServerError
+			} else {
+				status = http.StatusOK
+			}
+		}
+
+		metrics.GetOrCreateCounter(fmt.Sprintf(`http_requests_total{route=%q,method=%q,status="%d"}`, route, req.Method, status)).Inc()
+		metrics.GetOrCreateHistogram(fmt.Sprintf(`http_request_duration_seconds{route=%q}`, route)).UpdateDuration(startedAt)
+
+		return err
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestRequestMetricsMiddleware(t *testing.T) {
+	a, token := newTestAPI(t, APIOpts{EnableMetrics: true})
+
+	requestsTotal := func(status int) uint64 {
+		return metrics.GetOrCreateCounter(fmt.Sprintf(`http_requests_total{route="/api/v1/alias/:alias",method="GET",status="%d"}`, status)).Get()
+	}
+	okBefore, unauthorizedBefore := requestsTotal(http.StatusOK), requestsTotal(http.StatusUnauthorized)
+
+	for _, auth := range []string{"Bearer " + token, "Bearer " + token, ""} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alias/alice", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		a.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := requestsTotal(http.StatusOK) - okBefore; got != 2 {
+		t.Errorf("200 requests = %d, want 2", got)
+	}
+	// Errors are written by the error middleware inside the metrics one.
+	if got := requestsTotal(http.StatusUnauthorized) - unauthorizedBefore; got != 1 {
+		t.Errorf("401 requests = %d, want 1", got)
+	}
+
+	var exposed bytes.Buffer
+	metrics.WritePrometheus(&exposed, false)
+	if strings.Contains(exposed.String(), "/alias/alice") {
+		t.Error("metrics are labelled with the raw path instead of the route template")
+	}
+	if !strings.Contains(exposed.String(), `http_request_duration_seconds_bucket{route="/api/v1/alias/:alias"`) {
+		t.Error("no latency histogram for the route template")
+	}
+}
+
+func TestStatusRecorderResponseController(t *testing.T) {
+	router := bunrouter.New()
+	router.Use(requestMetricsMiddleware).GET("/stream", func(w http.ResponseWriter, req bunrouter.Request) error {
+		// Both need the underlying writer, reached through Unwrap.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "first"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	before := metrics.GetOrCreateCounter(`http_requests_total{route="/stream",method="GET",status="200"}`).Get()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "first" {
+		t.Errorf("body = %q, want %q", body, "first")
+	}
+	if got := metrics.GetOrCreateCounter(`http_requests_total{route="/stream",method="GET",status="200"}`).Get() - before; got != 1 {
+		t.Errorf("200 requests = %d, want 1", got)
+	}
+}
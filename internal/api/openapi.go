@@ -0,0 +1,20 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/uptrace/bunrouter"
+)
+
+// openAPISpec documents every route. It is served as is and checked against
+// the handlers by the contract tests, so update both together.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func openAPIHandler(w http.ResponseWriter, _ bunrouter.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(openAPISpec)
+	return err
+}
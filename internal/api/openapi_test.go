@@ -0,0 +1,494 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	model "github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestOpenAPIContract(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	a, token := newTestAPI(t, APIOpts{})
+
+	tests := []contractCase{
+		{name: "live", path: "/health/live", url: "/health/live", wantStatus: http.StatusOK},
+		{name: "openapi", path: "/openapi.json", url: "/openapi.json", wantStatus: http.StatusOK},
+		{name: "missing token", path: "/api/v1/pool/top", url: "/api/v1/pool/top", wantStatus: http.StatusUnauthorized},
+		{name: "malformed token", path: "/api/v1/pool/top", url: "/api/v1/pool/top", token: "not-a-jwt", wantStatus: http.StatusBadRequest},
+		{name: "alias", path: "/api/v1/alias/{alias}", url: "/api/v1/alias/alice", token: token, wantStatus: http.StatusOK},
+		{name: "invalid sort", path: "/api/v1/holdings/{address}", url: "/api/v1/holdings/" + testAddress + "?sort=size", token: token, wantStatus: http.StatusBadRequest},
+		{name: "invalid include_empty", path: "/api/v2/holdings/{address}", url: "/api/v2/holdings/" + testAddress + "?include_empty=maybe", token: token, wantStatus: http.StatusBadRequest},
+		{name: "portfolio value invalid reference", path: "/api/v1/holdings/{address}/value/{token}", url: "/api/v1/holdings/" + testAddress + "/value/" + testInvalidAddress, token: token, wantStatus: http.StatusBadRequest},
+		{name: "statement without from", path: "/api/v1/statement/{address}", url: "/api/v1/statement/" + testAddress, token: token, wantStatus: http.StatusBadRequest},
+		{name: "statement range too long", path: "/api/v2/statements/{address}", url: "/api/v2/statements/" + testAddress + "?from=2023-01-01&to=2025-01-01", token: token, wantStatus: http.StatusBadRequest},
+		{name: "statement bad format", path: "/api/v1/statement/{address}", url: "/api/v1/statement/" + testAddress + "?from=2025-01-01&format=xlsx", token: token, wantStatus: http.StatusBadRequest},
+		{name: "token search without query", path: "/api/v1/token/search", url: "/api/v1/token/search", token: token, wantStatus: http.StatusBadRequest},
+		{name: "token search invalid pool", path: "/api/v1/token/search", url: "/api/v1/token/search?q=srf&pool=" + testInvalidAddress, token: token, wantStatus: http.StatusBadRequest},
+		{name: "token search registry not cached", path: "/api/v1/token/search", url: "/api/v1/token/search?q=srf&registry=" + testAddress, token: token, wantStatus: http.StatusNotFound},
+		{name: "v2 token search limit", path: "/api/v2/tokens/search", url: "/api/v2/tokens/search?q=srf&limit=500", token: token, wantStatus: http.StatusBadRequest},
+		{name: "registry not cached", path: "/api/v1/registry/{address}/tokens", url: "/api/v1/registry/" + testAddress + "/tokens", token: token, wantStatus: http.StatusNotFound},
+		{name: "v2 registry not cached", path: "/api/v2/registries/{address}/tokens", url: "/api/v2/registries/" + testAddress + "/tokens", token: token, wantStatus: http.StatusNotFound},
+		{name: "pool analytics volume without reference", path: "/api/v1/pool/analytics", url: "/api/v1/pool/analytics?metric=volume", token: token, wantStatus: http.StatusBadRequest},
+		{name: "pool search without query", path: "/api/v1/pool/search", url: "/api/v1/pool/search", token: token, wantStatus: http.StatusBadRequest},
+		{name: "v2 pool search limit", path: "/api/v2/pools/search", url: "/api/v2/pools/search?q=kbr&limit=500", token: token, wantStatus: http.StatusBadRequest},
+		{name: "pool analytics window too long", path: "/api/v1/pool/analytics", url: "/api/v1/pool/analytics?days=365", token: token, wantStatus: http.StatusBadRequest},
+		{name: "v2 pool analytics invalid metric", path: "/api/v2/pools/analytics", url: "/api/v2/pools/analytics?metric=tvl", token: token, wantStatus: http.StatusBadRequest},
+		{name: "v2 missing token", path: "/api/v2/pools/top", url: "/api/v2/pools/top", wantStatus: http.StatusUnauthorized},
+		{name: "v2 alias", path: "/api/v2/aliases/{alias}", url: "/api/v2/aliases/alice", token: token, wantStatus: http.StatusOK},
+		{name: "v2 quote bad amount", path: "/api/v2/pools/{pool}/quote/{from}/{to}/{amount}", url: "/api/v2/pools/" + testAddress + "/quote/" + testAddress + "/" + testAddress + "/abc", token: token, wantStatus: http.StatusBadRequest},
+		{name: "reverse quote bad amount", path: "/api/v1/pool/reverse-quote/{pool}/{from}/{to}/{amount}", url: "/api/v1/pool/reverse-quote/" + testAddress + "/" + testAddress + "/" + testAddress + "/abc", token: token, wantStatus: http.StatusBadRequest},
+	}
+
+	// Every route that validates addresses must reject a non checksummed one
+	// with a documented 400, in its version's error format.
+	for path := range spec.Paths {
+		if !isVersionedPath(path) || !strings.Contains(path, "{") ||
+			strings.Contains(path, "{symbol}") || strings.Contains(path, "{alias}") {
+			continue
+		}
+		tests = append(tests, contractCase{
+			name:       "invalid address " + path,
+			path:       path,
+			url:        fillPathParams(path, testInvalidAddress),
+			token:      token,
+			wantStatus: http.StatusBadRequest,
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, spec, a)
+		})
+	}
+}
+
+// TestOpenAPIPathsRouted makes sure every documented API path is routed and
+// authenticated, so a renamed or removed route fails here.
+func TestOpenAPIPathsRouted(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	a, _ := newTestAPI(t, APIOpts{})
+
+	for path := range spec.Paths {
+		if !isVersionedPath(path) {
+			continue
+		}
+		t.Run(path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			a.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fillPathParams(path, testAddress), nil))
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d (is the route registered?)", rec.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+// TestOpenAPIRoutesDocumented makes sure every routed API path is in the
+// spec, so a new route fails here until it is documented.
+func TestOpenAPIRoutesDocumented(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	a, _ := newTestAPI(t, APIOpts{})
+
+	versions := map[string][]route{
+		apiVersion:   a.v1Routes(),
+		apiV2Version: a.v2Routes(),
+	}
+	for prefix, routes := range versions {
+		for _, r := range routes {
+			path := prefix + routeParamPattern.ReplaceAllString(r.path, "{$1}")
+			if _, ok := spec.Paths[path]["get"]; !ok {
+				t.Errorf("GET %s is routed but not documented", path)
+			}
+		}
+	}
+}
+
+// TestOpenAPIContractSuccess serves the Postgres backed routes from a fake
+// database and checks their 200 bodies against the spec. Routes that read
+// balances or limits from the chain are left to their unit tests.
+func TestOpenAPIContractSuccess(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	fake, pg := pgtest.NewServer(t, "../../queries.sql")
+
+	registryCache := newTestRegistryCache(t, &data.RegistryTokens{
+		Registry:  testAddress,
+		Tokens:    []*model.TokenDetails{{TokenAddress: testAddress, TokenSymbol: "SRF", TokenDecimals: 6, SinkAddress: testAddress, TokenName: "Sarafu"}},
+		UpdatedAt: time.Now().UTC(),
+	})
+
+	a, token := newTestAPI(t, APIOpts{PgDataSource: pg, RegistryCache: registryCache})
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	pool := &model.PoolDetails{PoolName: "Kibera Pool", PoolSymbol: "KBR", PoolContractAdrress: testAddress, LimiterAddress: testAddress, VoucherRegistry: testAddress}
+	holding := &model.TokenHoldings{TokenAddress: testAddress, TokenSymbol: "USDm", TokenDecimals: "6", LastInteraction: today}
+
+	fake.Set("last-10-tx", model.Last10TxResponse{}, &model.Last10TxResponse{
+		Sender: testAddress, Recipient: testAddress, TransferValue: "1000000", ContractAddress: testAddress,
+		TxHash: "0x01", DateBlock: today, TokenSymbol: "SRF", TokenDecimals: "6", Success: true,
+	})
+	fake.Set("token-details", model.TokenDetails{}, &model.TokenDetails{TokenAddress: testAddress, TokenSymbol: "SRF", TokenDecimals: 6, SinkAddress: testAddress, TokenName: "Sarafu"})
+	fake.Set("pool-details", model.PoolDetails{}, pool)
+	fake.Set("pool-reverse-details", model.PoolDetails{}, pool)
+	fake.Set("top-active-pools", model.PoolDetails{}, pool)
+	fake.Set("pool-token-allowed", struct {
+		IsAllowed bool `db:"is_allowed"`
+	}{}, struct {
+		IsAllowed bool `db:"is_allowed"`
+	}{IsAllowed: true})
+	fake.Set("pool-allowed-stables", model.TokenHoldings{}, holding)
+	fake.Set("pool-token-swap-rates", model.TokenSwapRates{}, &model.TokenSwapRates{InRate: 10_000, OutRate: 10_000, InDecimals: 6, OutDecimals: 6, InTokenLimit: "1000", OutTokenLimit: "1000"})
+	fake.Set("token-search-candidates", model.TokenMatch{}, &model.TokenMatch{TokenAddress: testAddress, TokenSymbol: "SRF", TokenName: "Sarafu", TokenDecimals: 6, Activity: 3})
+	fake.Set("pool-search-candidates", model.PoolMatch{}, &model.PoolMatch{
+		PoolAddress: testAddress, PoolName: "Kibera Pool", PoolSymbol: "KBR", LimiterAddress: testAddress, VoucherRegistry: testAddress,
+		Activity: 2,
+	})
+	fake.Set("pool-activity-daily", model.PoolActivityRow{}, &model.PoolActivityRow{
+		PoolAddress: testAddress, PoolName: "Kibera Pool", PoolSymbol: "KBR", Day: today,
+		TokenAddress: testAddress, TokenSymbol: "SRF", TokenDecimals: 6, SwapCount: 2, Volume: "2000000",
+	})
+	fake.Set("pool-activity-users", model.PoolActivityUsers{},
+		&model.PoolActivityUsers{PoolAddress: testAddress, Day: &today, UniqueUsers: 1},
+		&model.PoolActivityUsers{PoolAddress: testAddress, UniqueUsers: 1},
+	)
+	fake.Set("token-valuation-rates", model.TokenValuationRate{})
+
+	tests := []contractCase{
+		{name: "last 10 transfers", path: "/api/v1/transfers/last10/{address}", url: "/api/v1/transfers/last10/" + testAddress},
+		{name: "v2 transfers", path: "/api/v2/transfers/{address}", url: "/api/v2/transfers/" + testAddress},
+		{name: "token details", path: "/api/v1/token/{address}", url: "/api/v1/token/" + testAddress},
+		{name: "v2 token", path: "/api/v2/tokens/{address}", url: "/api/v2/tokens/" + testAddress},
+		{name: "registry tokens", path: "/api/v1/registry/{address}/tokens", url: "/api/v1/registry/" + testAddress + "/tokens"},
+		{name: "v2 registry tokens", path: "/api/v2/registries/{address}/tokens", url: "/api/v2/registries/" + testAddress + "/tokens"},
+		{name: "pool details", path: "/api/v1/pool/{address}", url: "/api/v1/pool/" + testAddress},
+		{name: "v2 pool", path: "/api/v2/pools/{pool}", url: "/api/v2/pools/" + testAddress},
+		{name: "pool by symbol", path: "/api/v1/pool/reverse/{symbol}", url: "/api/v1/pool/reverse/KBR"},
+		{name: "v2 pool by symbol", path: "/api/v2/pools/symbol/{symbol}", url: "/api/v2/pools/symbol/KBR"},
+		{name: "top pools", path: "/api/v1/pool/top", url: "/api/v1/pool/top"},
+		{name: "v2 top pools", path: "/api/v2/pools/top", url: "/api/v2/pools/top"},
+		{name: "swap from check", path: "/api/v1/pool/{pool}/check/{address}", url: "/api/v1/pool/" + testAddress + "/check/" + testAddress},
+		{name: "v2 swap from allowed", path: "/api/v2/pools/{pool}/swap-from-allowed/{token}", url: "/api/v2/pools/" + testAddress + "/swap-from-allowed/" + testAddress},
+		{name: "swap to stables", path: "/api/v1/pool/{pool}/to/", url: "/api/v1/pool/" + testAddress + "/to/?stables=true"},
+		{name: "v2 swap to stables", path: "/api/v2/pools/{pool}/swap-to", url: "/api/v2/pools/" + testAddress + "/swap-to?stables=true"},
+		{name: "reverse quote", path: "/api/v1/pool/reverse-quote/{pool}/{from}/{to}/{amount}", url: "/api/v1/pool/reverse-quote/" + testAddress + "/" + testAddress + "/" + testAddress + "/1000"},
+		{name: "v2 quote", path: "/api/v2/pools/{pool}/quote/{from}/{to}/{amount}", url: "/api/v2/pools/" + testAddress + "/quote/" + testAddress + "/" + testAddress + "/1000"},
+		{name: "token search", path: "/api/v1/token/search", url: "/api/v1/token/search?q=srf"},
+		{name: "token search by registry", path: "/api/v1/token/search", url: "/api/v1/token/search?q=srf&registry=" + testAddress},
+		{name: "v2 token search", path: "/api/v2/tokens/search", url: "/api/v2/tokens/search?q=srf"},
+		{name: "pool search", path: "/api/v1/pool/search", url: "/api/v1/pool/search?q=kbr"},
+		{name: "v2 pool search", path: "/api/v2/pools/search", url: "/api/v2/pools/search?q=kbr"},
+		{name: "pool analytics", path: "/api/v1/pool/analytics", url: "/api/v1/pool/analytics?metric=volume&reference=" + testAddress},
+		{name: "v2 pool analytics", path: "/api/v2/pools/analytics", url: "/api/v2/pools/analytics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.token = token
+			tt.wantStatus = http.StatusOK
+			tt.run(t, spec, a)
+		})
+	}
+}
+
+// TestOpenAPIModels checks that the documented schemas list exactly the JSON
+// fields of the models in pkg/api, with non omitempty fields required.
+func TestOpenAPIModels(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+
+	models := map[string]any{
+		"ErrResponse":        model.ErrResponse{},
+		"Freshness":          model.Freshness{},
+		"HealthResponse":     model.HealthResponse{},
+		"HealthCheck":        model.HealthCheck{},
+		"Last10TxResponse":   model.Last10TxResponse{},
+		"TokenHoldings":      model.TokenHoldings{},
+		"TokenDetails":       model.TokenDetails{},
+		"PoolDetails":        model.PoolDetails{},
+		"HoldingValue":       model.HoldingValue{},
+		"PortfolioValuation": model.PortfolioValuation{},
+		"StatementEntry":     model.StatementEntry{},
+		"TokenMatch":         model.TokenMatch{},
+		"PoolMatch":          model.PoolMatch{},
+		"PoolState":          model.PoolState{},
+		"PoolTokenState":     model.PoolTokenState{},
+		"PoolAnalytics":      model.PoolAnalytics{},
+		"PoolActivity":       model.PoolActivity{},
+		"PoolTokenVolume":    model.PoolTokenVolume{},
+		"PoolActivityDay":    model.PoolActivityDay{},
+		"V2ErrResponse":      model.V2ErrResponse{},
+		"V2Error":            model.V2Error{},
+		"V2Transfer":         model.V2Transfer{},
+		"V2TokenBalance":     model.V2TokenBalance{},
+		"V2Token":            model.V2Token{},
+		"V2Pool":             model.V2Pool{},
+		"V2SwapAllowed":      model.V2SwapAllowed{},
+		"V2SwapLimit":        model.V2SwapLimit{},
+		"V2CreditSend":       model.V2CreditSend{},
+		"V2Quote":            model.V2Quote{},
+		"V2Credit":           model.V2Credit{},
+		"V2Alias":            model.V2Alias{},
+		"V2HoldingValue":     model.V2HoldingValue{},
+		"V2PortfolioValue":   model.V2PortfolioValue{},
+		"V2TokenMatch":       model.V2TokenMatch{},
+		"V2PoolMatch":        model.V2PoolMatch{},
+		"V2RegistryToken":    model.V2RegistryToken{},
+		"V2PoolState":        model.V2PoolState{},
+		"V2PoolTokenState":   model.V2PoolTokenState{},
+		"V2PoolAnalytics":    model.V2PoolAnalytics{},
+		"V2ReferenceToken":   model.V2ReferenceToken{},
+		"V2PoolActivity":     model.V2PoolActivity{},
+		"V2PoolTokenVolume":  model.V2PoolTokenVolume{},
+		"V2PoolActivityDay":  model.V2PoolActivityDay{},
+		"V2RegistryTokens":   model.V2RegistryTokens{},
+	}
+
+	for name, m := range models {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("schema %s is missing", name)
+			}
+
+			var fields, required []string
+			typ := reflect.TypeOf(m)
+			for i := range typ.NumField() {
+				tag, opts, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+				if tag == "" || tag == "-" {
+					continue
+				}
+				fields = append(fields, tag)
+				if !strings.Contains(opts, "omitempty") {
+					required = append(required, tag)
+				}
+			}
+
+			properties, _ := schema["properties"].(map[string]any)
+			var documented []string
+			for property := range properties {
+				documented = append(documented, property)
+			}
+
+			slices.Sort(fields)
+			slices.Sort(documented)
+			if !slices.Equal(fields, documented) {
+				t.Errorf("properties = %v, model fields = %v", documented, fields)
+			}
+
+			var documentedRequired []string
+			for _, r := range schema["required"].([]any) {
+				documentedRequired = append(documentedRequired, r.(string))
+			}
+			slices.Sort(required)
+			slices.Sort(documentedRequired)
+			if !slices.Equal(required, documentedRequired) {
+				t.Errorf("required = %v, model requires %v", documentedRequired, required)
+			}
+		})
+	}
+}
+
+type contractCase struct {
+	name       string
+	path       string
+	url        string
+	token      string
+	wantStatus int
+}
+
+// run requests tt.url and checks the status and that the body matches the
+// documented response schema.
+func (tt contractCase) run(t *testing.T, spec *openAPIDoc, a *API) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+	if tt.token != "" {
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+
+	if rec.Code != tt.wantStatus {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body.String())
+	}
+
+	schema, err := spec.responseSchema(tt.path, rec.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if err := spec.validate(schema, body, "body"); err != nil {
+		t.Errorf("response does not match the spec: %v\nbody %s", err, rec.Body.String())
+	}
+}
+
+type openAPIDoc struct {
+	Paths      map[string]map[string]map[string]any `json:"paths"`
+	Components struct {
+		Schemas   map[string]map[string]any `json:"schemas"`
+		Responses map[string]map[string]any `json:"responses"`
+	} `json:"components"`
+}
+
+func loadOpenAPISpec(t *testing.T) *openAPIDoc {
+	t.Helper()
+
+	var spec openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return &spec
+}
+
+func isVersionedPath(path string) bool {
+	return strings.HasPrefix(path, apiVersion+"/") || strings.HasPrefix(path, apiV2Version+"/")
+}
+
+var (
+	pathParamPattern  = regexp.MustCompile(`\{[a-z]+\}`)
+	routeParamPattern = regexp.MustCompile(`:([a-z]+)`)
+)
+
+func fillPathParams(path string, address string) string {
+	return pathParamPattern.ReplaceAllStringFunc(path, func(param string) string {
+		switch param {
+		case "{symbol}":
+			return "SRF"
+		case "{alias}":
+			return "alice"
+		case "{amount}":
+			return "1000"
+		default:
+			return address
+		}
+	})
+}
+
+func (spec *openAPIDoc) responseSchema(path string, status int) (map[string]any, error) {
+	op, ok := spec.Paths[path]["get"]
+	if !ok {
+		return nil, fmt.Errorf("GET %s is not documented", path)
+	}
+
+	responses, _ := op["responses"].(map[string]any)
+	response, ok := responses[strconv.Itoa(status)].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("GET %s does not document status %d", path, status)
+	}
+	if ref, ok := response["$ref"].(string); ok {
+		response = spec.Components.Responses[strings.TrimPrefix(ref, "#/components/responses/")]
+	}
+
+	schema, ok := response["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("GET %s status %d has no JSON schema", path, status)
+	}
+	return schema, nil
+}
+
+// validate checks v against the subset of OpenAPI schema keywords used by
+// openapi.json. Undocumented object properties are rejected unless
+// additionalProperties is allowed.
+func (spec *openAPIDoc) validate(schema map[string]any, v any, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, ok := spec.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, ref)
+		}
+		return spec.validate(resolved, v, at)
+	}
+
+	if v == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, s := range allOf {
+			if err := spec.validate(s.(map[string]any), v, at); err != nil {
+				return err
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", at, v, enum)
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", at, v)
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if _, ok := obj[r.(string)]; !ok {
+					return fmt.Errorf("%s: missing required property %s", at, r)
+				}
+			}
+		}
+		for key, value := range obj {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				if properties != nil && schema["additionalProperties"] != true {
+					return fmt.Errorf("%s: undocumented property %s", at, key)
+				}
+				continue
+			}
+			if err := spec.validate(property, value, at+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", at, v)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range arr {
+			if err := spec.validate(items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", at, v)
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			return fmt.Errorf("%s: %q does not match %s", at, s, pattern)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: want integer, got %v", at, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: want number, got %T", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %T", at, v)
+		}
+	}
+
+	return nil
+}
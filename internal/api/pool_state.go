@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+func (a *API) poolStateHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	poolState, err := PoolState(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, r.Address)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, http.StatusOK, api.OKResponse{
+		Ok:          true,
+		Description: "Pool state",
+		Result: map[string]any{
+			"poolState": poolState,
+		},
+		Freshness: a.freshness(w),
+	})
+}
+
+// PoolState returns every token allowed in the pool with the pool's on-chain
+// balance, limit, exchange rate and remaining capacity. Balances are fetched
+// in one balance scanner call, limits missing from the pool router are read
+// from the pool's limiter in one batch. A token whose limit can't be read
+// from the limiter has the limit source none.
+func PoolState(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, chain *data.Chain, poolAddress string) (*api.PoolState, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	poolDetails, err := pg.PoolDetails(ctx, poolAddress)
+	if err != nil {
+		return nil, err
+	}
+	if poolDetails == nil {
+		return nil, notFound("Pool not found")
+	}
+
+	tokens, err := pg.PoolTokenState(ctx, poolAddress)
+	if err != nil {
+		logg.Debug("Failed to get pool token state", "error", err)
+		return nil, err
+	}
+
+	tokenAddresses := make([]string, len(tokens))
+	var missingLimits []string
+	for i, t := range tokens {
+		tokenAddresses[i] = t.TokenAddress
+		if t.Limit == "" {
+			missingLimits = append(missingLimits, t.TokenAddress)
+		}
+	}
+
+	balances, err := chain.TokenBalances(ctx, poolAddress, tokenAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	var limiterLimits map[string]*big.Int
+	if len(missingLimits) > 0 && hasLimiter(poolDetails.LimiterAddress) {
+		limits, err := chain.PoolTokenLimits(ctx, poolDetails.LimiterAddress, poolAddress, missingLimits)
+		if err != nil {
+			return nil, err
+		}
+		limiterLimits = make(map[string]*big.Int, len(limits))
+		for i, limit := range limits {
+			limiterLimits[missingLimits[i]] = limit
+		}
+	}
+	logg.Debug("Pool state fetched", "tokens", len(tokens), "limiterLimits", len(limiterLimits))
+
+	if err := fillPoolTokenState(tokens, balances, limiterLimits); err != nil {
+		return nil, err
+	}
+
+	return &api.PoolState{
+		PoolAddress:    poolAddress,
+		PoolSymbol:     poolDetails.PoolSymbol,
+		LimiterAddress: poolDetails.LimiterAddress,
+		Tokens:         tokens,
+	}, nil
+}
+
+// fillPoolTokenState sets the balance, limit source and remaining capacity of
+// every token. balances is in token order, limiterLimits holds the limits
+// read from the limiter for tokens the pool router has none for.
+func fillPoolTokenState(tokens []*api.PoolTokenState, balances []*big.Int, limiterLimits map[string]*big.Int) error {
+	for i, t := range tokens {
+		balance := balances[i]
+		t.Balance = balance.String()
+
+		limit := new(big.Int)
+		switch {
+		case t.Limit != "":
+			if _, ok := limit.SetString(t.Limit, 10); !ok {
+				return internalError("Invalid pool limit format")
+			}
+			t.LimitSource = api.PoolLimitSourceRouter
+		case limiterLimits[t.TokenAddress] != nil:
+			limit.Set(limiterLimits[t.TokenAddress])
+			t.LimitSource = api.PoolLimitSourceLimiter
+		default:
+			t.LimitSource = api.PoolLimitSourceNone
+		}
+		t.Limit = limit.String()
+
+		remaining := new(big.Int).Sub(limit, balance)
+		if remaining.Sign() < 0 {
+			remaining.SetInt64(0)
+		}
+		t.RemainingCapacity = remaining.String()
+	}
+
+	return nil
+}
+
+// hasLimiter reports whether the pool has a limiter contract to read limits
+// from.
+func hasLimiter(limiterAddress string) bool {
+	return common.HexToAddress(limiterAddress) != common.Address{}
+}
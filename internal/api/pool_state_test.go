@@ -0,0 +1,91 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestFillPoolTokenState(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         api.PoolTokenState
+		balance       *big.Int
+		limiterLimit  *big.Int
+		wantLimit     string
+		wantSource    string
+		wantRemaining string
+	}{
+		{
+			name:          "pool router limit",
+			token:         api.PoolTokenState{TokenAddress: "0xA", Limit: "1000"},
+			balance:       big.NewInt(400),
+			wantLimit:     "1000",
+			wantSource:    api.PoolLimitSourceRouter,
+			wantRemaining: "600",
+		},
+		{
+			name:          "pool router limit wins over the limiter",
+			token:         api.PoolTokenState{TokenAddress: "0xA", Limit: "1000"},
+			balance:       big.NewInt(400),
+			limiterLimit:  big.NewInt(5000),
+			wantLimit:     "1000",
+			wantSource:    api.PoolLimitSourceRouter,
+			wantRemaining: "600",
+		},
+		{
+			name:          "limiter limit",
+			token:         api.PoolTokenState{TokenAddress: "0xA"},
+			balance:       big.NewInt(400),
+			limiterLimit:  big.NewInt(5000),
+			wantLimit:     "5000",
+			wantSource:    api.PoolLimitSourceLimiter,
+			wantRemaining: "4600",
+		},
+		{
+			name:          "no limit",
+			token:         api.PoolTokenState{TokenAddress: "0xA"},
+			balance:       big.NewInt(400),
+			wantLimit:     "0",
+			wantSource:    api.PoolLimitSourceNone,
+			wantRemaining: "0",
+		},
+		{
+			name:          "balance over the limit",
+			token:         api.PoolTokenState{TokenAddress: "0xA", Limit: "100"},
+			balance:       big.NewInt(400),
+			wantLimit:     "100",
+			wantSource:    api.PoolLimitSourceRouter,
+			wantRemaining: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.token
+			var limiterLimits map[string]*big.Int
+			if tt.limiterLimit != nil {
+				limiterLimits = map[string]*big.Int{token.TokenAddress: tt.limiterLimit}
+			}
+
+			if err := fillPoolTokenState([]*api.PoolTokenState{&token}, []*big.Int{tt.balance}, limiterLimits); err != nil {
+				t.Fatal(err)
+			}
+
+			if token.Balance != tt.balance.String() || token.Limit != tt.wantLimit ||
+				token.LimitSource != tt.wantSource || token.RemainingCapacity != tt.wantRemaining {
+				t.Errorf("got balance %s limit %s (%s) remaining %s, want limit %s (%s) remaining %s",
+					token.Balance, token.Limit, token.LimitSource, token.RemainingCapacity,
+					tt.wantLimit, tt.wantSource, tt.wantRemaining)
+			}
+		})
+	}
+
+	t.Run("invalid pool router limit", func(t *testing.T) {
+		token := &api.PoolTokenState{TokenAddress: "0xA", Limit: "1e6"}
+		if err := fillPoolTokenState([]*api.PoolTokenState{token}, []*big.Int{big.NewInt(1)}, nil); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+// registryTokens backs the v1 and v2 registry routes. Registries are only
+// served from the cache, walking one on request is too slow.
+func (a *API) registryTokens(req bunrouter.Request) (*data.RegistryTokens, error) {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return nil, badInput("Address validation failed")
+	}
+
+	if a.registryCache == nil {
+		return nil, notFound("Registry not cached")
+	}
+
+	registry, ok := a.registryCache.Tokens(r.Address)
+	if !ok {
+		return nil, notFound("Registry not cached")
+	}
+
+	return registry, nil
+}
+
+func (a *API) registryTokensHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	registry, err := a.registryTokens(req)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, http.StatusOK, api.OKResponse{
+		Ok:          true,
+		Description: "Registry tokens",
+		Result: map[string]any{
+			"registry":  registry.Registry,
+			"tokens":    registry.Tokens,
+			"updatedAt": registry.UpdatedAt,
+		},
+	})
+}
+
+func (a *API) v2RegistryTokensHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	registry, err := a.registryTokens(req)
+	if err != nil {
+		return err
+	}
+
+	tokens := make([]*api.V2RegistryToken, 0, len(registry.Tokens))
+	for _, t := range registry.Tokens {
+		tokens = append(tokens, &api.V2RegistryToken{
+			TokenAddress:  t.TokenAddress,
+			TokenSymbol:   t.TokenSymbol,
+			TokenName:     t.TokenName,
+			TokenDecimals: t.TokenDecimals,
+			SinkAddress:   t.SinkAddress,
+		})
+	}
+
+	return v2JSON(w, &api.V2RegistryTokens{
+		RegistryAddress: registry.Registry,
+		UpdatedAt:       registry.UpdatedAt,
+		Tokens:          tokens,
+	}, nil, false)
+}
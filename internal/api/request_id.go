@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware reuses the caller's X-Request-ID (or generates one),
+// echoes it back and attaches it to a request scoped logger.
+func (a *API) requestIDMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		requestID := req.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		ctx := util.ContextWithRequestID(req.Context(), requestID)
+		ctx = util.ContextWithLogger(ctx, a.logg.With("request_id", requestID))
+
+		return next(w, req.WithContext(ctx))
+	}
+}
+
+// logger returns the request scoped logger.
+func (a *API) logger(req bunrouter.Request) *slog.Logger {
+	return util.LoggerFromContext(req.Context(), a.logg)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID only accepts short printable ASCII ids so callers can't
+// inject arbitrary data into logs and response headers.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
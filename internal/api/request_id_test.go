@@ -0,0 +1,28 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		want      bool
+	}{
+		{name: "uuid", requestID: "3f1c2a4e-8b7d-4a53-9f0e-2c6d1b7e9a10", want: true},
+		{name: "empty", requestID: "", want: false},
+		{name: "too long", requestID: strings.Repeat("a", maxRequestIDLength+1), want: false},
+		{name: "whitespace", requestID: "abc def", want: false},
+		{name: "header injection", requestID: "abc\r\nX-Evil: 1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validRequestID(tt.requestID); got != tt.want {
+				t.Errorf("validRequestID(%q) = %v, want %v", tt.requestID, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	searchQueryParam   = "q"
+	poolQueryParam     = "pool"
+	registryQueryParam = "registry"
+	limitQueryParam    = "limit"
+
+	defaultTokenSearchLimit = 10
+	defaultPoolSearchLimit  = 10
+)
+
+// TokenSearchQuery holds the token search options. Pool restricts the
+// results to tokens allowed in the pool and Registry to the registry's
+// entries, which are only known for registries in the RegistryCache.
+type TokenSearchQuery struct {
+	Query    string `validate:"required,max=32"`
+	Pool     string `validate:"omitempty,eth_addr_checksum"`
+	Registry string `validate:"omitempty,eth_addr_checksum"`
+	Limit    int    `validate:"min=1,max=50"`
+}
+
+// PoolSearchQuery holds the pool search options.
+// TODO: Filter by the location of the pool's vouchers once voucher locations
+// are indexed, see the graph resolver TODO in tokenDetailsHandler.
+type PoolSearchQuery struct {
+	Query string `validate:"required,max=32"`
+	Limit int    `validate:"min=1,max=50"`
+}
+
+func (a *API) tokenSearchQuery(req bunrouter.Request) (TokenSearchQuery, error) {
+	query := req.URL.Query()
+	q := TokenSearchQuery{
+		Query:    strings.TrimSpace(query.Get(searchQueryParam)),
+		Pool:     query.Get(poolQueryParam),
+		Registry: query.Get(registryQueryParam),
+		Limit:    defaultTokenSearchLimit,
+	}
+
+	if v := query.Get(limitQueryParam); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return q, badInput("Invalid limit")
+		}
+		q.Limit = limit
+	}
+
+	if err := a.validator.Validate(q); err != nil {
+		return q, badInput("Search validation failed")
+	}
+
+	return q, nil
+}
+
+func (a *API) tokenSearchHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	q, err := a.tokenSearchQuery(req)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := SearchTokens(req.Context(), a.logg, a.pgDataSource, a.registryCache, q)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, http.StatusOK, api.OKResponse{
+		Ok:          true,
+		Description: "Tokens matching the search",
+		Result: map[string]any{
+			"tokens": tokens,
+		},
+	})
+}
+
+// SearchTokens matches q.Query against token symbols and names, case
+// insensitively. Results are ranked by match kind, then by activity. A
+// registry filter on a registry not in registries is not found.
+func SearchTokens(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, registries *data.RegistryCache, q TokenSearchQuery) ([]*api.TokenMatch, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	var registry *data.RegistryTokens
+	if q.Registry != "" {
+		var ok bool
+		if registries != nil {
+			registry, ok = registries.Tokens(q.Registry)
+		}
+		if !ok {
+			return nil, notFound("Registry not cached")
+		}
+	}
+
+	candidates, err := pg.TokenSearchCandidates(ctx, q.Pool)
+	if err != nil {
+		logg.Debug("Failed to get token search candidates", "error", err)
+		return nil, err
+	}
+	if registry != nil {
+		candidates = registryCandidates(candidates, registry, q.Pool != "")
+	}
+
+	matches := matchTokens(candidates, q.Query, q.Limit)
+	logg.Debug("Token search", "query", q.Query, "candidates", len(candidates), "matches", len(matches))
+
+	return matches, nil
+}
+
+// registryCandidates returns the registry's entries with the activity of
+// their candidate. Entries the indexer doesn't know are searched by their
+// cached details, unless the candidates are already restricted to a pool.
+func registryCandidates(candidates []*api.TokenMatch, registry *data.RegistryTokens, pooled bool) []*api.TokenMatch {
+	byAddress := make(map[string]*api.TokenMatch, len(candidates))
+	for _, c := range candidates {
+		byAddress[strings.ToLower(c.TokenAddress)] = c
+	}
+
+	filtered := make([]*api.TokenMatch, 0, len(registry.Tokens))
+	for _, t := range registry.Tokens {
+		if c, ok := byAddress[strings.ToLower(t.TokenAddress)]; ok {
+			filtered = append(filtered, c)
+			continue
+		}
+		if !pooled {
+			filtered = append(filtered, &api.TokenMatch{
+				TokenAddress:  t.TokenAddress,
+				TokenSymbol:   t.TokenSymbol,
+				TokenName:     t.TokenName,
+				TokenDecimals: t.TokenDecimals,
+			})
+		}
+	}
+	return filtered
+}
+
+func (a *API) poolSearchQuery(req bunrouter.Request) (PoolSearchQuery, error) {
+	query := req.URL.Query()
+	q := PoolSearchQuery{
+		Query: strings.TrimSpace(query.Get(searchQueryParam)),
+		Limit: defaultPoolSearchLimit,
+	}
+
+	if v := query.Get(limitQueryParam); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return q, badInput("Invalid limit")
+		}
+		q.Limit = limit
+	}
+
+	if err := a.validator.Validate(q); err != nil {
+		return q, badInput("Search validation failed")
+	}
+
+	return q, nil
+}
+
+func (a *API) poolSearchHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	q, err := a.poolSearchQuery(req)
+	if err != nil {
+		return err
+	}
+
+	pools, err := SearchPools(req.Context(), a.logg, a.pgDataSource, q)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, http.StatusOK, api.OKResponse{
+		Ok:          true,
+		Description: "Pools matching the search",
+		Result: map[string]any{
+			"pools": pools,
+		},
+	})
+}
+
+// SearchPools matches q.Query against pool symbols and names like
+// SearchTokens. Results are ranked by match kind, then by activity.
+func SearchPools(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, q PoolSearchQuery) ([]*api.PoolMatch, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	candidates, err := pg.PoolSearchCandidates(ctx)
+	if err != nil {
+		logg.Debug("Failed to get pool search candidates", "error", err)
+		return nil, err
+	}
+
+	matches := matchPools(candidates, q)
+	logg.Debug("Pool search", "query", q.Query, "candidates", len(candidates), "matches", len(matches))
+
+	return matches, nil
+}
+
+// matchPools returns up to q.Limit candidates matching the query, best
+// first.
+func matchPools(candidates []*api.PoolMatch, q PoolSearchQuery) []*api.PoolMatch {
+	query := strings.ToLower(q.Query)
+
+	var matches []*api.PoolMatch
+	for _, c := range candidates {
+		if match := matchText(c.PoolSymbol, c.PoolName, query); match != "" {
+			c.Match = match
+			matches = append(matches, c)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *api.PoolMatch) int {
+		return cmp.Or(
+			cmp.Compare(tokenMatchRank[a.Match], tokenMatchRank[b.Match]),
+			cmp.Compare(b.Activity, a.Activity),
+			strings.Compare(strings.ToLower(a.PoolSymbol), strings.ToLower(b.PoolSymbol)),
+			strings.Compare(a.PoolAddress, b.PoolAddress),
+		)
+	})
+
+	if len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+	return matches
+}
+
+var tokenMatchRank = map[string]int{
+	api.TokenMatchExact:    0,
+	api.TokenMatchPrefix:   1,
+	api.TokenMatchContains: 2,
+	api.TokenMatchFuzzy:    3,
+}
+
+// matchTokens returns up to limit candidates matching query, best first.
+func matchTokens(candidates []*api.TokenMatch, query string, limit int) []*api.TokenMatch {
+	query = strings.ToLower(query)
+
+	var matches []*api.TokenMatch
+	for _, c := range candidates {
+		// Candidates are shared by concurrent searches
+		if match := matchText(c.TokenSymbol, c.TokenName, query); match != "" {
+			m := *c
+			m.Match = match
+			matches = append(matches, &m)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *api.TokenMatch) int {
+		return cmp.Or(
+			cmp.Compare(tokenMatchRank[a.Match], tokenMatchRank[b.Match]),
+			cmp.Compare(b.Activity, a.Activity),
+			strings.Compare(strings.ToLower(a.TokenSymbol), strings.ToLower(b.TokenSymbol)),
+			strings.Compare(a.TokenAddress, b.TokenAddress),
+		)
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// matchText returns how a lower cased query matches a token or pool symbol
+// and name, or an empty string. Names match on a prefix of any word.
+func matchText(symbol, name, query string) string {
+	symbol = strings.ToLower(symbol)
+
+	switch {
+	case symbol == query:
+		return api.TokenMatchExact
+	case strings.HasPrefix(symbol, query):
+		return api.TokenMatchPrefix
+	case strings.Contains(symbol, query):
+		return api.TokenMatchContains
+	}
+
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		if strings.HasPrefix(word, query) {
+			return api.TokenMatchContains
+		}
+	}
+
+	if maxDistance := fuzzyDistance(query); maxDistance > 0 && editDistance(symbol, query) <= maxDistance {
+		return api.TokenMatchFuzzy
+	}
+
+	return ""
+}
+
+// fuzzyDistance is the number of typos tolerated in a query. Very short
+// queries would match most symbols so they only match exactly.
+func fuzzyDistance(query string) int {
+	switch n := len([]rune(query)); {
+	case n < 3:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and swaps of adjacent runes (a common USSD typo)
+// each cost 1.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Three rows are enough: the current, previous and the one before for
+	// transpositions.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "srf", b: "srf", want: 0},
+		{a: "srf", b: "sfr", want: 1},
+		{a: "mbao", b: "mbo", want: 1},
+		{a: "mbao", b: "mbaoo", want: 1},
+		{a: "ckes", b: "cusd", want: 3},
+		{a: "", b: "abc", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	candidates := func() []*api.TokenMatch {
+		return []*api.TokenMatch{
+			{TokenAddress: "0x1", TokenSymbol: "SRF", TokenName: "Sarafu", Activity: 10},
+			{TokenAddress: "0x2", TokenSymbol: "SRFX", TokenName: "Sarafu Extra", Activity: 50},
+			{TokenAddress: "0x3", TokenSymbol: "MSRF", TokenName: "Mombasa Sarafu", Activity: 5},
+			{TokenAddress: "0x4", TokenSymbol: "SFR", TokenName: "Safari Foods", Activity: 99},
+			{TokenAddress: "0x5", TokenSymbol: "MBAO", TokenName: "Mbao Timber", Activity: 1},
+			{TokenAddress: "0x6", TokenSymbol: "KILI", TokenName: "Kilimanjaro", Activity: 7},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		limit     int
+		want      []string
+		wantMatch []string
+	}{
+		{
+			name:      "exact before prefix before contains before fuzzy",
+			query:     "srf",
+			limit:     10,
+			want:      []string{"SRF", "SRFX", "MSRF", "SFR"},
+			wantMatch: []string{api.TokenMatchExact, api.TokenMatchPrefix, api.TokenMatchContains, api.TokenMatchFuzzy},
+		},
+		{
+			name:      "name word prefix ranked by activity",
+			query:     "Sara",
+			limit:     10,
+			want:      []string{"SRFX", "SRF", "MSRF"},
+			wantMatch: []string{api.TokenMatchContains, api.TokenMatchContains, api.TokenMatchContains},
+		},
+		{
+			name:      "typo",
+			query:     "mboa",
+			limit:     10,
+			want:      []string{"MBAO"},
+			wantMatch: []string{api.TokenMatchFuzzy},
+		},
+		{
+			name:  "short queries are not fuzzy",
+			query: "kx",
+			limit: 10,
+		},
+		{
+			name:      "limit",
+			query:     "s",
+			limit:     2,
+			want:      []string{"SFR", "SRFX"},
+			wantMatch: []string{api.TokenMatchPrefix, api.TokenMatchPrefix},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, gotMatch []string
+			for _, m := range matchTokens(candidates(), tt.query, tt.limit) {
+				got = append(got, m.TokenSymbol)
+				gotMatch = append(gotMatch, m.Match)
+			}
+
+			if !slices.Equal(got, tt.want) || !slices.Equal(gotMatch, tt.wantMatch) {
+				t.Errorf("matchTokens() = %v %v, want %v %v", got, gotMatch, tt.want, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestSearchTokensCachesCandidates(t *testing.T) {
+	fake, pg := pgtest.NewServer(t, "../../queries.sql")
+	fake.Set("token-search-candidates", api.TokenMatch{},
+		&api.TokenMatch{TokenAddress: "0x1", TokenSymbol: "SRF", TokenName: "Sarafu", Activity: 10},
+		&api.TokenMatch{TokenAddress: "0x2", TokenSymbol: "SRFX", TokenName: "Sarafu Extra", Activity: 50},
+	)
+	logg := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Every keystroke of a USSD session searches again
+	for _, query := range []string{"s", "sr", "srf"} {
+		matches, err := SearchTokens(context.Background(), logg, pg, nil, TokenSearchQuery{Query: query, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 2 {
+			t.Fatalf("SearchTokens(%q) = %d matches, want 2", query, len(matches))
+		}
+	}
+
+	matches, err := SearchTokens(context.Background(), logg, pg, nil, TokenSearchQuery{Query: "srf", Pool: "0x3", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first search's match kinds are not left on the shared candidates
+	if matches[0].Match != api.TokenMatchExact || matches[1].Match != api.TokenMatchPrefix {
+		t.Errorf("matches = %s %s, want exact and prefix", matches[0].Match, matches[1].Match)
+	}
+
+	want := []string{"token-search-candidates", "token-search-candidates"}
+	if got := fake.Statements(); !slices.Equal(got, want) {
+		t.Errorf("queries = %v, want one per pool filter %v", got, want)
+	}
+}
+
+// newTestRegistryCache returns a RegistryCache holding registries, loaded
+// from a file since it has no other way in without a chain.
+func newTestRegistryCache(t *testing.T, registries ...*data.RegistryTokens) *data.RegistryCache {
+	t.Helper()
+
+	b, err := json.Marshal(registries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "registries.json")
+	if err := os.WriteFile(file, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := data.NewRegistryCache(data.RegistryCacheOpts{Logg: slog.New(slog.NewTextHandler(io.Discard, nil)), File: file})
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSearchTokensRegistry(t *testing.T) {
+	fake, pg := pgtest.NewServer(t, "../../queries.sql")
+	fake.Set("token-search-candidates", api.TokenMatch{},
+		&api.TokenMatch{TokenAddress: "0x00000000000000000000000000000000000000A1", TokenSymbol: "SRF", TokenName: "Sarafu", Activity: 10},
+		&api.TokenMatch{TokenAddress: "0x00000000000000000000000000000000000000A2", TokenSymbol: "SRFX", TokenName: "Sarafu Extra", Activity: 50},
+	)
+	registries := newTestRegistryCache(t, &data.RegistryTokens{
+		Registry: "0x00000000000000000000000000000000000000B1",
+		Tokens: []*api.TokenDetails{
+			// Entries are matched on the address regardless of case
+			{TokenAddress: "0x00000000000000000000000000000000000000a1", TokenSymbol: "SRF", TokenName: "Sarafu"},
+			// Not indexed yet
+			{TokenAddress: "0x00000000000000000000000000000000000000A3", TokenSymbol: "SRFM", TokenName: "Sarafu Market"},
+		},
+	})
+	logg := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		q          TokenSearchQuery
+		registries *data.RegistryCache
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:       "registry entries",
+			q:          TokenSearchQuery{Query: "srf", Registry: "0x00000000000000000000000000000000000000B1", Limit: 10},
+			registries: registries,
+			want:       []string{"SRF", "SRFM"},
+		},
+		{
+			name:       "registry entries in the pool",
+			q:          TokenSearchQuery{Query: "srf", Pool: "0x00000000000000000000000000000000000000C1", Registry: "0x00000000000000000000000000000000000000B1", Limit: 10},
+			registries: registries,
+			want:       []string{"SRF"},
+		},
+		{
+			name:       "registry not cached",
+			q:          TokenSearchQuery{Query: "srf", Registry: "0x00000000000000000000000000000000000000B2", Limit: 10},
+			registries: registries,
+			wantErr:    true,
+		},
+		{
+			name:    "no registry cache",
+			q:       TokenSearchQuery{Query: "srf", Registry: "0x00000000000000000000000000000000000000B1", Limit: 10},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := SearchTokens(context.Background(), logg, pg, tt.registries, tt.q)
+			if tt.wantErr {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.status != http.StatusNotFound {
+					t.Fatalf("error = %v, want not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.TokenSymbol)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchTokens() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPools(t *testing.T) {
+	candidates := func() []*api.PoolMatch {
+		return []*api.PoolMatch{
+			{PoolAddress: "0x1", PoolSymbol: "KMP", PoolName: "Kibera Market Pool", Activity: 10},
+			{PoolAddress: "0x2", PoolSymbol: "KMP2", PoolName: "Kisumu Market Pool", Activity: 50},
+			{PoolAddress: "0x3", PoolSymbol: "MSA", PoolName: "Mombasa Pool", Activity: 5},
+			{PoolAddress: "0x4", PoolSymbol: "KMP", PoolName: "Kawangware Market Pool", Activity: 30},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		q         PoolSearchQuery
+		want      []string
+		wantMatch []string
+	}{
+		{
+			name:      "same symbol ranked by activity",
+			q:         PoolSearchQuery{Query: "kmp", Limit: 10},
+			want:      []string{"0x4", "0x1", "0x2"},
+			wantMatch: []string{api.TokenMatchExact, api.TokenMatchExact, api.TokenMatchPrefix},
+		},
+		{
+			name:      "name word prefix",
+			q:         PoolSearchQuery{Query: "MARKET", Limit: 10},
+			want:      []string{"0x2", "0x4", "0x1"},
+			wantMatch: []string{api.TokenMatchContains, api.TokenMatchContains, api.TokenMatchContains},
+		},
+		{
+			name: "no match",
+			q:    PoolSearchQuery{Query: "eldoret", Limit: 10},
+		},
+		{
+			name:      "limit",
+			q:         PoolSearchQuery{Query: "pool", Limit: 1},
+			want:      []string{"0x2"},
+			wantMatch: []string{api.TokenMatchContains},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, gotMatch []string
+			for _, m := range matchPools(candidates(), tt.q) {
+				got = append(got, m.PoolAddress)
+				gotMatch = append(gotMatch, m.Match)
+			}
+
+			if !slices.Equal(got, tt.want) || !slices.Equal(gotMatch, tt.wantMatch) {
+				t.Errorf("matchPools() = %v %v, want %v %v", got, gotMatch, tt.want, tt.wantMatch)
+			}
+		})
+	}
+}
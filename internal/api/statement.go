@@ -0,0 +1,360 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	fromQueryParam   = "from"
+	toQueryParam     = "to"
+	formatQueryParam = "format"
+
+	statementDateLayout = "2006-01-02"
+	// maxStatementRange bounds a single export, longer periods are fetched in
+	// several requests
+	maxStatementRange = 366 * 24 * time.Hour
+	// statementFlushRows is how many rows are buffered before they are sent
+	statementFlushRows = 100
+)
+
+var statementCSVHeader = []string{
+	"date", "tx_hash", "kind", "direction", "token_address", "token_symbol",
+	"token_decimals", "counterparty", "amount", "balance",
+}
+
+type (
+	StatementQuery struct {
+		From   time.Time
+		To     time.Time
+		Format string `validate:"oneof=csv ndjson"`
+	}
+
+	// statementEncoder writes statement rows in one export format.
+	statementEncoder interface {
+		Encode(*api.StatementEntry) error
+		Flush() error
+	}
+
+	// csvStatementEncoder writes the header row before the first row, or on
+	// the first flush of an empty statement.
+	csvStatementEncoder struct {
+		w      *csv.Writer
+		header bool
+	}
+
+	ndjsonStatementEncoder struct {
+		buf *bufio.Writer
+		enc *json.Encoder
+	}
+
+	// statementLedger keeps the running balance of every token seen in a
+	// statement, in order of first appearance.
+	statementLedger struct {
+		balances map[string]*big.Int
+		tokens   []*api.StatementEntry
+	}
+
+	// statementStream records whether any byte reached the client, after
+	// which errors can no longer be sent as an error response.
+	statementStream struct {
+		w       http.ResponseWriter
+		written bool
+	}
+)
+
+func (a *API) statementQuery(req bunrouter.Request) (StatementQuery, error) {
+	query := req.URL.Query()
+	q := StatementQuery{
+		Format: query.Get(formatQueryParam),
+	}
+	if q.Format == "" {
+		q.Format = api.StatementFormatCSV
+	}
+	if err := a.validator.Validate(q); err != nil {
+		return q, badInput("Invalid statement format")
+	}
+
+	from, _, err := parseStatementTime(query.Get(fromQueryParam))
+	if err != nil {
+		return q, badInput("Invalid from date")
+	}
+	q.From = from
+
+	q.To = time.Now().UTC()
+	if v := query.Get(toQueryParam); v != "" {
+		to, dateOnly, err := parseStatementTime(v)
+		if err != nil {
+			return q, badInput("Invalid to date")
+		}
+		// A date includes the whole day
+		if dateOnly {
+			to = to.Add(24 * time.Hour)
+		}
+		q.To = to
+	}
+
+	if !q.From.Before(q.To) {
+		return q, badInput("from must be before to")
+	}
+	if q.To.Sub(q.From) > maxStatementRange {
+		return q, badInput("Statement range is limited to 366 days")
+	}
+
+	return q, nil
+}
+
+// parseStatementTime accepts a date (UTC) or an RFC 3339 timestamp.
+func parseStatementTime(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(statementDateLayout, v); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	return t.UTC(), false, err
+}
+
+func (a *API) statementHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	q, err := a.statementQuery(req)
+	if err != nil {
+		return err
+	}
+
+	openingBalances, err := a.pgDataSource.StatementOpeningBalances(req.Context(), r.Address, q.From)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s-%s.%s", r.Address, q.From.Format("20060102"), q.To.Format("20060102"), q.Format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Exports outlive the server wide write timeout, the route's request
+	// deadline bounds them instead.
+	if deadline, ok := req.Context().Deadline(); ok {
+		if err := http.NewResponseController(w).SetWriteDeadline(deadline.Add(time.Second)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+
+	stream := &statementStream{w: w}
+	var enc statementEncoder
+	if q.Format == api.StatementFormatNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = newNDJSONStatementEncoder(stream)
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		enc = newCSVStatementEncoder(stream)
+	}
+
+	flush := func() error {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	entries := func(fn func(*api.StatementEntry) error) error {
+		return a.pgDataSource.StatementEntries(req.Context(), r.Address, q.From, q.To, fn)
+	}
+
+	if err := writeStatement(enc, flush, openingBalances, q, entries); err != nil {
+		if !stream.written {
+			w.Header().Del("Content-Disposition")
+			return err
+		}
+		// The status line is already sent, abort the connection so the client
+		// sees a failed download instead of a statement missing its closing rows.
+		a.logger(req).Error("statement export failed mid stream", "address", r.Address, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+// writeStatement writes the opening rows, every ledger entry with its running
+// balance and the closing rows, flushing every statementFlushRows rows so an
+// early failure can still be answered with an error response.
+func writeStatement(enc statementEncoder, flush func() error, openingBalances []*api.StatementEntry, q StatementQuery, entries func(fn func(*api.StatementEntry) error) error) error {
+	ledger := newStatementLedger()
+	rows := 0
+
+	encode := func(entry *api.StatementEntry) error {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+		rows++
+		if rows%statementFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	}
+
+	for _, opening := range openingBalances {
+		if err := ledger.open(opening, q.From); err != nil {
+			return err
+		}
+		if err := encode(opening); err != nil {
+			return err
+		}
+	}
+
+	if err := entries(func(entry *api.StatementEntry) error {
+		if err := ledger.apply(entry); err != nil {
+			return err
+		}
+		return encode(entry)
+	}); err != nil {
+		return err
+	}
+
+	for _, closing := range ledger.closing(q.To) {
+		if err := enc.Encode(closing); err != nil {
+			return err
+		}
+	}
+
+	return flush()
+}
+
+func newStatementLedger() *statementLedger {
+	return &statementLedger{
+		balances: make(map[string]*big.Int),
+	}
+}
+
+// open sets a token's opening balance and turns the row into an opening row.
+func (l *statementLedger) open(entry *api.StatementEntry, at time.Time) error {
+	balance, ok := new(big.Int).SetString(entry.Balance, 10)
+	if !ok {
+		return internalError("Invalid statement balance")
+	}
+
+	entry.Kind = api.StatementKindOpening
+	entry.Date = at
+	l.track(entry)
+	l.balances[entry.TokenAddress] = balance
+
+	return nil
+}
+
+// apply adds an entry to its token's balance and sets entry.Balance to the
+// balance after it.
+func (l *statementLedger) apply(entry *api.StatementEntry) error {
+	amount, ok := new(big.Int).SetString(entry.Amount, 10)
+	if !ok {
+		return internalError("Invalid statement amount")
+	}
+
+	balance, ok := l.balances[entry.TokenAddress]
+	if !ok {
+		balance = new(big.Int)
+		l.balances[entry.TokenAddress] = balance
+		l.track(entry)
+	}
+
+	if entry.Direction == api.StatementDirectionOut {
+		balance.Sub(balance, amount)
+	} else {
+		balance.Add(balance, amount)
+	}
+	entry.Balance = balance.String()
+
+	return nil
+}
+
+func (l *statementLedger) track(entry *api.StatementEntry) {
+	l.tokens = append(l.tokens, &api.StatementEntry{
+		TokenAddress:  entry.TokenAddress,
+		TokenSymbol:   entry.TokenSymbol,
+		TokenDecimals: entry.TokenDecimals,
+	})
+}
+
+// closing returns a closing row for every token, in order of first appearance.
+func (l *statementLedger) closing(at time.Time) []*api.StatementEntry {
+	rows := make([]*api.StatementEntry, 0, len(l.tokens))
+	for _, token := range l.tokens {
+		token.Kind = api.StatementKindClosing
+		token.Date = at
+		token.Balance = l.balances[token.TokenAddress].String()
+		rows = append(rows, token)
+	}
+	return rows
+}
+
+func newCSVStatementEncoder(w io.Writer) *csvStatementEncoder {
+	return &csvStatementEncoder{w: csv.NewWriter(w), header: true}
+}
+
+func (e *csvStatementEncoder) Encode(entry *api.StatementEntry) error {
+	if e.header {
+		e.header = false
+		if err := e.w.Write(statementCSVHeader); err != nil {
+			return err
+		}
+	}
+
+	return e.w.Write([]string{
+		entry.Date.UTC().Format(time.RFC3339),
+		entry.TxHash,
+		entry.Kind,
+		entry.Direction,
+		entry.TokenAddress,
+		entry.TokenSymbol,
+		entry.TokenDecimals,
+		entry.Counterparty,
+		entry.Amount,
+		entry.Balance,
+	})
+}
+
+func (e *csvStatementEncoder) Flush() error {
+	if e.header {
+		e.header = false
+		if err := e.w.Write(statementCSVHeader); err != nil {
+			return err
+		}
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func newNDJSONStatementEncoder(w io.Writer) *ndjsonStatementEncoder {
+	buf := bufio.NewWriter(w)
+	return &ndjsonStatementEncoder{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *ndjsonStatementEncoder) Encode(entry *api.StatementEntry) error {
+	return e.enc.Encode(entry)
+}
+
+func (e *ndjsonStatementEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+func (s *statementStream) Write(b []byte) (int, error) {
+	s.written = true
+	return s.w.Write(b)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestWriteStatement(t *testing.T) {
+	const (
+		cUSD = "0x765DE816845861e75A25fCA122bb6898B8B1282a"
+		srf  = "0xcebA9300f2b948710d2653dD7B07f33A8B32118C"
+		pool = "0x045Dc382332aBFb9155FF7D1bD9981fF142492a9"
+		bob  = "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439"
+	)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	at := from.Add(time.Hour)
+
+	opening := func() []*api.StatementEntry {
+		return []*api.StatementEntry{
+			{TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Balance: "1000"},
+		}
+	}
+	entries := func() []*api.StatementEntry {
+		return []*api.StatementEntry{
+			{Date: at, TxHash: "0x01", Kind: api.StatementKindTransfer, Direction: api.StatementDirectionOut, TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Counterparty: bob, Amount: "300"},
+			{Date: at, TxHash: "0x02", Kind: api.StatementKindSwap, Direction: api.StatementDirectionOut, TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Counterparty: pool, Amount: "200"},
+			{Date: at, TxHash: "0x02", Kind: api.StatementKindSwap, Direction: api.StatementDirectionIn, TokenAddress: srf, TokenSymbol: "SRF", TokenDecimals: "6", Counterparty: pool, Amount: "400"},
+			{Date: at, TxHash: "0x03", Kind: api.StatementKindMint, Direction: api.StatementDirectionIn, TokenAddress: srf, TokenSymbol: "SRF", TokenDecimals: "6", Counterparty: bob, Amount: "50"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		entries []*api.StatementEntry
+		want    string
+	}{
+		{
+			name:    "csv",
+			format:  api.StatementFormatCSV,
+			entries: entries(),
+			want: `date,tx_hash,kind,direction,token_address,token_symbol,token_decimals,counterparty,amount,balance
+2025-01-01T00:00:00Z,,opening,,0x765DE816845861e75A25fCA122bb6898B8B1282a,cUSD,6,,,1000
+2025-01-01T01:00:00Z,0x01,transfer,out,0x765DE816845861e75A25fCA122bb6898B8B1282a,cUSD,6,0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439,300,700
+2025-01-01T01:00:00Z,0x02,swap,out,0x765DE816845861e75A25fCA122bb6898B8B1282a,cUSD,6,0x045Dc382332aBFb9155FF7D1bD9981fF142492a9,200,500
+2025-01-01T01:00:00Z,0x02,swap,in,0xcebA9300f2b948710d2653dD7B07f33A8B32118C,SRF,6,0x045Dc382332aBFb9155FF7D1bD9981fF142492a9,400,400
+2025-01-01T01:00:00Z,0x03,mint,in,0xcebA9300f2b948710d2653dD7B07f33A8B32118C,SRF,6,0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439,50,450
+2025-02-01T00:00:00Z,,closing,,0x765DE816845861e75A25fCA122bb6898B8B1282a,cUSD,6,,,500
+2025-02-01T00:00:00Z,,closing,,0xcebA9300f2b948710d2653dD7B07f33A8B32118C,SRF,6,,,450
+`,
+		},
+		{
+			name:   "ndjson without entries",
+			format: api.StatementFormatNDJSON,
+			want: `{"date":"2025-01-01T00:00:00Z","kind":"opening","tokenAddress":"0x765DE816845861e75A25fCA122bb6898B8B1282a","tokenSymbol":"cUSD","tokenDecimals":"6","balance":"1000"}
+{"date":"2025-02-01T00:00:00Z","kind":"closing","tokenAddress":"0x765DE816845861e75A25fCA122bb6898B8B1282a","tokenSymbol":"cUSD","tokenDecimals":"6","balance":"1000"}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var enc statementEncoder = newCSVStatementEncoder(&buf)
+			if tt.format == api.StatementFormatNDJSON {
+				enc = newNDJSONStatementEncoder(&buf)
+			}
+
+			err := writeStatement(enc, enc.Flush, opening(), StatementQuery{From: from, To: to}, func(fn func(*api.StatementEntry) error) error {
+				for _, entry := range tt.entries {
+					if err := fn(entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("statement =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteStatementBuffersUntilFlush(t *testing.T) {
+	var buf bytes.Buffer
+	enc := newCSVStatementEncoder(&buf)
+	errQuery := errors.New("query failed")
+
+	err := writeStatement(enc, enc.Flush, nil, StatementQuery{}, func(fn func(*api.StatementEntry) error) error {
+		if err := fn(&api.StatementEntry{Kind: api.StatementKindMint, Direction: api.StatementDirectionIn, Amount: "1"}); err != nil {
+			return err
+		}
+		return errQuery
+	})
+	if !errors.Is(err, errQuery) {
+		t.Fatalf("error = %v, want %v", err, errQuery)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q before the first flush, the error response can no longer be sent", buf.String())
+	}
+}
+
+// TestStatementHandler exports through the router, so the route deadline
+// set by the timeout middleware is what bounds the entries query.
+func TestStatementHandler(t *testing.T) {
+	const (
+		cUSD = "0x765DE816845861e75A25fCA122bb6898B8B1282a"
+		bob  = "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439"
+	)
+	at := time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		url           string
+		routeTimeouts map[string]time.Duration
+		wantTimeout   time.Duration
+	}{
+		{
+			name:        "v1 default deadline",
+			url:         "/api/v1/statement/" + bob + "?from=2025-01-01&to=2025-01-31",
+			wantTimeout: statementTimeout,
+		},
+		{
+			name:          "v2 configured deadline",
+			url:           "/api/v2/statements/" + bob + "?from=2025-01-01&to=2025-01-31",
+			routeTimeouts: map[string]time.Duration{"/api/v2/statements/:address": 30 * time.Second},
+			wantTimeout:   30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, pg := pgtest.NewServer(t, "../../queries.sql")
+			fake.Set("statement-opening-balances", api.StatementEntry{}, &api.StatementEntry{TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Balance: "1000"})
+			fake.Set("statement-entries", api.StatementEntry{},
+				&api.StatementEntry{Date: at, TxHash: "0x01", Kind: api.StatementKindTransfer, Direction: api.StatementDirectionOut, TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Counterparty: bob, Amount: "300"},
+			)
+
+			a, token := newTestAPI(t, APIOpts{PgDataSource: pg, RouteTimeouts: tt.routeTimeouts})
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			a.router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			if rows := strings.Count(rec.Body.String(), "\n"); rows != 4 {
+				t.Errorf("rows = %d, want header, opening, entry and closing rows\n%s", rows, rec.Body.String())
+			}
+
+			statements := fake.Statements()
+			if len(statements) != 5 {
+				t.Fatalf("statements = %q, want opening balances and the entries in a transaction", statements)
+			}
+			if statements[0] != "statement-opening-balances" || !strings.HasPrefix(strings.ToLower(statements[1]), "begin read only") || statements[3] != "statement-entries" || statements[4] != "commit" {
+				t.Errorf("statements = %q", statements)
+			}
+
+			timeout, ok := strings.CutPrefix(statements[2], "SET LOCAL statement_timeout = ")
+			if !ok {
+				t.Fatalf("statement = %q, want statement_timeout set", statements[2])
+			}
+			ms, err := strconv.ParseInt(timeout, 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := time.Duration(ms) * time.Millisecond; got > tt.wantTimeout || got < tt.wantTimeout-5*time.Second {
+				t.Errorf("statement_timeout = %s, want the %s route deadline", got, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestParseStatementTime(t *testing.T) {
+	tests := []struct {
+		in           string
+		want         time.Time
+		wantDateOnly bool
+		wantErr      bool
+	}{
+		{in: "2025-03-01", want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), wantDateOnly: true},
+		{in: "2025-03-01T10:00:00+03:00", want: time.Date(2025, 3, 1, 7, 0, 0, 0, time.UTC)},
+		{in: "", wantErr: true},
+		{in: "01/03/2025", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, dateOnly, err := parseStatementTime(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Equal(tt.want) || dateOnly != tt.wantDateOnly || !strings.HasSuffix(got.Format(time.RFC3339), "Z") {
+				t.Errorf("parseStatementTime() = %s, %v, want %s, %v", got, dateOnly, tt.want, tt.wantDateOnly)
+			}
+		})
+	}
+}
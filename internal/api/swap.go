@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+)
+
+// SwapLimit returns the largest amount of u.FromToken the user can swap into
+// u.ToToken through the pool. It is shared by the HTTP handler and the CLI.
+func SwapLimit(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, chain *data.Chain, u PoolLimits) (*big.Int, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	swapRates, err := pg.PoolTokenSwapRates(ctx, u.PoolAddress, u.FromToken, u.ToToken)
+	if err != nil {
+		logg.Debug("Failed to get token swap rates", "error", err)
+		return nil, err
+	}
+
+	if swapRates == nil {
+		return nil, notFound("Swap rates not found for the specified pool and tokens")
+	}
+
+	if swapRates.InRate == 0 {
+		swapRates.OutRate = 10_000
+	}
+	if swapRates.OutRate == 0 {
+		swapRates.OutRate = 10_000
+	}
+
+	logg.Debug("Swap rates found", "inRate", swapRates.InRate, "outRate", swapRates.OutRate,
+		"inDecimals", swapRates.InDecimals, "outDecimals", swapRates.OutDecimals,
+		"inTokenLimit", swapRates.InTokenLimit, "outTokenLimit", swapRates.OutTokenLimit)
+
+	// Get user balance and pool balance from chain
+	userInBalance, poolInBalance, poolOutBalance, err := chain.GetSwapBalances(
+		ctx,
+		u.UserAddress,
+		u.PoolAddress,
+		u.FromToken,
+		u.ToToken,
+	)
+	if err != nil {
+		return nil, err
+	}
+	logg.Debug("Swap balances found", "userInBalance", userInBalance.String(),
+		"poolInBalance", poolInBalance.String(), "poolOutBalance", poolOutBalance.String())
+
+	// Convert the token limit from database string to *big.Int
+	inTokenLimit := new(big.Int)
+	if _, ok := inTokenLimit.SetString(swapRates.InTokenLimit, 10); !ok {
+		return nil, internalError("Invalid token limit format")
+	}
+
+	outTokenLimit := new(big.Int)
+	if _, ok := outTokenLimit.SetString(swapRates.OutTokenLimit, 10); !ok {
+		return nil, internalError("Invalid token limit format")
+	}
+
+	return chain.MaxSwapInput(
+		userInBalance,
+		inTokenLimit,
+		outTokenLimit,
+		poolInBalance,
+		poolOutBalance,
+		swapRates.InRate,
+		swapRates.OutRate,
+		swapRates.InDecimals,
+		swapRates.OutDecimals,
+	), nil
+}
+
+// ReverseQuote returns the amount of u.FromToken required to receive
+// outputAmount of u.ToToken from the pool.
+func ReverseQuote(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, u ReverseQuoteParams, outputAmount *big.Int) (*big.Int, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	swapRates, err := pg.PoolTokenSwapRates(ctx, u.PoolAddress, u.FromToken, u.ToToken)
+	if err != nil {
+		logg.Debug("Failed to get token swap rates", "error", err)
+		return nil, err
+	}
+
+	if swapRates == nil {
+		return nil, notFound("Swap rates not found for the specified pool and tokens")
+	}
+
+	if swapRates.InRate == 0 {
+		swapRates.InRate = 10_000
+	}
+	if swapRates.OutRate == 0 {
+		swapRates.OutRate = 10_000
+	}
+
+	logg.Debug("Swap rates found", "inRate", swapRates.InRate, "outRate", swapRates.OutRate,
+		"inDecimals", swapRates.InDecimals, "outDecimals", swapRates.OutDecimals)
+
+	inputAmount := CalculateReverseQuote(outputAmount, swapRates.InRate, swapRates.OutRate, swapRates.InDecimals, swapRates.OutDecimals)
+
+	if inputAmount == nil {
+		return nil, internalError("Invalid swap rate configuration")
+	}
+
+	logg.Debug("Reverse quote calculation", "outputAmount", outputAmount.String(), "inputAmount", inputAmount.String())
+
+	return inputAmount, nil
+}
+
+// CreditSendLimits returns the most a user can send through the pool, both in
+// the sender's active token (u.FromToken) and the recipient's active token
+// (u.ToToken).
+func CreditSendLimits(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, chain *data.Chain, u CreditSendParams) (*big.Int, *big.Int, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	swapRates, err := pg.PoolTokenSwapRates(ctx, u.PoolAddress, u.FromToken, u.ToToken)
+	if err != nil {
+		logg.Debug("Failed to get token swap rates", "error", err)
+		return nil, nil, err
+	}
+
+	if swapRates == nil {
+		return nil, nil, notFound("Swap rates not found for the specified pool and tokens")
+	}
+
+	if swapRates.InRate == 0 {
+		swapRates.InRate = 10_000
+	}
+	if swapRates.OutRate == 0 {
+		swapRates.OutRate = 10_000
+	}
+
+	logg.Debug("Swap rates found", "inRate", swapRates.InRate, "outRate", swapRates.OutRate,
+		"inDecimals", swapRates.InDecimals, "outDecimals", swapRates.OutDecimals,
+		"inTokenLimit", swapRates.InTokenLimit, "outTokenLimit", swapRates.OutTokenLimit)
+
+	userInBalance, poolInBalance, poolOutBalance, err := chain.GetSwapBalances(
+		ctx,
+		u.UserAddress,
+		u.PoolAddress,
+		u.FromToken,
+		u.ToToken,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	logg.Debug("Swap balances found", "userInBalance", userInBalance.String(),
+		"poolInBalance", poolInBalance.String(), "poolOutBalance", poolOutBalance.String())
+
+	inTokenLimit := new(big.Int)
+	if _, ok := inTokenLimit.SetString(swapRates.InTokenLimit, 10); !ok {
+		return nil, nil, internalError("Invalid token limit format")
+	}
+
+	outTokenLimit := new(big.Int)
+	if _, ok := outTokenLimit.SetString(swapRates.OutTokenLimit, 10); !ok {
+		return nil, nil, internalError("Invalid token limit format")
+	}
+
+	maxInSAT := chain.MaxSwapInput(
+		userInBalance,
+		inTokenLimit,
+		outTokenLimit,
+		poolInBalance,
+		poolOutBalance,
+		swapRates.InRate,
+		swapRates.OutRate,
+		swapRates.InDecimals,
+		swapRates.OutDecimals,
+	)
+
+	// maxInRAT = maxInSAT * (inRate / outRate) * (10^outDecimals / 10^inDecimals)
+	// This is the inverse of the MaxSwap calculation
+	bigInRate := new(big.Int).SetUint64(swapRates.InRate)
+	bigOutRate := new(big.Int).SetUint64(swapRates.OutRate)
+
+	pow10In := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(swapRates.InDecimals)), nil)
+	pow10Out := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(swapRates.OutDecimals)), nil)
+
+	numerator := new(big.Int).Mul(maxInSAT, bigInRate)
+	numerator.Mul(numerator, pow10Out)
+
+	denominator := new(big.Int).Mul(bigOutRate, pow10In)
+
+	var maxInRAT *big.Int
+	if denominator.Sign() == 0 {
+		maxInRAT = big.NewInt(0)
+	} else {
+		maxInRAT = new(big.Int).Div(numerator, denominator)
+	}
+
+	logg.Debug("Credit Send calculation", "maxInSAT", maxInSAT.String(), "maxInRAT", maxInRAT.String())
+
+	return maxInSAT, maxInRAT, nil
+}
+
+// AbsoluteCredit returns how much more of u.TokenAddress the user can put
+// into the pool, bounded by both their balance and the pool's token limit.
+func AbsoluteCredit(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, chain *data.Chain, u PoolBalanceParams) (*big.Int, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	poolLimit, err := pg.PoolTokenLimit(ctx, u.PoolAddress, u.TokenAddress)
+	if err != nil {
+		logg.Debug("Failed to get pool token limit", "error", err)
+		return nil, err
+	}
+
+	poolLimitBig := new(big.Int)
+	if _, ok := poolLimitBig.SetString(poolLimit, 10); !ok {
+		return nil, internalError("Invalid pool limit format")
+	}
+
+	userBalance, err := chain.TokenBalance(ctx, u.UserAddress, u.TokenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	remainingLimit := new(big.Int).Sub(poolLimitBig, userBalance)
+	logg.Debug("Pool balance calculation",
+		"poolLimit", poolLimitBig.String(),
+		"userBalance", userBalance.String(),
+		"remainingLimit", remainingLimit.String())
+
+	// Calculate absoluteCredit = min(userBalance, poolLimit - userBalance)
+	// Examples:
+	// - If user has 50 SRF and pool limit is 60: credit = min(50, 60-50) = min(50, 10) = 10
+	// - If user has 10 SRF and pool limit is 1000000: credit = min(10, 1000000-10) = min(10, 999990) = 10
+	// Your credit can't be higher than your current balance
+	absoluteCredit := new(big.Int)
+	if userBalance.Cmp(remainingLimit) <= 0 {
+		// User balance is smaller or equal, so credit = user balance
+		absoluteCredit.Set(userBalance)
+	} else {
+		// Remaining limit is smaller, so credit = remaining limit
+		absoluteCredit.Set(remainingLimit)
+	}
+
+	return absoluteCredit, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/uptrace/bunrouter"
+)
+
+// partialResponseReserve is kept from the request deadline when a composite
+// handler makes its chain lookup, leaving time to still return DB only
+// results if the lookup times out.
+const partialResponseReserve = 500 * time.Millisecond
+
+// timeoutMiddleware bounds every request by its route's SLA so a hung
+// dependency can't hold a USSD session open.
+func (a *API) timeoutMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		timeouts := a.timeouts.Load()
+		timeout, ok := timeouts.routes[req.Route()]
+		if !ok {
+			timeout = timeouts.defaultTimeout
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+
+		return next(w, req.WithContext(ctx))
+	}
+}
+
+// mergeBalancesWithinSLA merges on-chain balances into holdings, giving up
+// shortly before the request deadline. On timeout it returns the DB only
+// holdings (without balances) and partial set to true instead of failing.
+// includeEmpty keeps holdings with no balance, flagged as empty.
+func (a *API) mergeBalancesWithinSLA(req bunrouter.Request, holdings []*api.TokenHoldings, ownerAddress string, includeEmpty bool) ([]*api.TokenHoldings, bool, error) {
+	ctx := req.Context()
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) > partialResponseReserve {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-partialResponseReserve))
+		defer cancel()
+	}
+
+	merge := a.chainDataSource.MergeTokenBalances
+	if includeEmpty {
+		merge = a.chainDataSource.MergeAllTokenBalances
+	}
+
+	merged, err := merge(ctx, holdings, ownerAddress)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && req.Context().Err() == nil {
+			a.logger(req).Warn("balance lookup missed deadline, returning partial results", "owner", ownerAddress)
+			return holdings, true, nil
+		}
+		return nil, false, err
+	}
+
+	return merged, false, nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/internal/rpctest"
+	model "github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/uptrace/bunrouter"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	a, err := New(APIOpts{
+		Logg:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		RequestTimeout: 3 * time.Second,
+		RouteTimeouts:  map[string]time.Duration{"/slow/:id": 7 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deadline time.Time
+	router := bunrouter.New()
+	router.Use(a.timeoutMiddleware).WithGroup("", func(g *bunrouter.Group) {
+		handler := func(w http.ResponseWriter, req bunrouter.Request) error {
+			deadline, _ = req.Context().Deadline()
+			return nil
+		}
+		g.GET("/slow/:id", handler)
+		g.GET("/fast/:id", handler)
+		g.GET(apiVersion+"/statement/:address", handler)
+	})
+
+	tests := []struct {
+		url  string
+		want time.Duration
+	}{
+		{url: "/slow/1", want: 7 * time.Second},
+		{url: "/fast/1", want: 3 * time.Second},
+		{url: apiVersion + "/statement/" + testAddress, want: statementTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			startedAt := time.Now()
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if got := deadline.Sub(startedAt); got < tt.want || got > tt.want+time.Second {
+				t.Errorf("deadline in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHoldingsWithinSLA(t *testing.T) {
+	holding := &model.TokenHoldings{TokenAddress: testAddress, TokenSymbol: "USDm", TokenDecimals: "6"}
+
+	tests := []struct {
+		name         string
+		routeTimeout time.Duration
+		wantStatus   int
+		// wantElapsed is when the response is expected, the lookup never finishes
+		wantElapsed time.Duration
+	}{
+		{
+			name:         "partial before the deadline",
+			routeTimeout: time.Second,
+			wantStatus:   http.StatusOK,
+			wantElapsed:  time.Second - partialResponseReserve,
+		},
+		{
+			// No time to keep in reserve, so the lookup runs to the deadline.
+			name:         "timeout",
+			routeTimeout: partialResponseReserve / 2,
+			wantStatus:   http.StatusGatewayTimeout,
+			wantElapsed:  partialResponseReserve / 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logg := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			fake, pg := pgtest.NewServer(t, "../../queries.sql")
+			fake.Set("token-holdings", model.TokenHoldings{}, holding)
+
+			// The balance scanner answers long after any deadline.
+			rpc := rpctest.NewServer(t)
+			rpc.SetDelay(time.Minute)
+
+			a, token := newTestAPI(t, APIOpts{
+				PgDataSource:    pg,
+				ChainDataSource: data.NewChainProvider(data.ChainOpts{ChainID: 1, RPCEndpoint: rpc.URL(), Logg: logg}),
+				RouteTimeouts:   map[string]time.Duration{apiVersion + "/holdings/:address": tt.routeTimeout},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, apiVersion+"/holdings/"+testAddress, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+
+			startedAt := time.Now()
+			a.router.ServeHTTP(rec, req)
+			elapsed := time.Since(startedAt)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if elapsed < tt.wantElapsed || elapsed > tt.wantElapsed+300*time.Millisecond {
+				t.Errorf("responded after %s, want %s", elapsed, tt.wantElapsed)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Partial bool `json:"partial"`
+				Result  struct {
+					Holdings []*model.TokenHoldings `json:"holdings"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !resp.Partial {
+				t.Error("partial = false, want true")
+			}
+			// The DB only holdings, without balances.
+			if len(resp.Result.Holdings) != 1 || *resp.Result.Holdings[0] != *holding {
+				t.Errorf("holdings = %+v, want the unmerged %+v", resp.Result.Holdings, holding)
+			}
+		})
+	}
+}
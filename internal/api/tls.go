@@ -0,0 +1,150 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// certReloader serves the configured key pair and reloads it whenever the
+// certificate file changes on disk, so rotated certs are picked up without a
+// restart.
+type certReloader struct {
+	logg     *slog.Logger
+	certFile string
+	keyFile  string
+	// checkInterval is how often handshakes look at the cert file's mod time
+	checkInterval time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+const certCheckInterval = 5 * time.Second
+
+func newCertReloader(logg *slog.Logger, certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		logg:          logg,
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: certCheckInterval,
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if !r.checkDue(time.Now()) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.cert, nil
+	}
+
+	if info, err := os.Stat(r.certFile); err == nil {
+		r.mu.RLock()
+		changed := !info.ModTime().Equal(r.modTime)
+		r.mu.RUnlock()
+
+		if changed {
+			if err := r.reload(); err != nil {
+				// Keep serving the old certificate, the new pair may be half written.
+				r.logg.Error("failed to reload TLS certificate", "error", err)
+			} else {
+				r.logg.Info("TLS certificate reloaded", "cert_file", r.certFile)
+			}
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// checkDue reports whether the cert file should be checked for changes, at
+// most once per checkInterval so busy servers don't stat it on every handshake.
+func (r *certReloader) checkDue(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.checkedAt) < r.checkInterval {
+		return false
+	}
+	r.checkedAt = now
+	return true
+}
+
+func (a *API) buildTLSConfig(o APIOpts) (*tls.Config, error) {
+	reloader, err := newCertReloader(a.logg, o.TLSCertFile, o.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if o.TLSClientCAFile != "" {
+		caPEM, err := os.ReadFile(o.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no client CA certificates found")
+		}
+
+		// Client certificates are optional, callers without one fall back to JWT auth.
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// verifiedClientCert reports whether the request carries a client certificate
+// that was verified against the configured CA and, when an allowlist is set,
+// whose common name is allowed.
+func (a *API) verifiedClientCert(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	commonName := state.VerifiedChains[0][0].Subject.CommonName
+	if len(a.clientNames) > 0 && !slices.Contains(a.clientNames, commonName) {
+		return commonName, false
+	}
+
+	return commonName, true
+}
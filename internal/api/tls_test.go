@@ -0,0 +1,244 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for TLS tests.
+type testCA struct {
+	t      *testing.T
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	ca := &testCA{t: t}
+	ca.cert, ca.key = ca.issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return ca
+}
+
+// issue signs template with the CA, or self signs it for the CA itself.
+func (ca *testCA) issue(template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	ca.t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+
+	ca.serial++
+	template.SerialNumber = big.NewInt(ca.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := template, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	return cert, key
+}
+
+func (ca *testCA) serverCert() (*x509.Certificate, *ecdsa.PrivateKey) {
+	return ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func (ca *testCA) clientCert(commonName string) tls.Certificate {
+	cert, key := ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// writePEM writes the certificate and key to certFile and keyFile.
+func writePEM(t *testing.T, certFile string, keyFile string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTLSServer serves handler with config as is, httptest's StartTLS would
+// add its own certificate in front of GetCertificate.
+func startTLSServer(t *testing.T, handler http.Handler, config *tls.Config) string {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = tls.NewListener(server.Listener, config)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return "https://" + server.Listener.Addr().String()
+}
+
+func TestClientCertAuth(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+
+	serverCert, serverKey := ca.serverCert()
+	writePEM(t, certFile, keyFile, serverCert, serverKey)
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(APIOpts{
+		Logg:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		EnableTLS:       true,
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: caFile,
+		TLSClientNames:  []string{"ussd-server"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverURL := startTLSServer(t, a.Handler(), a.server.TLSConfig)
+
+	tests := []struct {
+		name       string
+		certs      []tls.Certificate
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "allowed common name", certs: []tls.Certificate{ca.clientCert("ussd-server")}, wantStatus: http.StatusOK},
+		{name: "disallowed common name", certs: []tls.Certificate{ca.clientCert("someone-else")}, wantStatus: http.StatusUnauthorized},
+		{name: "no client certificate", wantStatus: http.StatusUnauthorized},
+		{name: "untrusted client certificate", certs: []tls.Certificate{newTestCA(t).clientCert("ussd-server")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      ca.pool(),
+				Certificates: tt.certs,
+			}}}
+
+			// No Authorization header, only a verified client certificate gets past auth.
+			resp, err := client.Get(serverURL + "/api/v1/alias/alice")
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("expected the handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCertReloaderRotation(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+
+	oldCert, oldKey := ca.serverCert()
+	writePEM(t, certFile, keyFile, oldCert, oldKey)
+
+	reloader, err := newCertReloader(slog.New(slog.NewTextHandler(io.Discard, nil)), certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverURL := startTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}), &tls.Config{GetCertificate: reloader.GetCertificate})
+
+	servedSerial := func() int64 {
+		t.Helper()
+
+		// A new transport per request so every request does a handshake.
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.pool()}}}
+		resp, err := client.Get(serverURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got := servedSerial(); got != oldCert.SerialNumber.Int64() {
+		t.Fatalf("serial = %d, want %d", got, oldCert.SerialNumber.Int64())
+	}
+
+	newCert, newKey := ca.serverCert()
+	writePEM(t, certFile, keyFile, newCert, newKey)
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file was checked on the first handshake, so the rotation isn't seen
+	// until the check interval passes.
+	if got := servedSerial(); got != oldCert.SerialNumber.Int64() {
+		t.Errorf("serial within check interval = %d, want %d", got, oldCert.SerialNumber.Int64())
+	}
+
+	reloader.mu.Lock()
+	reloader.checkedAt = time.Time{}
+	reloader.mu.Unlock()
+
+	if got := servedSerial(); got != newCert.SerialNumber.Int64() {
+		t.Errorf("serial after rotation = %d, want %d", got, newCert.SerialNumber.Int64())
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/uptrace/bunrouter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/grassrootseconomics/ussd-data-service/internal/api")
+
+// tracingMiddleware starts a server span per request, continuing any trace
+// context propagated by the caller.
+func tracingMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		route := req.Route()
+
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer.Start(ctx, req.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(req.URL.Path),
+				attribute.String("request.id", util.RequestIDFromContext(req.Context())),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		err := next(rec, req.WithContext(ctx))
+
+		if rec.status != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+
+		return err
+	}
+}
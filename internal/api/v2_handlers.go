@@ -0,0 +1,638 @@
+package api
+
+import (
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+	"github.com/uptrace/bunrouter"
+)
+
+// The v2 handlers share their lookups with v1 and only differ in the typed
+// envelope and model naming, see pkg/api/v2.go.
+
+func v2JSON[T any](w http.ResponseWriter, data T, freshness *api.Freshness, partial bool) error {
+	return httputil.JSON(w, http.StatusOK, api.V2Response[T]{
+		Ok:        true,
+		Data:      data,
+		Freshness: freshness,
+		Partial:   partial,
+	})
+}
+
+func (a *API) v2TransfersHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	last10Tx, err := a.pgDataSource.Last10Tx(req.Context(), r.Address)
+	if err != nil {
+		return err
+	}
+
+	transfers := make([]*api.V2Transfer, 0, len(last10Tx))
+	for _, tx := range last10Tx {
+		decimals, err := parseDecimals(tx.TokenDecimals)
+		if err != nil {
+			return err
+		}
+		transfers = append(transfers, &api.V2Transfer{
+			TxHash:        tx.TxHash,
+			Sender:        tx.Sender,
+			Recipient:     tx.Recipient,
+			TokenAddress:  tx.ContractAddress,
+			TokenSymbol:   tx.TokenSymbol,
+			TokenDecimals: decimals,
+			Value:         tx.TransferValue,
+			Success:       tx.Success,
+			Timestamp:     tx.DateBlock,
+		})
+	}
+
+	return v2JSON(w, transfers, a.freshness(w), false)
+}
+
+func (a *API) v2HoldingsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	q, err := a.holdingsQuery(req)
+	if err != nil {
+		return err
+	}
+
+	tokenHoldings, err := a.pgDataSource.TokenHoldings(req.Context(), r.Address)
+	if err != nil {
+		return err
+	}
+
+	merged, partial, err := a.mergeBalancesWithinSLA(req, tokenHoldings, r.Address, q.IncludeEmpty)
+	if err != nil {
+		return err
+	}
+	sortHoldings(merged, q.Sort)
+
+	balances, err := toV2Balances(merged, !partial)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, balances, a.freshness(w), partial)
+}
+
+func (a *API) v2PortfolioValueHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	valuation, partial, err := a.portfolioValue(req)
+	if err != nil {
+		return err
+	}
+
+	holdings := make([]*api.V2HoldingValue, 0, len(valuation.Holdings))
+	for _, h := range valuation.Holdings {
+		decimals, err := parseDecimals(h.TokenDecimals)
+		if err != nil {
+			return err
+		}
+
+		holding := &api.V2HoldingValue{
+			TokenAddress:  h.TokenAddress,
+			TokenSymbol:   h.TokenSymbol,
+			TokenDecimals: decimals,
+			Priced:        h.Priced,
+		}
+		if h.Balance != "" {
+			holding.Balance = &h.Balance
+		}
+		if h.Priced {
+			holding.Value = &h.Value
+		}
+		if h.PoolAddress != "" {
+			holding.PoolAddress = &h.PoolAddress
+		}
+		holdings = append(holdings, holding)
+	}
+
+	return v2JSON(w, &api.V2PortfolioValue{
+		ReferenceTokenAddress:  valuation.ReferenceTokenAddress,
+		ReferenceTokenSymbol:   valuation.ReferenceTokenSymbol,
+		ReferenceTokenDecimals: valuation.ReferenceTokenDecimals,
+		TotalValue:             valuation.TotalValue,
+		Holdings:               holdings,
+	}, a.freshness(w), partial)
+}
+
+func (a *API) v2TokenHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("address"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	tokenDetails, err := a.pgDataSource.TokenDetails(req.Context(), r.Address)
+	if err != nil {
+		return err
+	}
+
+	if tokenDetails == nil {
+		tokenDetails, err = a.chainDataSource.TokenDetails(req.Context(), r.Address)
+		if err != nil {
+			return err
+		}
+	}
+
+	return v2JSON(w, &api.V2Token{
+		TokenAddress:  tokenDetails.TokenAddress,
+		TokenSymbol:   tokenDetails.TokenSymbol,
+		TokenName:     tokenDetails.TokenName,
+		TokenDecimals: tokenDetails.TokenDecimals,
+		SinkAddress:   tokenDetails.SinkAddress,
+	}, nil, false)
+}
+
+func (a *API) v2TokenSearchHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	q, err := a.tokenSearchQuery(req)
+	if err != nil {
+		return err
+	}
+
+	matches, err := SearchTokens(req.Context(), a.logg, a.pgDataSource, a.registryCache, q)
+	if err != nil {
+		return err
+	}
+
+	tokens := make([]*api.V2TokenMatch, 0, len(matches))
+	for _, m := range matches {
+		tokens = append(tokens, &api.V2TokenMatch{
+			TokenAddress:  m.TokenAddress,
+			TokenSymbol:   m.TokenSymbol,
+			TokenName:     m.TokenName,
+			TokenDecimals: m.TokenDecimals,
+			Activity:      m.Activity,
+			Match:         m.Match,
+		})
+	}
+
+	return v2JSON(w, tokens, nil, false)
+}
+
+func (a *API) v2TopPoolsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	topPools, err := a.pgDataSource.TopPools(req.Context())
+	if err != nil {
+		return err
+	}
+
+	pools := make([]*api.V2Pool, 0, len(topPools))
+	for _, p := range topPools {
+		pools = append(pools, toV2Pool(p))
+	}
+
+	return v2JSON(w, pools, nil, false)
+}
+
+func (a *API) v2PoolHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("pool"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	poolDetails, err := a.pgDataSource.PoolDetails(req.Context(), r.Address)
+	if err != nil {
+		return err
+	}
+
+	if poolDetails == nil {
+		poolDetails, err = a.chainDataSource.PoolDetails(req.Context(), r.Address)
+		if err != nil {
+			return err
+		}
+	}
+
+	if poolDetails == nil {
+		return notFound("Pool not found")
+	}
+
+	return v2JSON(w, toV2Pool(poolDetails), nil, false)
+}
+
+func (a *API) v2PoolStateHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := PublicAddressParam{
+		Address: req.Param("pool"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	poolState, err := PoolState(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, r.Address)
+	if err != nil {
+		return err
+	}
+
+	tokens := make([]*api.V2PoolTokenState, 0, len(poolState.Tokens))
+	for _, t := range poolState.Tokens {
+		decimals, err := parseDecimals(t.TokenDecimals)
+		if err != nil {
+			return err
+		}
+
+		tokens = append(tokens, &api.V2PoolTokenState{
+			TokenAddress:      t.TokenAddress,
+			TokenSymbol:       t.TokenSymbol,
+			TokenDecimals:     decimals,
+			ExchangeRate:      t.ExchangeRate,
+			Balance:           t.Balance,
+			Limit:             t.Limit,
+			LimitSource:       t.LimitSource,
+			RemainingCapacity: t.RemainingCapacity,
+		})
+	}
+
+	return v2JSON(w, &api.V2PoolState{
+		PoolAddress:    poolState.PoolAddress,
+		PoolSymbol:     poolState.PoolSymbol,
+		LimiterAddress: poolState.LimiterAddress,
+		Tokens:         tokens,
+	}, a.freshness(w), false)
+}
+
+func (a *API) v2PoolAnalyticsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	analytics, err := a.poolAnalytics(req)
+	if err != nil {
+		return err
+	}
+
+	v2Analytics := &api.V2PoolAnalytics{
+		Metric: analytics.Metric,
+		From:   analytics.From,
+		To:     analytics.To,
+		Pools:  make([]*api.V2PoolActivity, 0, len(analytics.Pools)),
+	}
+	if analytics.ReferenceTokenAddress != "" {
+		v2Analytics.ReferenceToken = &api.V2ReferenceToken{
+			TokenAddress:  analytics.ReferenceTokenAddress,
+			TokenSymbol:   analytics.ReferenceTokenSymbol,
+			TokenDecimals: analytics.ReferenceTokenDecimals,
+		}
+	}
+
+	for _, p := range analytics.Pools {
+		pool := &api.V2PoolActivity{
+			PoolAddress: p.PoolAddress,
+			PoolName:    p.PoolName,
+			PoolSymbol:  p.PoolSymbol,
+			SwapCount:   p.SwapCount,
+			UniqueUsers: p.UniqueUsers,
+			Volume:      optionalString(p.Volume),
+			Tokens:      make([]*api.V2PoolTokenVolume, 0, len(p.Tokens)),
+			Days:        make([]*api.V2PoolActivityDay, 0, len(p.Days)),
+		}
+		for _, t := range p.Tokens {
+			pool.Tokens = append(pool.Tokens, &api.V2PoolTokenVolume{
+				TokenAddress:  t.TokenAddress,
+				TokenSymbol:   t.TokenSymbol,
+				TokenDecimals: t.TokenDecimals,
+				SwapCount:     t.SwapCount,
+				Volume:        t.Volume,
+				Priced:        t.Priced,
+				Value:         optionalString(t.Value),
+			})
+		}
+		for _, d := range p.Days {
+			pool.Days = append(pool.Days, &api.V2PoolActivityDay{
+				Date:        d.Date,
+				SwapCount:   d.SwapCount,
+				UniqueUsers: d.UniqueUsers,
+				Volume:      optionalString(d.Volume),
+			})
+		}
+		v2Analytics.Pools = append(v2Analytics.Pools, pool)
+	}
+
+	return v2JSON(w, v2Analytics, a.freshness(w), false)
+}
+
+func (a *API) v2PoolSearchHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	q, err := a.poolSearchQuery(req)
+	if err != nil {
+		return err
+	}
+
+	matches, err := SearchPools(req.Context(), a.logg, a.pgDataSource, q)
+	if err != nil {
+		return err
+	}
+
+	pools := make([]*api.V2PoolMatch, 0, len(matches))
+	for _, m := range matches {
+		pools = append(pools, &api.V2PoolMatch{
+			PoolAddress:     m.PoolAddress,
+			PoolName:        m.PoolName,
+			PoolSymbol:      m.PoolSymbol,
+			LimiterAddress:  m.LimiterAddress,
+			RegistryAddress: m.VoucherRegistry,
+			Activity:        m.Activity,
+			Match:           m.Match,
+		})
+	}
+
+	return v2JSON(w, pools, nil, false)
+}
+
+func (a *API) v2PoolBySymbolHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := SymbolParam{
+		Symbol: req.Param("symbol"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Symbol validation failed")
+	}
+
+	poolDetails, err := a.pgDataSource.PoolReverseDetails(req.Context(), r.Symbol)
+	if err != nil {
+		return err
+	}
+
+	if poolDetails == nil {
+		return notFound("Pool not found")
+	}
+
+	return v2JSON(w, toV2Pool(poolDetails), nil, false)
+}
+
+func (a *API) v2SwapFromHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := PoolVoucherList{
+		UserAddress: req.Param("address"),
+		PoolAddress: req.Param("pool"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	q, err := a.holdingsQuery(req)
+	if err != nil {
+		return err
+	}
+
+	poolDetails, err := a.pgDataSource.PoolDetails(req.Context(), u.PoolAddress)
+	if err != nil {
+		return err
+	}
+
+	if poolDetails == nil {
+		return notFound("Pool not found")
+	}
+
+	allowed, err := a.pgDataSource.PoolAllowedTokensForUser(req.Context(), u.UserAddress, u.PoolAddress)
+	if err != nil {
+		return err
+	}
+
+	merged, partial, err := a.mergeBalancesWithinSLA(req, allowed, u.UserAddress, q.IncludeEmpty)
+	if err != nil {
+		return err
+	}
+	sortHoldings(merged, q.Sort)
+
+	balances, err := toV2Balances(merged, !partial)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, balances, a.freshness(w), partial)
+}
+
+func (a *API) v2SwapFromAllowedHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := TokenList{
+		TokenAddress: req.Param("token"),
+		PoolAddress:  req.Param("pool"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	isAllowed, err := a.pgDataSource.PoolTokenAllowed(req.Context(), u.PoolAddress, u.TokenAddress)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, &api.V2SwapAllowed{Allowed: isAllowed}, nil, false)
+}
+
+// v2SwapToHandler lists the tokens the pool can swap into with the pool's
+// balances. With ?stables=true only stables are listed, without balances.
+func (a *API) v2SwapToHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := PublicAddressParam{
+		Address: req.Param("pool"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	if req.URL.Query().Get("stables") == "true" {
+		stables, err := a.pgDataSource.PoolAllowedStables(req.Context(), u.Address)
+		if err != nil {
+			return err
+		}
+
+		balances, err := toV2Balances(stables, false)
+		if err != nil {
+			return err
+		}
+
+		return v2JSON(w, balances, a.freshness(w), false)
+	}
+
+	allTokens, err := a.pgDataSource.PoolAllowedTokens(req.Context(), u.Address)
+	if err != nil {
+		return err
+	}
+
+	merged, partial, err := a.mergeBalancesWithinSLA(req, allTokens, u.Address, false)
+	if err != nil {
+		return err
+	}
+
+	balances, err := toV2Balances(merged, !partial)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, balances, a.freshness(w), partial)
+}
+
+func (a *API) v2SwapLimitHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := PoolLimits{
+		PoolAddress: req.Param("pool"),
+		UserAddress: req.Param("address"),
+		FromToken:   req.Param("from"),
+		ToToken:     req.Param("to"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	maxSwapInput, err := SwapLimit(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, u)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, &api.V2SwapLimit{MaxFromAmount: maxSwapInput.String()}, nil, false)
+}
+
+func (a *API) v2QuoteHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := ReverseQuoteParams{
+		PoolAddress: req.Param("pool"),
+		FromToken:   req.Param("from"),
+		ToToken:     req.Param("to"),
+		Amount:      req.Param("amount"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Parameter validation failed")
+	}
+
+	outputAmount := new(big.Int)
+	if _, ok := outputAmount.SetString(u.Amount, 10); !ok {
+		return badInput("Invalid amount format")
+	}
+
+	inputAmount, err := ReverseQuote(req.Context(), a.logg, a.pgDataSource, u, outputAmount)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, &api.V2Quote{
+		FromAmount: inputAmount.String(),
+		ToAmount:   outputAmount.String(),
+	}, nil, false)
+}
+
+func (a *API) v2CreditHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := PoolBalanceParams{
+		PoolAddress:  req.Param("pool"),
+		TokenAddress: req.Param("token"),
+		UserAddress:  req.Param("address"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	absoluteCredit, err := AbsoluteCredit(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, u)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, &api.V2Credit{Credit: absoluteCredit.String()}, nil, false)
+}
+
+func (a *API) v2CreditSendHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	u := CreditSendParams{
+		PoolAddress: req.Param("pool"),
+		UserAddress: req.Param("address"),
+		FromToken:   req.Param("from"),
+		ToToken:     req.Param("to"),
+	}
+
+	if err := a.validator.Validate(u); err != nil {
+		return badInput("Address validation failed")
+	}
+
+	maxFrom, maxTo, err := CreditSendLimits(req.Context(), a.logg, a.pgDataSource, a.chainDataSource, u)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, &api.V2CreditSend{
+		MaxFromAmount: maxFrom.String(),
+		MaxToAmount:   maxTo.String(),
+	}, nil, false)
+}
+
+func (a *API) v2AliasHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	r := AliasParam{
+		Alias: req.Param("alias"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return badInput("Alias validation failed")
+	}
+
+	aliasAddress, err := a.pgDataSource.ResolveAlias(req.Context(), r.Alias)
+	if err != nil {
+		return err
+	}
+
+	return v2JSON(w, &api.V2Alias{Address: aliasAddress.Address}, nil, false)
+}
+
+// toV2Balances converts holdings, keeping balances only when withBalances is
+// set since unmerged holdings carry an empty balance.
+func toV2Balances(holdings []*api.TokenHoldings, withBalances bool) ([]*api.V2TokenBalance, error) {
+	balances := make([]*api.V2TokenBalance, 0, len(holdings))
+	for _, h := range holdings {
+		decimals, err := parseDecimals(h.TokenDecimals)
+		if err != nil {
+			return nil, err
+		}
+
+		balance := &api.V2TokenBalance{
+			TokenAddress:  h.TokenAddress,
+			TokenSymbol:   h.TokenSymbol,
+			TokenDecimals: decimals,
+			Empty:         h.Empty,
+		}
+		if withBalances {
+			balance.Balance = &h.Balance
+		}
+		balances = append(balances, balance)
+	}
+
+	return balances, nil
+}
+
+func toV2Pool(p *api.PoolDetails) *api.V2Pool {
+	return &api.V2Pool{
+		PoolAddress:     p.PoolContractAdrress,
+		PoolName:        p.PoolName,
+		PoolSymbol:      p.PoolSymbol,
+		LimiterAddress:  p.LimiterAddress,
+		RegistryAddress: p.VoucherRegistry,
+	}
+}
+
+// parseDecimals converts the text token_decimals column used by the v1 models.
+// optionalString maps an empty string to null.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func parseDecimals(decimals string) (uint8, error) {
+	d, err := strconv.ParseUint(decimals, 10, 8)
+	if err != nil {
+		return 0, internalError("Invalid token decimals")
+	}
+	return uint8(d), nil
+}
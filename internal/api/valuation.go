@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"strconv"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/uptrace/bunrouter"
+)
+
+// portfolioValue backs the v1 and v2 valuation routes. Holdings whose balance
+// missed the deadline are returned unpriced with partial set.
+func (a *API) portfolioValue(req bunrouter.Request) (*api.PortfolioValuation, bool, error) {
+	r := PortfolioValueParams{
+		Address:        req.Param("address"),
+		ReferenceToken: req.Param("token"),
+	}
+
+	if err := a.validator.Validate(r); err != nil {
+		return nil, false, badInput("Address validation failed")
+	}
+
+	reference, err := a.pgDataSource.TokenDetails(req.Context(), r.ReferenceToken)
+	if err != nil {
+		return nil, false, err
+	}
+	if reference == nil {
+		return nil, false, notFound("Reference token not found")
+	}
+
+	tokenHoldings, err := a.pgDataSource.TokenHoldings(req.Context(), r.Address)
+	if err != nil {
+		return nil, false, err
+	}
+
+	merged, partial, err := a.mergeBalancesWithinSLA(req, tokenHoldings, r.Address, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	valuation, err := PortfolioValue(req.Context(), a.logg, a.pgDataSource, merged, reference)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return valuation, partial, nil
+}
+
+// PortfolioValue values each holding in the reference token using the
+// exchange rates of the most active pool that rates both tokens.
+func PortfolioValue(ctx context.Context, logg *slog.Logger, pg *data.PgChainData, holdings []*api.TokenHoldings, reference *api.TokenDetails) (*api.PortfolioValuation, error) {
+	logg = util.LoggerFromContext(ctx, logg)
+
+	tokenAddresses := make([]string, 0, len(holdings))
+	for _, h := range holdings {
+		tokenAddresses = append(tokenAddresses, h.TokenAddress)
+	}
+
+	rates, err := pg.TokenValuationRates(ctx, tokenAddresses, reference.TokenAddress)
+	if err != nil {
+		logg.Debug("Failed to get token valuation rates", "error", err)
+		return nil, err
+	}
+	logg.Debug("Valuation rates found", "holdings", len(holdings), "rates", len(rates))
+
+	return valueHoldings(holdings, reference, rates), nil
+}
+
+// valueHoldings prices every holding it has a rate for. Holdings without a
+// rate, balance or valid decimals are marked unpriced and left out of the
+// total rather than counted as zero.
+func valueHoldings(holdings []*api.TokenHoldings, reference *api.TokenDetails, rates []*api.TokenValuationRate) *api.PortfolioValuation {
+	ratesByToken := make(map[string]*api.TokenValuationRate, len(rates))
+	for _, r := range rates {
+		ratesByToken[r.TokenAddress] = r
+	}
+
+	total := new(big.Int)
+	values := make([]*api.HoldingValue, 0, len(holdings))
+	for _, h := range holdings {
+		value := &api.HoldingValue{
+			TokenAddress:  h.TokenAddress,
+			TokenSymbol:   h.TokenSymbol,
+			TokenDecimals: h.TokenDecimals,
+			Balance:       h.Balance,
+		}
+		values = append(values, value)
+
+		balance, ok := new(big.Int).SetString(h.Balance, 10)
+		if !ok {
+			continue
+		}
+
+		if h.TokenAddress == reference.TokenAddress {
+			value.Priced = true
+			value.Value = balance.String()
+			total.Add(total, balance)
+			continue
+		}
+
+		rate, ok := ratesByToken[h.TokenAddress]
+		if !ok {
+			continue
+		}
+
+		decimals, err := strconv.ParseUint(h.TokenDecimals, 10, 8)
+		if err != nil {
+			continue
+		}
+
+		converted := CalculateValue(balance, rate.TokenRate, rate.ReferenceRate, uint8(decimals), reference.TokenDecimals)
+		value.Priced = true
+		value.Value = converted.String()
+		value.PoolAddress = rate.PoolAddress
+		total.Add(total, converted)
+	}
+
+	return &api.PortfolioValuation{
+		ReferenceTokenAddress:  reference.TokenAddress,
+		ReferenceTokenSymbol:   reference.TokenSymbol,
+		ReferenceTokenDecimals: reference.TokenDecimals,
+		TotalValue:             total.String(),
+		Holdings:               values,
+	}
+}
+
+// CalculateValue converts amount into the reference token the way a pool
+// swap would, rounding down:
+// value = amount * tokenRate * 10^referenceDecimals / (referenceRate * 10^tokenDecimals)
+func CalculateValue(amount *big.Int, tokenRate, referenceRate uint64, tokenDecimals, referenceDecimals uint8) *big.Int {
+	if tokenRate == 0 {
+		tokenRate = 10_000
+	}
+	if referenceRate == 0 {
+		referenceRate = 10_000
+	}
+
+	pow10Token := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(tokenDecimals)), nil)
+	pow10Reference := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(referenceDecimals)), nil)
+
+	numerator := new(big.Int).Mul(amount, new(big.Int).SetUint64(tokenRate))
+	numerator.Mul(numerator, pow10Reference)
+
+	denominator := new(big.Int).Mul(new(big.Int).SetUint64(referenceRate), pow10Token)
+
+	return numerator.Div(numerator, denominator)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestCalculateValue(t *testing.T) {
+	tests := []struct {
+		name              string
+		amount            *big.Int
+		tokenRate         uint64
+		referenceRate     uint64
+		tokenDecimals     uint8
+		referenceDecimals uint8
+		want              *big.Int
+	}{
+		{
+			name:              "inverse of the reverse quote",
+			amount:            big.NewInt(7752),
+			tokenRate:         1_290_000,
+			referenceRate:     10_000,
+			tokenDecimals:     6,
+			referenceDecimals: 6,
+			want:              big.NewInt(1_000_008),
+		},
+		{
+			name:              "scales decimals and rounds down",
+			amount:            big.NewInt(1_500_001),
+			tokenRate:         10_000,
+			referenceRate:     20_000,
+			tokenDecimals:     6,
+			referenceDecimals: 18,
+			want:              new(big.Int).Mul(big.NewInt(7_500_005), big.NewInt(100_000_000_000)),
+		},
+		{
+			name:              "unset rates default to par",
+			amount:            big.NewInt(3_000_000_000_000_000),
+			tokenDecimals:     18,
+			referenceDecimals: 6,
+			want:              big.NewInt(3_000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateValue(tt.amount, tt.tokenRate, tt.referenceRate, tt.tokenDecimals, tt.referenceDecimals)
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("CalculateValue() = %s, want %s", got.String(), tt.want.String())
+			}
+		})
+	}
+}
+
+func TestValueHoldings(t *testing.T) {
+	const (
+		cUSD = "0x765DE816845861e75A25fCA122bb6898B8B1282a"
+		srf  = "0xcebA9300f2b948710d2653dD7B07f33A8B32118C"
+		mbao = "0xf1AB7Ab052140653Ceb69149F22d72ea9CD5eCc6"
+		pool = "0x045Dc382332aBFb9155FF7D1bD9981fF142492a9"
+	)
+
+	reference := &api.TokenDetails{TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: 6}
+	holdings := []*api.TokenHoldings{
+		{TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Balance: "2000000"},
+		{TokenAddress: srf, TokenSymbol: "SRF", TokenDecimals: "6", Balance: "500000"},
+		{TokenAddress: mbao, TokenSymbol: "MBAO", TokenDecimals: "6", Balance: "100"},
+	}
+	rates := []*api.TokenValuationRate{
+		{TokenAddress: srf, PoolAddress: pool, TokenRate: 5_000, ReferenceRate: 10_000},
+	}
+
+	got := valueHoldings(holdings, reference, rates)
+
+	if got.TotalValue != "2250000" {
+		t.Errorf("TotalValue = %s, want 2250000", got.TotalValue)
+	}
+
+	want := []api.HoldingValue{
+		{TokenAddress: cUSD, TokenSymbol: "cUSD", TokenDecimals: "6", Balance: "2000000", Priced: true, Value: "2000000"},
+		{TokenAddress: srf, TokenSymbol: "SRF", TokenDecimals: "6", Balance: "500000", Priced: true, Value: "250000", PoolAddress: pool},
+		{TokenAddress: mbao, TokenSymbol: "MBAO", TokenDecimals: "6", Balance: "100"},
+	}
+	for i, w := range want {
+		if *got.Holdings[i] != w {
+			t.Errorf("Holdings[%d] = %+v, want %+v", i, *got.Holdings[i], w)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/lmittmann/w3"
+	"github.com/lmittmann/w3/w3types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrRPCUnavailable wraps transport level RPC failures (as opposed to
+// reverted calls, which are returned as w3.CallErrors).
+var ErrRPCUnavailable = errors.New("rpc unavailable")
+
+// call sends a batch of RPC calls and records its size, latency and
+// error/revert counts labelled by method.
+func (c *Chain) call(ctx context.Context, method string, calls ...w3types.RPCCaller) error {
+	ctx, done := c.observe(ctx, method, len(calls))
+	err := c.chain.Client.CallCtx(ctx, calls...)
+	done(err, func() []any {
+		params := make([]any, 0, len(calls))
+		for _, call := range calls {
+			if elem, err := call.CreateRequest(); err == nil {
+				params = append(params, elem.Args...)
+			}
+		}
+		return params
+	})
+
+	return wrapRPCError(err)
+}
+
+func wrapRPCError(err error) error {
+	var batchErr w3.CallErrors
+	if err == nil || errors.As(err, &batchErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrRPCUnavailable, err)
+}
+
+// observe starts a span for an RPC batch. The returned func records metrics
+// and ends the span, params is only evaluated when the batch is slow enough
+// to be logged.
+func (c *Chain) observe(ctx context.Context, method string, size int) (context.Context, func(err error, params func() []any)) {
+	startedAt := time.Now()
+	ctx, span := tracer.Start(ctx, "rpc "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("jsonrpc"),
+			semconv.RPCMethod(method),
+			attribute.Int("rpc.batch_size", size),
+		),
+	)
+
+	return ctx, func(err error, params func() []any) {
+		elapsed := time.Since(startedAt)
+
+		metrics.GetOrCreateHistogram(fmt.Sprintf(`rpc_batch_duration_seconds{method=%q}`, method)).Update(elapsed.Seconds())
+		metrics.GetOrCreateHistogram(fmt.Sprintf(`rpc_batch_size{method=%q}`, method)).Update(float64(size))
+
+		var batchErr w3.CallErrors
+		if errors.As(err, &batchErr) {
+			reverts := 0
+			for _, callErr := range batchErr {
+				if callErr != nil {
+					reverts++
+				}
+			}
+			metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_call_reverts_total{method=%q}`, method)).Add(reverts)
+			span.SetAttributes(attribute.Int("rpc.reverts", reverts))
+		} else if err != nil {
+			metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_batch_errors_total{method=%q}`, method)).Inc()
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		if c.slowThreshold > 0 && elapsed > c.slowThreshold {
+			util.LoggerFromContext(ctx, c.logg).Warn("slow rpc call", "method", method, "size", size, "elapsed", elapsed, "params", params(), "error", err)
+		}
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"errors"
 	"log/slog"
 	"math/big"
+	"slices"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/grassrootseconomics/ethutils"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
 	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
 	"github.com/lmittmann/w3"
 	"github.com/lmittmann/w3/module/eth"
@@ -20,14 +24,21 @@ type (
 		RPCEndpoint     string
 		Logg            *slog.Logger
 		BalancesScanner string
+		// SlowCallThreshold logs RPC batches (with their parameters) that take longer, 0 disables it
+		SlowCallThreshold time.Duration
 	}
 
 	Chain struct {
-		logg  *slog.Logger
-		chain *ethutils.Provider
+		logg          *slog.Logger
+		chain         *ethutils.Provider
+		chainID       int64
+		slowThreshold time.Duration
 	}
 )
 
+// defaultTokenDetailsBatchSize is the number of tokens per TokensDetails batch.
+const defaultTokenDetailsBatchSize = 50
+
 var (
 	nameGetter            = w3.MustNewFunc("name()", "string")
 	symbolGetter          = w3.MustNewFunc("symbol()", "string")
@@ -36,47 +47,98 @@ var (
 	limiterAddressGetter  = w3.MustNewFunc("tokenLimiter()", "address")
 	registryAddressGetter = w3.MustNewFunc("tokenRegistry()", "address")
 	exists                = w3.MustNewFunc("have(address)", "bool")
+	entryCountGetter      = w3.MustNewFunc("entryCount()", "uint256")
+	entryGetter           = w3.MustNewFunc("entry(uint256)", "address")
 	balanceOf             = w3.MustNewFunc("balanceOf(address)", "uint256")
 	limitOf               = w3.MustNewFunc("limitOf(address, address)", "uint256")
 )
 
 func NewChainProvider(o ChainOpts) *Chain {
 	return &Chain{
-		logg:  o.Logg,
-		chain: ethutils.NewProvider(o.RPCEndpoint, o.ChainID, ethutils.WithBalanceScannerAddress(o.BalancesScanner)),
+		logg:          o.Logg,
+		chain:         ethutils.NewProvider(o.RPCEndpoint, o.ChainID, ethutils.WithBalanceScannerAddress(o.BalancesScanner)),
+		chainID:       o.ChainID,
+		slowThreshold: o.SlowCallThreshold,
 	}
 }
 
+// MergeTokenBalances sets the owner's balance on every holding and drops the
+// ones with no balance.
 func (c *Chain) MergeTokenBalances(ctx context.Context, input []*api.TokenHoldings, ownerAddress string) ([]*api.TokenHoldings, error) {
+	return c.mergeTokenBalances(ctx, input, ownerAddress, false)
+}
+
+// MergeAllTokenBalances is MergeTokenBalances but keeps holdings with no
+// balance, flagged as empty.
+func (c *Chain) MergeAllTokenBalances(ctx context.Context, input []*api.TokenHoldings, ownerAddress string) ([]*api.TokenHoldings, error) {
+	return c.mergeTokenBalances(ctx, input, ownerAddress, true)
+}
+
+func (c *Chain) mergeTokenBalances(ctx context.Context, input []*api.TokenHoldings, ownerAddress string, keepEmpty bool) ([]*api.TokenHoldings, error) {
 	if len(input) == 0 {
 		return input, nil
 	}
 
-	addresses := make([]common.Address, len(input))
+	tokenAddresses := make([]string, len(input))
 	for i, holding := range input {
-		addresses[i] = common.HexToAddress(holding.TokenAddress)
+		tokenAddresses[i] = holding.TokenAddress
 	}
 
-	tokenBalances, err := c.chain.TokensBalance(ctx, common.HexToAddress(ownerAddress), addresses)
+	tokenBalances, err := c.TokenBalances(ctx, ownerAddress, tokenAddresses)
 	if err != nil {
 		return nil, err
 	}
 
-	zero := big.NewInt(0)
-
 	j := 0
 	for i, holding := range input {
-		contractAddress := addresses[i]
-		if balance, exists := tokenBalances[contractAddress]; exists && balance.Cmp(zero) > 0 {
+		if balance := tokenBalances[i]; balance.Sign() > 0 {
 			holding.Balance = balance.String()
 			input[j] = holding
 			j++
+		} else if keepEmpty {
+			holding.Balance = "0"
+			holding.Empty = true
+			input[j] = holding
+			j++
 		}
 	}
 
 	return input[:j], nil
 }
 
+// TokenBalances returns the owner's balance of every token, in input order,
+// in one balance scanner call. Tokens the scanner skips have a zero balance.
+func (c *Chain) TokenBalances(ctx context.Context, ownerAddress string, tokenAddresses []string) ([]*big.Int, error) {
+	if len(tokenAddresses) == 0 {
+		return nil, nil
+	}
+
+	addresses := make([]common.Address, len(tokenAddresses))
+	for i, tokenAddress := range tokenAddresses {
+		addresses[i] = common.HexToAddress(tokenAddress)
+	}
+
+	tracedCtx, done := c.observe(ctx, "tokens_balance", len(addresses))
+	tokenBalances, err := c.chain.TokensBalance(tracedCtx, common.HexToAddress(ownerAddress), addresses)
+	done(err, func() []any {
+		return []any{ownerAddress, addresses}
+	})
+	if err != nil {
+		return nil, wrapRPCError(err)
+	}
+
+	balances := make([]*big.Int, len(addresses))
+	for i, contractAddress := range addresses {
+		if balance, exists := tokenBalances[contractAddress]; exists && balance != nil {
+			balances[i] = balance
+		} else {
+			balances[i] = new(big.Int)
+		}
+	}
+
+	return balances, nil
+}
+
 func (c *Chain) TokenDetails(ctx context.Context, input string) (*api.TokenDetails, error) {
 	contractAddress := w3.A(input)
 
@@ -89,8 +151,9 @@ func (c *Chain) TokenDetails(ctx context.Context, input string) (*api.TokenDetai
 		batchErr w3.CallErrors
 	)
 
-	if err := c.chain.Client.CallCtx(
+	if err := c.call(
 		ctx,
+		"token_details",
 		eth.CallFunc(contractAddress, nameGetter).Returns(&tokenName),
 		eth.CallFunc(contractAddress, symbolGetter).Returns(&tokenSymbol),
 		eth.CallFunc(contractAddress, decimalsGetter).Returns(&tokenDecimals),
@@ -100,8 +163,9 @@ func (c *Chain) TokenDetails(ctx context.Context, input string) (*api.TokenDetai
 		return nil, err
 	}
 
-	if err := c.chain.Client.CallCtx(
+	if err := c.call(
 		ctx,
+		"token_sink_address",
 		eth.CallFunc(contractAddress, sinkAddressGetter).Returns(&sinkAddress),
 	); err != nil {
 		// This will most likely revert if the contract does not have a sinkAddress
@@ -130,8 +194,9 @@ func (c *Chain) PoolDetails(ctx context.Context, input string) (*api.PoolDetails
 		batchErr w3.CallErrors
 	)
 
-	if err := c.chain.Client.CallCtx(
+	if err := c.call(
 		ctx,
+		"pool_details",
 		eth.CallFunc(contractAddress, nameGetter).Returns(&poolName),
 		eth.CallFunc(contractAddress, symbolGetter).Returns(&poolSymbol),
 		eth.CallFunc(contractAddress, registryAddressGetter).Returns(&tokenRegistryAddress),
@@ -160,8 +225,9 @@ func (c *Chain) MaxLimit(ctx context.Context, initator string, poolAddress strin
 		batchErr w3.CallErrors
 	)
 
-	if err := c.chain.Client.CallCtx(
+	if err := c.call(
 		ctx,
+		"max_limit",
 		eth.CallFunc(common.HexToAddress(inToken), balanceOf, common.HexToAddress(initator)).Returns(&initiatorInTokenBalance),
 		eth.CallFunc(common.HexToAddress(outToken), balanceOf, common.HexToAddress(poolAddress)).Returns(&outTokenBalance),
 		eth.CallFunc(common.HexToAddress(limiterAddress), limitOf, common.HexToAddress(inToken), common.HexToAddress(poolAddress)).Returns(&inTokenLimit),
@@ -170,11 +236,45 @@ func (c *Chain) MaxLimit(ctx context.Context, initator string, poolAddress strin
 	} else if err != nil {
 		return nil, err
 	}
-	c.logg.Info("Max limit calculation", "initiatorInTokenBalance", initiatorInTokenBalance, "outTokenBalance", outTokenBalance, "inTokenLimit", inTokenLimit)
+	util.LoggerFromContext(ctx, c.logg).Info("Max limit calculation", "initiatorInTokenBalance", initiatorInTokenBalance, "outTokenBalance", outTokenBalance, "inTokenLimit", inTokenLimit)
 
 	return min([]*big.Int{inTokenLimit, initiatorInTokenBalance, outTokenBalance}), nil
 }
 
+// PoolTokenLimits reads the limiter's limit of every token for the pool, in
+// input order, in one batch. The limit of a token whose limitOf call reverts
+// is logged and left nil so one bad token doesn't fail the whole pool.
+func (c *Chain) PoolTokenLimits(ctx context.Context, limiterAddress string, poolAddress string, tokenAddresses []string) ([]*big.Int, error) {
+	if len(tokenAddresses) == 0 {
+		return nil, nil
+	}
+
+	var (
+		limits = make([]*big.Int, len(tokenAddresses))
+		calls  = make([]w3types.RPCCaller, len(tokenAddresses))
+
+		batchErr w3.CallErrors
+	)
+
+	for i, tokenAddress := range tokenAddresses {
+		calls[i] = eth.CallFunc(common.HexToAddress(limiterAddress), limitOf, common.HexToAddress(tokenAddress), common.HexToAddress(poolAddress)).Returns(&limits[i])
+	}
+
+	err := c.call(ctx, "pool_token_limits", calls...)
+	if err != nil && !errors.As(err, &batchErr) {
+		return nil, err
+	}
+
+	for i, tokenAddress := range tokenAddresses {
+		if failed := callsFailed(batchErr, i, i+1); failed != nil {
+			util.LoggerFromContext(ctx, c.logg).Error("failed to get token limit", "limiter", limiterAddress, "address", tokenAddress, "error", failed)
+			limits[i] = nil
+		}
+	}
+
+	return limits, nil
+}
+
 func (c *Chain) GetSwapBalances(ctx context.Context, initiator string, poolAddress string, inToken string, outToken string) (*big.Int, *big.Int, *big.Int, error) {
 	var (
 		initiatorInTokenBalance *big.Int
@@ -184,8 +284,9 @@ func (c *Chain) GetSwapBalances(ctx context.Context, initiator string, poolAddre
 		batchErr w3.CallErrors
 	)
 
-	if err := c.chain.Client.CallCtx(
+	if err := c.call(
 		ctx,
+		"swap_balances",
 		eth.CallFunc(common.HexToAddress(inToken), balanceOf, common.HexToAddress(initiator)).Returns(&initiatorInTokenBalance),
 		eth.CallFunc(common.HexToAddress(inToken), balanceOf, common.HexToAddress(poolAddress)).Returns(&poolInTokenBalance),
 		eth.CallFunc(common.HexToAddress(outToken), balanceOf, common.HexToAddress(poolAddress)).Returns(&poolOutTokenBalance),
@@ -195,7 +296,7 @@ func (c *Chain) GetSwapBalances(ctx context.Context, initiator string, poolAddre
 		return nil, nil, nil, err
 	}
 
-	c.logg.Info("Swap balances retrieved", "initiatorInTokenBalance", initiatorInTokenBalance, "poolInTokenBalance", poolInTokenBalance, "poolOutTokenBalance", poolOutTokenBalance)
+	util.LoggerFromContext(ctx, c.logg).Info("Swap balances retrieved", "initiatorInTokenBalance", initiatorInTokenBalance, "poolInTokenBalance", poolInTokenBalance, "poolOutTokenBalance", poolOutTokenBalance)
 
 	return initiatorInTokenBalance, poolInTokenBalance, poolOutTokenBalance, nil
 }
@@ -211,8 +312,9 @@ func (c *Chain) TokensExistsInIndex(ctx context.Context, index string, input []*
 
 	var batchErr w3.CallErrors
 
-	if err := c.chain.Client.CallCtx(
+	if err := c.call(
 		ctx,
+		"tokens_exists_in_index",
 		calls...,
 	); errors.As(err, &batchErr) {
 		return nil, batchErr
@@ -250,8 +352,9 @@ func min(values []*big.Int) *big.Int {
 func (c *Chain) TokenExistsInIndex(ctx context.Context, index string, tokenAddress string) (bool, error) {
 	var existsResp bool
 
-	err := c.chain.Client.CallCtx(
+	err := c.call(
 		ctx,
+		"token_exists_in_index",
 		eth.CallFunc(common.HexToAddress(index), exists, common.HexToAddress(tokenAddress)).Returns(&existsResp),
 	)
 	if err != nil {
@@ -260,19 +363,31 @@ func (c *Chain) TokenExistsInIndex(ctx context.Context, index string, tokenAddre
 	return existsResp, nil
 }
 
-// TODO: This is extremely inefficient dee to the number of round trips. We need to cache all this info
+// AllTokensInIndex returns the details of every token in the index. It walks
+// the whole index and should not be called on request paths, see
+// RegistryCache.
 func (c *Chain) AllTokensInIndex(ctx context.Context, index string) ([]*api.TokenDetails, error) {
-	tokenDetails := make([]*api.TokenDetails, 0)
+	addresses, err := c.TokenIndexAddresses(ctx, index)
+	if err != nil {
+		return nil, err
+	}
 
+	return c.TokensDetails(ctx, addresses, defaultTokenDetailsBatchSize)
+}
+
+// TokenIndexAddresses returns every non zero address in the index, in index
+// order.
+func (c *Chain) TokenIndexAddresses(ctx context.Context, index string) ([]string, error) {
 	tokenIndexIter, err := c.chain.NewBatchIterator(ctx, common.HexToAddress(index))
 	if err != nil {
-		return nil, err
+		return nil, wrapRPCError(err)
 	}
 
+	var addresses []string
 	for {
 		tokenIndexBatch, err := tokenIndexIter.Next(ctx)
 		if err != nil {
-			return nil, err
+			return nil, wrapRPCError(err)
 		}
 		if tokenIndexBatch == nil {
 			break
@@ -280,24 +395,128 @@ func (c *Chain) AllTokensInIndex(ctx context.Context, index string) ([]*api.Toke
 
 		for _, address := range tokenIndexBatch {
 			if address != ethutils.ZeroAddress {
-				tokenDetail, err := c.TokenDetails(ctx, address.Hex())
-				if err != nil {
-					c.logg.Error("failed to get token details", "address", address.Hex(), "error", err)
-					continue
-				}
-				tokenDetails = append(tokenDetails, tokenDetail)
+				addresses = append(addresses, address.Hex())
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// TokenIndexEntryCount returns the number of entries in the index.
+func (c *Chain) TokenIndexEntryCount(ctx context.Context, index string) (uint64, error) {
+	var count *big.Int
+
+	if err := c.call(ctx, "token_index_entry_count", eth.CallFunc(common.HexToAddress(index), entryCountGetter).Returns(&count)); err != nil {
+		return 0, err
+	}
+	return count.Uint64(), nil
+}
+
+// TokenIndexEntries returns the index entries in [from, to) with batchSize
+// entries per RPC batch. Unlike TokenIndexAddresses it can start past the
+// first entry, and zero addresses are kept so positions match the index.
+func (c *Chain) TokenIndexEntries(ctx context.Context, index string, from, to uint64, batchSize int) ([]string, error) {
+	if batchSize <= 0 {
+		batchSize = defaultTokenDetailsBatchSize
+	}
+
+	entries := make([]string, 0, to-from)
+	for start := from; start < to; start += uint64(batchSize) {
+		end := start + uint64(batchSize)
+		if end > to {
+			end = to
+		}
+
+		var (
+			batch = make([]common.Address, end-start)
+			calls = make([]w3types.RPCCaller, 0, end-start)
+		)
+		for i := range batch {
+			calls = append(calls, eth.CallFunc(common.HexToAddress(index), entryGetter, new(big.Int).SetUint64(start+uint64(i))).Returns(&batch[i]))
+		}
+
+		if err := c.call(ctx, "token_index_entries", calls...); err != nil {
+			return nil, err
+		}
+		for _, address := range batch {
+			entries = append(entries, address.Hex())
+		}
+	}
+
+	return entries, nil
+}
+
+// TokensDetails fetches token details with batchSize tokens per RPC batch.
+// Tokens whose name, symbol or decimals call reverts are logged and left
+// out, a reverted sinkAddress call is returned as the zero address like
+// TokenDetails does.
+func (c *Chain) TokensDetails(ctx context.Context, addresses []string, batchSize int) ([]*api.TokenDetails, error) {
+	if batchSize <= 0 {
+		batchSize = defaultTokenDetailsBatchSize
+	}
+
+	const callsPerToken = 4
+	tokenDetails := make([]*api.TokenDetails, 0, len(addresses))
+
+	for batch := range slices.Chunk(addresses, batchSize) {
+		var (
+			details       = make([]api.TokenDetails, len(batch))
+			sinkAddresses = make([]common.Address, len(batch))
+			calls         = make([]w3types.RPCCaller, 0, len(batch)*callsPerToken)
+
+			batchErr w3.CallErrors
+		)
+
+		for i, address := range batch {
+			contractAddress := common.HexToAddress(address)
+			calls = append(calls,
+				eth.CallFunc(contractAddress, nameGetter).Returns(&details[i].TokenName),
+				eth.CallFunc(contractAddress, symbolGetter).Returns(&details[i].TokenSymbol),
+				eth.CallFunc(contractAddress, decimalsGetter).Returns(&details[i].TokenDecimals),
+				eth.CallFunc(contractAddress, sinkAddressGetter).Returns(&sinkAddresses[i]),
+			)
+		}
+
+		err := c.call(ctx, "tokens_details", calls...)
+		if err != nil && !errors.As(err, &batchErr) {
+			return nil, err
+		}
+
+		for i, address := range batch {
+			if failed := callsFailed(batchErr, i*callsPerToken, i*callsPerToken+3); failed != nil {
+				util.LoggerFromContext(ctx, c.logg).Error("failed to get token details", "address", address, "error", failed)
+				continue
 			}
+			if callsFailed(batchErr, i*callsPerToken+3, i*callsPerToken+4) != nil {
+				sinkAddresses[i] = ethutils.ZeroAddress
+			}
+
+			details[i].TokenAddress = address
+			details[i].SinkAddress = sinkAddresses[i].Hex()
+			tokenDetails = append(tokenDetails, &details[i])
 		}
 	}
 
 	return tokenDetails, nil
 }
 
+// callsFailed returns the first error of the calls in [from, to) of a batch.
+func callsFailed(batchErr w3.CallErrors, from, to int) error {
+	for i := from; i < to && i < len(batchErr); i++ {
+		if batchErr[i] != nil {
+			return batchErr[i]
+		}
+	}
+	return nil
+}
+
 func (c *Chain) TokenBalance(ctx context.Context, userAddress, tokenAddress string) (*big.Int, error) {
 	var balance *big.Int
 
-	err := c.chain.Client.CallCtx(
+	err := c.call(
 		ctx,
+		"token_balance",
 		eth.CallFunc(common.HexToAddress(tokenAddress), balanceOf, common.HexToAddress(userAddress)).Returns(&balance),
 	)
 	if err != nil {
@@ -306,3 +525,41 @@ func (c *Chain) TokenBalance(ctx context.Context, userAddress, tokenAddress stri
 
 	return balance, nil
 }
+
+// ExpectedChainID returns the configured chain id.
+func (c *Chain) ExpectedChainID() int64 {
+	return c.chainID
+}
+
+// ChainID returns the chain id reported by the RPC node.
+func (c *Chain) ChainID(ctx context.Context) (uint64, error) {
+	var chainID uint64
+
+	if err := c.call(ctx, "chain_id", eth.ChainID().Returns(&chainID)); err != nil {
+		return 0, err
+	}
+
+	return chainID, nil
+}
+
+// HeadBlock returns the number and timestamp of the RPC node's latest block.
+func (c *Chain) HeadBlock(ctx context.Context) (uint64, time.Time, error) {
+	var header *types.Header
+
+	if err := c.call(ctx, "head_block", eth.HeaderByNumber(nil).Returns(&header)); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return header.Number.Uint64(), time.Unix(int64(header.Time), 0), nil
+}
+
+// ContractCode returns the deployed bytecode at address, empty if there is none.
+func (c *Chain) ContractCode(ctx context.Context, address string) ([]byte, error) {
+	var code []byte
+
+	if err := c.call(ctx, "contract_code", eth.Code(common.HexToAddress(address), nil).Returns(&code)); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ussd-data-service/internal/rpctest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+// newFakeRPC starts a fake RPC node and returns a chain data source using it.
+func newFakeRPC(t *testing.T) (*rpctest.Server, *Chain) {
+	t.Helper()
+
+	rpc := rpctest.NewServer(t)
+	return rpc, NewChainProvider(ChainOpts{
+		ChainID:     1,
+		RPCEndpoint: rpc.URL(),
+		Logg:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func TestTokensDetails(t *testing.T) {
+	rpc, chain := newFakeRPC(t)
+
+	var (
+		sink      = common.HexToAddress("0x9999999999999999999999999999999999999999")
+		tokenA    = "0x000000000000000000000000000000000000000A"
+		tokenB    = "0x000000000000000000000000000000000000000B"
+		tokenC    = "0x000000000000000000000000000000000000000C"
+		tokenD    = "0x000000000000000000000000000000000000000D"
+		setTokens = func(address, name, symbol string, decimals uint8) {
+			rpc.Set(address, nameGetter, nil, name)
+			rpc.Set(address, symbolGetter, nil, symbol)
+			rpc.Set(address, decimalsGetter, nil, decimals)
+		}
+	)
+
+	// B's decimals call reverts so it is left out, C has no sink address
+	setTokens(tokenA, "Token A", "A", 6)
+	rpc.Set(tokenA, sinkAddressGetter, nil, sink)
+	rpc.Set(tokenB, nameGetter, nil, "Token B")
+	rpc.Set(tokenB, symbolGetter, nil, "B")
+	rpc.Set(tokenB, sinkAddressGetter, nil, sink)
+	setTokens(tokenC, "Token C", "C", 18)
+	setTokens(tokenD, "Token D", "D", 2)
+	rpc.Set(tokenD, sinkAddressGetter, nil, sink)
+
+	got, err := chain.TokensDetails(context.Background(), []string{tokenA, tokenB, tokenC, tokenD}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*api.TokenDetails{
+		{TokenAddress: tokenA, TokenSymbol: "A", TokenDecimals: 6, SinkAddress: sink.Hex(), TokenName: "Token A"},
+		{TokenAddress: tokenC, TokenSymbol: "C", TokenDecimals: 18, SinkAddress: common.Address{}.Hex(), TokenName: "Token C"},
+		{TokenAddress: tokenD, TokenSymbol: "D", TokenDecimals: 2, SinkAddress: sink.Hex(), TokenName: "Token D"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TokensDetails() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if batches := rpc.CallBatches(); !reflect.DeepEqual(batches, []int{12, 4}) {
+		t.Errorf("batch sizes = %v, want [12 4]", batches)
+	}
+}
+
+func TestPoolTokenLimits(t *testing.T) {
+	rpc, chain := newFakeRPC(t)
+
+	var (
+		limiter = "0x1111111111111111111111111111111111111111"
+		pool    = "0x2222222222222222222222222222222222222222"
+		tokenA  = "0x000000000000000000000000000000000000000A"
+		tokenB  = "0x000000000000000000000000000000000000000B"
+		tokenC  = "0x000000000000000000000000000000000000000C"
+	)
+
+	// B's limitOf call reverts
+	rpc.Set(limiter, limitOf, []any{common.HexToAddress(tokenA), common.HexToAddress(pool)}, big.NewInt(1000))
+	rpc.Set(limiter, limitOf, []any{common.HexToAddress(tokenC), common.HexToAddress(pool)}, big.NewInt(0))
+
+	got, err := chain.PoolTokenLimits(context.Background(), limiter, pool, []string{tokenA, tokenB, tokenC})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*big.Int{big.NewInt(1000), nil, big.NewInt(0)}
+	if len(got) != len(want) {
+		t.Fatalf("PoolTokenLimits() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if (got[i] == nil) != (want[i] == nil) || (got[i] != nil && got[i].Cmp(want[i]) != 0) {
+			t.Errorf("limit %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCallsFailed(t *testing.T) {
+	errReverted := errors.New("execution reverted")
+	batchErr := []error{nil, nil, nil, nil, nil, errReverted, nil, nil}
+
+	tests := []struct {
+		name     string
+		batchErr []error
+		from, to int
+		want     error
+	}{
+		{name: "first token succeeded", batchErr: batchErr, from: 0, to: 4},
+		{name: "second token failed", batchErr: batchErr, from: 4, to: 7, want: errReverted},
+		{name: "failure outside the range", batchErr: batchErr, from: 6, to: 8},
+		{name: "no call errors", from: 0, to: 4},
+		{name: "range past the batch", batchErr: batchErr[:2], from: 0, to: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := callsFailed(tt.batchErr, tt.from, tt.to); !errors.Is(got, tt.want) {
+				t.Errorf("callsFailed(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	DenylistOpts struct {
+		Logg            *slog.Logger
+		PgDataSource    *PgChainData
+		File            string
+		RefreshInterval time.Duration
+	}
+
+	// Denylist holds revoked JWT ids and subjects. Entries are merged from
+	// Postgres and/or a JSON file and swapped in atomically on every refresh.
+	Denylist struct {
+		logg            *slog.Logger
+		pgDataSource    *PgChainData
+		file            string
+		refreshInterval time.Duration
+		entries         atomic.Pointer[denylistEntries]
+	}
+
+	RevokedToken struct {
+		JTI     string `json:"jti" db:"jti"`
+		Subject string `json:"subject" db:"subject"`
+	}
+
+	denylistEntries struct {
+		jtis     map[string]struct{}
+		subjects map[string]struct{}
+	}
+)
+
+const defaultDenylistRefreshInterval = time.Minute
+
+func NewDenylist(o DenylistOpts) *Denylist {
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = defaultDenylistRefreshInterval
+	}
+
+	d := &Denylist{
+		logg:            o.Logg,
+		pgDataSource:    o.PgDataSource,
+		file:            o.File,
+		refreshInterval: o.RefreshInterval,
+	}
+	d.entries.Store(&denylistEntries{
+		jtis:     map[string]struct{}{},
+		subjects: map[string]struct{}{},
+	})
+
+	return d
+}
+
+// Start refreshes the denylist periodically until ctx is cancelled. A failed
+// refresh keeps the previously loaded entries.
+func (d *Denylist) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Refresh(ctx); err != nil {
+				d.logg.Error("failed to refresh token denylist", "error", err)
+			}
+		}
+	}
+}
+
+func (d *Denylist) Refresh(ctx context.Context) error {
+	var revoked []*RevokedToken
+
+	if d.pgDataSource != nil {
+		pgRevoked, err := d.pgDataSource.RevokedTokens(ctx)
+		if err != nil {
+			return err
+		}
+		revoked = append(revoked, pgRevoked...)
+	}
+
+	if d.file != "" {
+		fileRevoked, err := loadDenylistFile(d.file)
+		if err != nil {
+			return err
+		}
+		revoked = append(revoked, fileRevoked...)
+	}
+
+	entries := &denylistEntries{
+		jtis:     make(map[string]struct{}),
+		subjects: make(map[string]struct{}),
+	}
+	for _, v := range revoked {
+		if v.JTI != "" {
+			entries.jtis[v.JTI] = struct{}{}
+		}
+		if v.Subject != "" {
+			entries.subjects[v.Subject] = struct{}{}
+		}
+	}
+	d.entries.Store(entries)
+	d.logg.Debug("token denylist refreshed", "jtis", len(entries.jtis), "subjects", len(entries.subjects))
+
+	return nil
+}
+
+func (d *Denylist) IsRevoked(jti string, subject string) bool {
+	entries := d.entries.Load()
+
+	if _, ok := entries.jtis[jti]; ok && jti != "" {
+		return true
+	}
+	if _, ok := entries.subjects[subject]; ok && subject != "" {
+		return true
+	}
+
+	return false
+}
+
+// loadDenylistFile reads a JSON array of {"jti": "...", "subject": "..."} objects.
+func loadDenylistFile(path string) ([]*RevokedToken, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var revoked []*RevokedToken
+	if err := json.Unmarshal(b, &revoked); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
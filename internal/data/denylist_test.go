@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDenylist(t *testing.T, contents string) *Denylist {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "denylist.json")
+	if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewDenylist(DenylistOpts{
+		Logg: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		File: file,
+	})
+}
+
+func TestDenylistIsRevoked(t *testing.T) {
+	d := newTestDenylist(t, `[{"jti": "revoked-jti"}, {"subject": "revoked-subject"}, {"jti": "", "subject": ""}]`)
+	if d.IsRevoked("revoked-jti", "") {
+		t.Fatal("entries revoked before the first refresh")
+	}
+	if err := d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		jti     string
+		subject string
+		want    bool
+	}{
+		{name: "revoked jti", jti: "revoked-jti", subject: "alice", want: true},
+		{name: "revoked subject", jti: "other-jti", subject: "revoked-subject", want: true},
+		{name: "not revoked", jti: "other-jti", subject: "alice"},
+		{name: "empty claims never match empty entries"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.IsRevoked(tt.jti, tt.subject); got != tt.want {
+				t.Errorf("IsRevoked(%q, %q) = %v, want %v", tt.jti, tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDenylistRefresh(t *testing.T) {
+	d := newTestDenylist(t, `[{"jti": "first"}]`)
+	if err := d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A refresh replaces the entries, so removed entries are no longer revoked
+	if err := os.WriteFile(d.file, []byte(`[{"jti": "second"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d.IsRevoked("first", "") || !d.IsRevoked("second", "") {
+		t.Fatal("refresh did not replace the entries")
+	}
+
+	// A failed refresh keeps the previous entries
+	if err := os.WriteFile(d.file, []byte(`not json`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh() succeeded on an invalid file")
+	}
+	if !d.IsRevoked("second", "") {
+		t.Fatal("failed refresh dropped the previous entries")
+	}
+}
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Sample takes one indexer lag sample, for the tests in data_test.
+func (m *IndexerMonitor) Sample(ctx context.Context) error {
+	return m.sample(ctx)
+}
+
+// SetClock replaces the monitor's clock.
+func (m *IndexerMonitor) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+// ReplicaCooldowns returns until when each replica is skipped.
+func (pg *PgChainData) ReplicaCooldowns() []time.Time {
+	cooldowns := make([]time.Time, len(pg.replicas))
+	for i, replica := range pg.replicas {
+		cooldowns[i] = time.Unix(0, replica.downUntil.Load())
+	}
+	return cooldowns
+}
+
+// EndReplicaCooldowns makes every replica eligible again.
+func (pg *PgChainData) EndReplicaCooldowns() {
+	for _, replica := range pg.replicas {
+		replica.downUntil.Store(0)
+	}
+}
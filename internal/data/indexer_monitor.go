@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+type (
+	IndexerMonitorOpts struct {
+		Logg            *slog.Logger
+		PgDataSource    *PgChainData
+		ChainDataSource *Chain
+		Interval        time.Duration
+		// MaxLagBlocks marks results as stale once the indexer falls further behind
+		MaxLagBlocks uint64
+	}
+
+	// IndexerMonitor periodically compares the newest indexed block with the
+	// RPC head and exposes the lag to handlers and as metrics.
+	IndexerMonitor struct {
+		logg            *slog.Logger
+		pgDataSource    *PgChainData
+		chainDataSource *Chain
+		interval        time.Duration
+		maxLagBlocks    uint64
+		now             func() time.Time
+		freshness       atomic.Pointer[api.Freshness]
+
+		indexedBlock atomic.Uint64
+		headBlock    atomic.Uint64
+		lagBlocks    atomic.Uint64
+	}
+)
+
+const (
+	defaultIndexerMonitorInterval = 15 * time.Second
+	defaultIndexerMaxLagBlocks    = 60
+	// staleSampleIntervals is how many intervals a sample is served for
+	// before it is marked stale, i.e. sampling has been failing since.
+	staleSampleIntervals = 3
+)
+
+// NewIndexerMonitor returns a monitor and registers its lag gauges.
+func NewIndexerMonitor(o IndexerMonitorOpts) *IndexerMonitor {
+	if o.Interval <= 0 {
+		o.Interval = defaultIndexerMonitorInterval
+	}
+	if o.MaxLagBlocks == 0 {
+		o.MaxLagBlocks = defaultIndexerMaxLagBlocks
+	}
+
+	m := &IndexerMonitor{
+		logg:            o.Logg,
+		pgDataSource:    o.PgDataSource,
+		chainDataSource: o.ChainDataSource,
+		interval:        o.Interval,
+		maxLagBlocks:    o.MaxLagBlocks,
+		now:             time.Now,
+	}
+
+	set := metrics.NewSet()
+	set.NewGauge("indexer_head_block", func() float64 { return float64(m.indexedBlock.Load()) })
+	set.NewGauge("rpc_head_block", func() float64 { return float64(m.headBlock.Load()) })
+	set.NewGauge("indexer_lag_blocks", func() float64 { return float64(m.lagBlocks.Load()) })
+	metrics.RegisterSet(set)
+
+	return m
+}
+
+// Start samples the indexer lag until ctx is cancelled.
+func (m *IndexerMonitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.sample(ctx); err != nil {
+			m.logg.Error("failed to sample indexer lag", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Freshness returns the latest sample, nil until the first successful one.
+// Once sampling has failed for a few intervals the last sample is returned
+// as stale.
+func (m *IndexerMonitor) Freshness() *api.Freshness {
+	freshness := m.freshness.Load()
+	if freshness == nil || freshness.Stale {
+		return freshness
+	}
+
+	if m.now().Sub(freshness.CheckedAt) > staleSampleIntervals*m.interval {
+		stale := *freshness
+		stale.Stale = true
+		return &stale
+	}
+
+	return freshness
+}
+
+func (m *IndexerMonitor) sample(ctx context.Context) error {
+	indexedBlock, err := m.pgDataSource.IndexerHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	headBlock, _, err := m.chainDataSource.HeadBlock(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lag uint64
+	if headBlock > indexedBlock {
+		lag = headBlock - indexedBlock
+	}
+
+	m.indexedBlock.Store(indexedBlock)
+	m.headBlock.Store(headBlock)
+	m.lagBlocks.Store(lag)
+
+	m.freshness.Store(&api.Freshness{
+		IndexedBlock: indexedBlock,
+		HeadBlock:    headBlock,
+		LagBlocks:    lag,
+		Stale:        lag > m.maxLagBlocks,
+		CheckedAt:    m.now(),
+	})
+
+	if lag > m.maxLagBlocks {
+		m.logg.Warn("indexer is lagging", "indexed_block", indexedBlock, "head_block", headBlock, "lag", lag)
+	}
+
+	return nil
+}
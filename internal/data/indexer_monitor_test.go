@@ -0,0 +1,95 @@
+package data_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/internal/rpctest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+type indexerHead struct {
+	BlockNumber uint64 `db:"block_number"`
+}
+
+func TestIndexerMonitor(t *testing.T) {
+	logg := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	pgServer, pg := pgtest.NewServer(t, "../../queries.sql")
+	rpc := rpctest.NewServer(t)
+	setHead := func(block int64) {
+		rpc.SetResult("eth_getBlockByNumber", &types.Header{Number: big.NewInt(block), Difficulty: new(big.Int)})
+	}
+
+	monitor := data.NewIndexerMonitor(data.IndexerMonitorOpts{
+		Logg:            logg,
+		PgDataSource:    pg,
+		ChainDataSource: data.NewChainProvider(data.ChainOpts{ChainID: 1, RPCEndpoint: rpc.URL(), Logg: logg}),
+		Interval:        15 * time.Second,
+		MaxLagBlocks:    60,
+	})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	monitor.SetClock(func() time.Time { return now })
+
+	if freshness := monitor.Freshness(); freshness != nil {
+		t.Fatalf("freshness before the first sample = %+v, want nil", freshness)
+	}
+
+	pgServer.Set("indexer-head", indexerHead{}, indexerHead{BlockNumber: 1000})
+	setHead(1010)
+	if err := monitor.Sample(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := api.Freshness{IndexedBlock: 1000, HeadBlock: 1010, LagBlocks: 10, CheckedAt: now}
+	if got := *monitor.Freshness(); got != want {
+		t.Errorf("freshness = %+v, want %+v", got, want)
+	}
+
+	var exposed bytes.Buffer
+	metrics.WritePrometheus(&exposed, false)
+	for _, gauge := range []string{"indexer_head_block 1000", "rpc_head_block 1010", "indexer_lag_blocks 10"} {
+		if !strings.Contains(exposed.String(), gauge+"\n") {
+			t.Errorf("metrics don't contain %q", gauge)
+		}
+	}
+
+	setHead(1100)
+	if err := monitor.Sample(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if freshness := monitor.Freshness(); !freshness.Stale || freshness.LagBlocks != 100 {
+		t.Errorf("freshness of a lagging indexer = %+v, want stale with a lag of 100", freshness)
+	}
+
+	setHead(1010)
+	if err := monitor.Sample(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sampling fails from here on, the last sample goes stale after a few intervals.
+	pgServer.Close()
+	if err := monitor.Sample(ctx); err == nil {
+		t.Fatal("expected sampling to fail with the database down")
+	}
+
+	now = now.Add(45 * time.Second)
+	if freshness := monitor.Freshness(); freshness.Stale || freshness.LagBlocks != 10 {
+		t.Errorf("freshness after 3 intervals = %+v, want the last sample, fresh", freshness)
+	}
+
+	now = now.Add(time.Second)
+	if freshness := monitor.Freshness(); !freshness.Stale || freshness.LagBlocks != 10 {
+		t.Errorf("freshness after more than 3 intervals = %+v, want the last sample, stale", freshness)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/grassrootseconomics/ethutils"
@@ -17,37 +20,104 @@ type (
 		Logg    *slog.Logger
 		DSN     string
 		Queries *PgQueries
+		// SlowQueryThreshold logs queries (with their arguments) that take longer, 0 disables it
+		SlowQueryThreshold time.Duration
+		// ReplicaDSNs optionally serve read queries, the primary is used as fallback
+		ReplicaDSNs []string
+		Pool        PgPoolOpts
+		// QueryTimeouts bounds individual queries by their goyesql name
+		QueryTimeouts map[string]time.Duration
 	}
 
 	PgChainData struct {
-		logg    *slog.Logger
-		db      *pgxpool.Pool
-		queries *PgQueries
+		logg          *slog.Logger
+		db            *pgxpool.Pool
+		replicas      []*pgReplica
+		replicaCursor atomic.Uint64
+		tracer        *queryTracer
+		queries       atomic.Pointer[PgQueries]
+		queryTimeouts map[string]time.Duration
+		tokenSearch   *tokenSearchCache
 	}
 )
 
 func NewPgChainDataSource(o PgChainDataOpts) (*PgChainData, error) {
-	parsedConfig, err := pgxpool.ParseConfig(o.DSN)
+	tracer := newQueryTracer(o.Logg, o.SlowQueryThreshold, o.Queries)
+
+	dbPool, err := newPgPool(o.DSN, o.Pool, tracer)
 	if err != nil {
 		return nil, err
 	}
 
-	dbPool, err := pgxpool.NewWithConfig(context.Background(), parsedConfig)
+	pg := &PgChainData{
+		logg:          o.Logg,
+		db:            dbPool,
+		tracer:        tracer,
+		queryTimeouts: o.QueryTimeouts,
+		tokenSearch:   newTokenSearchCache(tokenSearchCacheTTL),
+	}
+	pg.queries.Store(o.Queries)
+
+	for _, dsn := range o.ReplicaDSNs {
+		replicaPool, err := newPgPool(dsn, o.Pool, tracer)
+		if err != nil {
+			pg.Close()
+			return nil, err
+		}
+		pg.replicas = append(pg.replicas, &pgReplica{db: replicaPool})
+	}
+
+	return pg, nil
+}
+
+// SetQueries atomically swaps the queries used by every subsequent call.
+func (pg *PgChainData) SetQueries(queries *PgQueries) {
+	pg.tracer.setQueries(queries)
+	pg.queries.Store(queries)
+}
+
+func (pg *PgChainData) Ping(ctx context.Context) error {
+	return pg.db.Ping(ctx)
+}
+
+func (pg *PgChainData) Close() {
+	pg.db.Close()
+	for _, replica := range pg.replicas {
+		replica.db.Close()
+	}
+}
+
+// PrepareQueries prepares every loaded query except the skipped ones against
+// the database without executing it and returns the failures keyed by query
+// name.
+func (pg *PgChainData) PrepareQueries(ctx context.Context, skip []string) (map[string]error, error) {
+	conn, err := pg.db.Acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Release()
+
+	failed := make(map[string]error)
+	for sql, name := range pg.queries.Load().names() {
+		if slices.Contains(skip, name) {
+			continue
+		}
+		if _, err := conn.Conn().Prepare(ctx, name, sql); err != nil {
+			failed[name] = err
+			continue
+		}
+		if err := conn.Conn().Deallocate(ctx, name); err != nil {
+			return nil, err
+		}
+	}
 
-	return &PgChainData{
-		logg:    o.Logg,
-		db:      dbPool,
-		queries: o.Queries,
-	}, nil
+	return failed, nil
 }
 
 func (pg *PgChainData) Last10Tx(ctx context.Context, publicAddress string) ([]*api.Last10TxResponse, error) {
 	var last10Tx []*api.Last10TxResponse
 
-	if err := pgxscan.Select(ctx, pg.db, &last10Tx, pg.queries.Last10Tx, publicAddress); err != nil {
+	if err := pgxscan.Select(ctx, pg.reader(), &last10Tx, pg.queries.Load().Last10Tx, publicAddress); err != nil {
 		return nil, err
 	}
 
@@ -57,7 +127,7 @@ func (pg *PgChainData) Last10Tx(ctx context.Context, publicAddress string) ([]*a
 func (pg *PgChainData) TokenHoldings(ctx context.Context, publicAddress string) ([]*api.TokenHoldings, error) {
 	var tokenHoldings []*api.TokenHoldings
 
-	if err := pgxscan.Select(ctx, pg.db, &tokenHoldings, pg.queries.TokenHoldings, publicAddress); err != nil {
+	if err := pgxscan.Select(ctx, pg.reader(), &tokenHoldings, pg.queries.Load().TokenHoldings, publicAddress); err != nil {
 		return nil, err
 	}
 
@@ -72,7 +142,7 @@ func (pg *PgChainData) ResolveAlias(ctx context.Context, alias string) (*api.Ali
 }
 
 func (pg *PgChainData) TokenDetails(ctx context.Context, tokenAddress string) (*api.TokenDetails, error) {
-	row, err := pg.db.Query(ctx, pg.queries.TokenDetails, tokenAddress)
+	row, err := pg.reader().Query(ctx, pg.queries.Load().TokenDetails, tokenAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +158,7 @@ func (pg *PgChainData) TokenDetails(ctx context.Context, tokenAddress string) (*
 }
 
 func (pg *PgChainData) PoolDetails(ctx context.Context, poolAddress string) (*api.PoolDetails, error) {
-	row, err := pg.db.Query(ctx, pg.queries.PoolDetails, poolAddress)
+	row, err := pg.reader().Query(ctx, pg.queries.Load().PoolDetails, poolAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +174,7 @@ func (pg *PgChainData) PoolDetails(ctx context.Context, poolAddress string) (*ap
 }
 
 func (pg *PgChainData) PoolReverseDetails(ctx context.Context, poolSymbol string) (*api.PoolDetails, error) {
-	row, err := pg.db.Query(ctx, pg.queries.PoolReverseDetails, poolSymbol)
+	row, err := pg.reader().Query(ctx, pg.queries.Load().PoolReverseDetails, poolSymbol)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +192,7 @@ func (pg *PgChainData) PoolReverseDetails(ctx context.Context, poolSymbol string
 func (pg *PgChainData) TopPools(ctx context.Context) ([]*api.PoolDetails, error) {
 	var topPools []*api.PoolDetails
 
-	if err := pgxscan.Select(ctx, pg.db, &topPools, pg.queries.TopPools); err != nil {
+	if err := pgxscan.Select(ctx, pg.reader(), &topPools, pg.queries.Load().TopPools); err != nil {
 		return nil, err
 	}
 
@@ -132,7 +202,7 @@ func (pg *PgChainData) TopPools(ctx context.Context) ([]*api.PoolDetails, error)
 func (pg *PgChainData) PoolAllowedTokensForUser(ctx context.Context, userAddress, poolAddress string) ([]*api.TokenHoldings, error) {
 	var tokenHoldings []*api.TokenHoldings
 
-	if err := pgxscan.Select(ctx, pg.db, &tokenHoldings, pg.queries.PoolAllowedTokensForUser, userAddress, poolAddress); err != nil {
+	if err := pgxscan.Select(ctx, pg.reader(), &tokenHoldings, pg.queries.Load().PoolAllowedTokensForUser, userAddress, poolAddress); err != nil {
 		return nil, err
 	}
 
@@ -144,7 +214,7 @@ func (pg *PgChainData) PoolTokenAllowed(ctx context.Context, poolAddress, tokenA
 		IsAllowed bool `db:"is_allowed"`
 	}
 
-	row, err := pg.db.Query(ctx, pg.queries.PoolTokenAllowed, poolAddress, tokenAddress)
+	row, err := pg.reader().Query(ctx, pg.queries.Load().PoolTokenAllowed, poolAddress, tokenAddress)
 	if err != nil {
 		return false, err
 	}
@@ -159,7 +229,7 @@ func (pg *PgChainData) PoolTokenAllowed(ctx context.Context, poolAddress, tokenA
 func (pg *PgChainData) PoolAllowedTokens(ctx context.Context, poolAddress string) ([]*api.TokenHoldings, error) {
 	var tokenHoldings []*api.TokenHoldings
 
-	if err := pgxscan.Select(ctx, pg.db, &tokenHoldings, pg.queries.PoolAllowedTokens, poolAddress); err != nil {
+	if err := pgxscan.Select(ctx, pg.reader(), &tokenHoldings, pg.queries.Load().PoolAllowedTokens, poolAddress); err != nil {
 		return nil, err
 	}
 
@@ -169,15 +239,63 @@ func (pg *PgChainData) PoolAllowedTokens(ctx context.Context, poolAddress string
 func (pg *PgChainData) PoolAllowedStables(ctx context.Context, poolAddress string) ([]*api.TokenHoldings, error) {
 	var tokenHoldings []*api.TokenHoldings
 
-	if err := pgxscan.Select(ctx, pg.db, &tokenHoldings, pg.queries.PoolAllowedStables, poolAddress); err != nil {
+	if err := pgxscan.Select(ctx, pg.reader(), &tokenHoldings, pg.queries.Load().PoolAllowedStables, poolAddress); err != nil {
 		return nil, err
 	}
 
 	return tokenHoldings, nil
 }
 
+// PoolTokenState returns the pool's allowed tokens with their exchange rate
+// and pool router limit. Balances and limiter limits are left to the caller.
+func (pg *PgChainData) PoolTokenState(ctx context.Context, poolAddress string) ([]*api.PoolTokenState, error) {
+	var tokens []*api.PoolTokenState
+
+	if err := pgxscan.Select(ctx, pg.reader(), &tokens, pg.queries.Load().PoolTokenState, poolAddress); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// PoolActivityDaily returns swap counts and input volumes per pool, UTC day
+// and input token since the given time, optionally for a single pool.
+func (pg *PgChainData) PoolActivityDaily(ctx context.Context, since time.Time, poolAddress string) ([]*api.PoolActivityRow, error) {
+	var rows []*api.PoolActivityRow
+
+	if err := pgxscan.Select(ctx, pg.reader(), &rows, pg.queries.Load().PoolActivityDaily, since, poolAddress); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// PoolActivityUsers returns unique swap initiators per pool and UTC day since
+// the given time. Rows with a nil Day count the whole window.
+func (pg *PgChainData) PoolActivityUsers(ctx context.Context, since time.Time, poolAddress string) ([]*api.PoolActivityUsers, error) {
+	var rows []*api.PoolActivityUsers
+
+	if err := pgxscan.Select(ctx, pg.reader(), &rows, pg.queries.Load().PoolActivityUsers, since, poolAddress); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// PoolSearchCandidates returns every pool a search can match with its
+// allowed vouchers.
+func (pg *PgChainData) PoolSearchCandidates(ctx context.Context) ([]*api.PoolMatch, error) {
+	var candidates []*api.PoolMatch
+
+	if err := pgxscan.Select(ctx, pg.reader(), &candidates, pg.queries.Load().PoolSearchCandidates); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
 func (pg *PgChainData) PoolTokenSwapRates(ctx context.Context, poolAddress, inTokenAddress, outTokenAddress string) (*api.TokenSwapRates, error) {
-	row, err := pg.db.Query(ctx, pg.queries.PoolTokenSwapRates, poolAddress, inTokenAddress, outTokenAddress)
+	row, err := pg.reader().Query(ctx, pg.queries.Load().PoolTokenSwapRates, poolAddress, inTokenAddress, outTokenAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -197,7 +315,7 @@ func (pg *PgChainData) PoolTokenLimit(ctx context.Context, poolAddress, tokenAdd
 		TokenLimit string `db:"token_limit"`
 	}
 
-	row, err := pg.db.Query(ctx, pg.queries.PoolTokenLimit, poolAddress, tokenAddress)
+	row, err := pg.reader().Query(ctx, pg.queries.Load().PoolTokenLimit, poolAddress, tokenAddress)
 	if err != nil {
 		return "", err
 	}
@@ -210,3 +328,114 @@ func (pg *PgChainData) PoolTokenLimit(ctx context.Context, poolAddress, tokenAdd
 
 	return result.TokenLimit, nil
 }
+
+// TokenValuationRates returns at most one rate per token, tokens without a
+// pool that also rates referenceToken are left out.
+func (pg *PgChainData) TokenValuationRates(ctx context.Context, tokenAddresses []string, referenceToken string) ([]*api.TokenValuationRate, error) {
+	var rates []*api.TokenValuationRate
+
+	if err := pgxscan.Select(ctx, pg.reader(), &rates, pg.queries.Load().TokenValuationRates, tokenAddresses, referenceToken); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// TokenSearchCandidates returns every token a search can match, optionally
+// only those allowed in poolAddress. Candidates are cached for a minute and
+// shared between callers, so they must not be modified.
+func (pg *PgChainData) TokenSearchCandidates(ctx context.Context, poolAddress string) ([]*api.TokenMatch, error) {
+	if candidates, ok := pg.tokenSearch.get(poolAddress, time.Now()); ok {
+		return candidates, nil
+	}
+
+	var candidates []*api.TokenMatch
+
+	if err := pgxscan.Select(ctx, pg.reader(), &candidates, pg.queries.Load().TokenSearchCandidates, poolAddress); err != nil {
+		return nil, err
+	}
+	pg.tokenSearch.set(poolAddress, candidates, time.Now())
+
+	return candidates, nil
+}
+
+// TokenRegistries returns the token registry of every swap pool.
+func (pg *PgChainData) TokenRegistries(ctx context.Context) ([]string, error) {
+	var registries []string
+
+	if err := pgxscan.Select(ctx, pg.reader(), &registries, pg.queries.Load().TokenRegistries); err != nil {
+		return nil, err
+	}
+
+	return registries, nil
+}
+
+// StatementOpeningBalances returns the account's non zero balances per token
+// from its ledger before the given time.
+func (pg *PgChainData) StatementOpeningBalances(ctx context.Context, publicAddress string, before time.Time) ([]*api.StatementEntry, error) {
+	var balances []*api.StatementEntry
+
+	if err := pgxscan.Select(ctx, pg.reader(), &balances, pg.queries.Load().StatementOpeningBalances, publicAddress, before); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// StatementEntries streams the account's ledger entries in [from, to) to fn
+// one row at a time, so large ranges are never held in memory. Returning an
+// error from fn stops the iteration. The cursor is open for the whole export,
+// so it runs with a statement_timeout taken from the ctx deadline.
+func (pg *PgChainData) StatementEntries(ctx context.Context, publicAddress string, from, to time.Time, fn func(*api.StatementEntry) error) error {
+	sql := pg.queries.Load().StatementEntries
+	ctx, cancel := pg.queryContext(ctx, sql)
+	defer cancel()
+
+	return pg.readTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, sql, publicAddress, from, to)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		scanner := pgxscan.NewRowScanner(rows)
+		for rows.Next() {
+			var entry api.StatementEntry
+			if err := scanner.Scan(&entry); err != nil {
+				return err
+			}
+			if err := fn(&entry); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+func (pg *PgChainData) RevokedTokens(ctx context.Context) ([]*RevokedToken, error) {
+	var revokedTokens []*RevokedToken
+
+	if err := pgxscan.Select(ctx, pg.reader(), &revokedTokens, pg.queries.Load().RevokedTokens); err != nil {
+		return nil, err
+	}
+
+	return revokedTokens, nil
+}
+
+func (pg *PgChainData) IndexerHead(ctx context.Context) (uint64, error) {
+	var result struct {
+		BlockNumber uint64 `db:"block_number"`
+	}
+
+	row, err := pg.reader().Query(ctx, pg.queries.Load().IndexerHead)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := pgxscan.ScanOne(&result, row); err != nil {
+		return 0, err
+	}
+
+	return result.BlockNumber, nil
+}
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type (
+	// PgPoolOpts tunes every pool (primary and replicas). Zero values keep
+	// the pgxpool defaults.
+	PgPoolOpts struct {
+		MaxConns          int32
+		MinConns          int32
+		MaxConnLifetime   time.Duration
+		MaxConnIdleTime   time.Duration
+		HealthCheckPeriod time.Duration
+		// StatementTimeout is set as the server side statement_timeout on every connection
+		StatementTimeout time.Duration
+	}
+
+	pgReplica struct {
+		db        *pgxpool.Pool
+		downUntil atomic.Int64
+	}
+
+	// routedQuerier sends reads to a healthy replica, falling back to the
+	// primary, and applies per query timeouts.
+	routedQuerier struct {
+		pg *PgChainData
+	}
+
+	// cancelRows releases the per query timeout once the rows are consumed.
+	cancelRows struct {
+		pgx.Rows
+		cancel context.CancelFunc
+	}
+)
+
+// replicaCooldown is how long a replica that failed to serve a query is
+// skipped before it is tried again.
+const replicaCooldown = 30 * time.Second
+
+var replicaFallbacksCounter = metrics.NewCounter("pg_replica_fallbacks_total")
+
+func newPgPool(dsn string, o PgPoolOpts, tracer *queryTracer) (*pgxpool.Pool, error) {
+	parsedConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	parsedConfig.ConnConfig.Tracer = tracer
+
+	if o.MaxConns > 0 {
+		parsedConfig.MaxConns = o.MaxConns
+	}
+	if o.MinConns > 0 {
+		parsedConfig.MinConns = o.MinConns
+	}
+	if o.MaxConnLifetime > 0 {
+		parsedConfig.MaxConnLifetime = o.MaxConnLifetime
+	}
+	if o.MaxConnIdleTime > 0 {
+		parsedConfig.MaxConnIdleTime = o.MaxConnIdleTime
+	}
+	if o.HealthCheckPeriod > 0 {
+		parsedConfig.HealthCheckPeriod = o.HealthCheckPeriod
+	}
+	if o.StatementTimeout > 0 {
+		parsedConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(o.StatementTimeout.Milliseconds(), 10)
+	}
+
+	return pgxpool.NewWithConfig(context.Background(), parsedConfig)
+}
+
+// reader returns the querier used for all read queries.
+func (pg *PgChainData) reader() pgxscan.Querier {
+	return routedQuerier{pg: pg}
+}
+
+// readTx runs fn in a read only transaction on a healthy replica or the
+// primary. When ctx has a deadline the transaction's statement_timeout is
+// raised to it, so a long read such as a statement export is bounded by its
+// caller instead of the connection wide statement_timeout.
+func (pg *PgChainData) readTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := pg.beginRead(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := max(time.Until(deadline).Milliseconds(), 1)
+		if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = "+strconv.FormatInt(timeout, 10)); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (pg *PgChainData) beginRead(ctx context.Context) (pgx.Tx, error) {
+	opts := pgx.TxOptions{AccessMode: pgx.ReadOnly}
+
+	replica := pg.nextReplica()
+	if replica == nil {
+		return pg.db.BeginTx(ctx, opts)
+	}
+
+	tx, err := replica.db.BeginTx(ctx, opts)
+	if err == nil || !IsPgConnError(err) || ctx.Err() != nil {
+		return tx, err
+	}
+
+	replica.downUntil.Store(time.Now().Add(replicaCooldown).UnixNano())
+	replicaFallbacksCounter.Inc()
+	util.LoggerFromContext(ctx, pg.logg).Warn("read replica unavailable, falling back to primary", "error", err)
+
+	return pg.db.BeginTx(ctx, opts)
+}
+
+// queryContext applies the query's configured timeout, if any, to ctx.
+func (pg *PgChainData) queryContext(ctx context.Context, sql string) (context.Context, context.CancelFunc) {
+	if timeout, ok := pg.queryTimeouts[pg.tracer.name(sql)]; ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (q routedQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := q.pg.queryContext(ctx, sql)
+
+	rows, err := q.query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &cancelRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (q routedQuerier) query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	replica := q.pg.nextReplica()
+	if replica == nil {
+		return q.pg.db.Query(ctx, sql, args...)
+	}
+
+	rows, err := replica.db.Query(ctx, sql, args...)
+	if err == nil || !IsPgConnError(err) || ctx.Err() != nil {
+		return rows, err
+	}
+
+	replica.downUntil.Store(time.Now().Add(replicaCooldown).UnixNano())
+	replicaFallbacksCounter.Inc()
+	util.LoggerFromContext(ctx, q.pg.logg).Warn("read replica unavailable, falling back to primary", "error", err)
+
+	return q.pg.db.Query(ctx, sql, args...)
+}
+
+func (r *cancelRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+// nextReplica round robins over replicas that are not cooling down, nil
+// means the primary should be used.
+func (pg *PgChainData) nextReplica() *pgReplica {
+	n := len(pg.replicas)
+	if n == 0 {
+		return nil
+	}
+
+	now := time.Now().UnixNano()
+	start := pg.replicaCursor.Add(1)
+	for i := range uint64(n) {
+		replica := pg.replicas[(start+i)%uint64(n)]
+		if replica.downUntil.Load() <= now {
+			return replica
+		}
+	}
+
+	return nil
+}
+
+// IsPgConnError reports errors where the query never reached a usable
+// connection, as opposed to errors returned by the server.
+func IsPgConnError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr) || pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,171 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+// newRoutedPg starts a primary and n replicas answering indexer-head and
+// returns a data source reading from them.
+func newRoutedPg(t *testing.T, replicas int, timeouts map[string]time.Duration) (*pgtest.Server, []*pgtest.Server, *data.PgChainData) {
+	t.Helper()
+
+	start := func() *pgtest.Server {
+		server := pgtest.Start(t, "../../queries.sql")
+		server.Set("indexer-head", indexerHead{}, indexerHead{BlockNumber: 1})
+		return server
+	}
+
+	primary := start()
+	var replicaServers []*pgtest.Server
+	var replicaDSNs []string
+	for range replicas {
+		replica := start()
+		replicaServers = append(replicaServers, replica)
+		replicaDSNs = append(replicaDSNs, replica.DSN())
+	}
+
+	pg, err := data.NewPgChainDataSource(data.PgChainDataOpts{
+		Logg:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DSN:           primary.DSN(),
+		Queries:       primary.Queries(),
+		ReplicaDSNs:   replicaDSNs,
+		QueryTimeouts: timeouts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pg.Close)
+
+	return primary, replicaServers, pg
+}
+
+// served counts the indexer-head queries each server answered.
+func served(servers ...*pgtest.Server) []int {
+	counts := make([]int, len(servers))
+	for i, server := range servers {
+		for _, statement := range server.Statements() {
+			if statement == "indexer-head" {
+				counts[i]++
+			}
+		}
+	}
+	return counts
+}
+
+func TestReplicaRoundRobin(t *testing.T) {
+	primary, replicas, pg := newRoutedPg(t, 2, nil)
+
+	for range 4 {
+		if _, err := pg.IndexerHead(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := served(primary, replicas[0], replicas[1]); !slices.Equal(got, []int{0, 2, 2}) {
+		t.Errorf("queries served by primary, replicas = %v, want [0 2 2]", got)
+	}
+}
+
+func TestReplicaFallbackAndCooldown(t *testing.T) {
+	primary, replicas, pg := newRoutedPg(t, 2, nil)
+	// Down before the pool ever connected, so queries fail to connect.
+	replicas[0].Close()
+
+	query := func() {
+		t.Helper()
+		if _, err := pg.IndexerHead(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first pick is the second replica, then the one that is down.
+	query()
+	query()
+	if got := served(primary, replicas[1]); !slices.Equal(got, []int{1, 1}) {
+		t.Fatalf("queries served by primary, replica = %v, want [1 1]", got)
+	}
+
+	cooldown := time.Until(pg.ReplicaCooldowns()[0])
+	if cooldown < 29*time.Second || cooldown > 30*time.Second {
+		t.Errorf("replica cooldown = %s, want 30s", cooldown)
+	}
+
+	// Cooling down, the down replica's turns go to the other one.
+	query()
+	query()
+	if got := served(primary, replicas[1]); !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("queries served by primary, replica during cooldown = %v, want [1 3]", got)
+	}
+
+	// After the cooldown the replica is tried again and falls back again.
+	pg.EndReplicaCooldowns()
+	query()
+	query()
+	if got := served(primary, replicas[1]); !slices.Equal(got, []int{2, 4}) {
+		t.Errorf("queries served by primary, replica after cooldown = %v, want [2 4]", got)
+	}
+}
+
+func TestQueryTimeouts(t *testing.T) {
+	primary, _, pg := newRoutedPg(t, 0, map[string]time.Duration{"indexer-head": 50 * time.Millisecond})
+	primary.SetDelay("indexer-head", time.Second)
+
+	startedAt := time.Now()
+	_, err := pg.IndexerHead(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want a deadline exceeded error", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 500*time.Millisecond {
+		t.Errorf("query returned after %s, want it cut off after 50ms", elapsed)
+	}
+}
+
+func TestStatementEntriesTimeout(t *testing.T) {
+	primary, replicas, pg := newRoutedPg(t, 1, nil)
+	replicas[0].Set("statement-entries", api.StatementEntry{}, &api.StatementEntry{TxHash: "0x01"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var entries int
+	err := pg.StatementEntries(ctx, "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439", time.Now().Add(-time.Hour), time.Now(), func(*api.StatementEntry) error {
+		entries++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != 1 {
+		t.Errorf("entries = %d, want 1", entries)
+	}
+
+	if statements := primary.Statements(); len(statements) != 0 {
+		t.Errorf("primary statements = %q, want the export on the replica", statements)
+	}
+
+	statements := replicas[0].Statements()
+	if len(statements) != 4 || !strings.HasPrefix(statements[0], "begin") || statements[2] != "statement-entries" || statements[3] != "commit" {
+		t.Fatalf("replica statements = %q, want a read only transaction", statements)
+	}
+
+	// The transaction's statement_timeout is the time left until the deadline.
+	var timeoutMs int64
+	if _, err := fmt.Sscanf(statements[1], "SET LOCAL statement_timeout = %d", &timeoutMs); err != nil {
+		t.Fatalf("statement %q: %v", statements[1], err)
+	}
+	if timeoutMs < 9000 || timeoutMs > 10000 {
+		t.Errorf("statement_timeout = %dms, want about 10s", timeoutMs)
+	}
+}
@@ -13,4 +13,15 @@ type PgQueries struct {
 	PoolAllowedStables       string `query:"pool-allowed-stables"`
 	PoolTokenSwapRates       string `query:"pool-token-swap-rates"`
 	PoolTokenLimit           string `query:"pool-token-limit"`
+	RevokedTokens            string `query:"revoked-tokens"`
+	IndexerHead              string `query:"indexer-head"`
+	TokenValuationRates      string `query:"token-valuation-rates"`
+	StatementOpeningBalances string `query:"statement-opening-balances"`
+	StatementEntries         string `query:"statement-entries"`
+	TokenSearchCandidates    string `query:"token-search-candidates"`
+	TokenRegistries          string `query:"token-registries"`
+	PoolTokenState           string `query:"pool-token-state"`
+	PoolActivityDaily        string `query:"pool-activity-daily"`
+	PoolActivityUsers        string `query:"pool-activity-users"`
+	PoolSearchCandidates     string `query:"pool-search-candidates"`
 }
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/ussd-data-service/internal/util"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	// queryTracer records latency and errors for every query, labelled by the
+	// goyesql query name it was loaded from.
+	queryTracer struct {
+		logg          *slog.Logger
+		slowThreshold time.Duration
+		queries       atomic.Pointer[PgQueries]
+		names         atomic.Pointer[map[string]string]
+	}
+
+	queryTraceKey struct{}
+
+	queryTrace struct {
+		name      string
+		args      []any
+		startedAt time.Time
+		span      trace.Span
+	}
+)
+
+const unknownQueryName = "unknown"
+
+var tracer = otel.Tracer("github.com/grassrootseconomics/ussd-data-service/internal/data")
+
+func newQueryTracer(logg *slog.Logger, slowThreshold time.Duration, queries *PgQueries) *queryTracer {
+	t := &queryTracer{
+		logg:          logg,
+		slowThreshold: slowThreshold,
+	}
+	t.setQueries(queries)
+
+	return t
+}
+
+// setQueries replaces the query names with those of queries. The names of
+// the previously loaded queries are kept until the next reload, so statements
+// read just before a reload stay labelled, without the map growing on every
+// reload.
+func (t *queryTracer) setQueries(queries *PgQueries) {
+	names := queries.names()
+	if previous := t.queries.Swap(queries); previous != nil {
+		for sql, name := range previous.names() {
+			if _, ok := names[sql]; !ok {
+				names[sql] = name
+			}
+		}
+	}
+	t.names.Store(&names)
+}
+
+// name returns the goyesql query name of a loaded SQL statement.
+func (t *queryTracer) name(sql string) string {
+	if name, ok := (*t.names.Load())[sql]; ok {
+		return name
+	}
+	return unknownQueryName
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := t.name(data.SQL)
+
+	ctx, span := tracer.Start(ctx, "pg "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(data.SQL),
+		),
+	)
+
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{
+		name:      name,
+		args:      data.Args,
+		startedAt: time.Now(),
+		span:      span,
+	})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	qt, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(qt.startedAt)
+
+	metrics.GetOrCreateHistogram(fmt.Sprintf(`pg_query_duration_seconds{query=%q}`, qt.name)).Update(elapsed.Seconds())
+	if data.Err != nil {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`pg_query_errors_total{query=%q}`, qt.name)).Inc()
+		qt.span.RecordError(data.Err)
+		qt.span.SetStatus(codes.Error, data.Err.Error())
+	}
+	qt.span.End()
+
+	if t.slowThreshold > 0 && elapsed > t.slowThreshold {
+		util.LoggerFromContext(ctx, t.logg).Warn("slow query", "query", qt.name, "elapsed", elapsed, "args", qt.args, "error", data.Err)
+	}
+}
+
+// names maps each loaded SQL statement to its goyesql query name.
+func (q *PgQueries) names() map[string]string {
+	names := make(map[string]string)
+
+	v := reflect.ValueOf(q).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("query"); name != "" {
+			names[v.Field(i).String()] = name
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,37 @@
+package data
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestQueryTracerSetQueries(t *testing.T) {
+	generation := func(sql string) *PgQueries {
+		return &PgQueries{IndexerHead: sql}
+	}
+
+	tr := newQueryTracer(slog.New(slog.NewTextHandler(io.Discard, nil)), 0, generation("SELECT 1"))
+	tr.setQueries(generation("SELECT 2"))
+	tr.setQueries(generation("SELECT 3"))
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{sql: "SELECT 3", want: "indexer-head"},
+		{sql: "SELECT 2", want: "indexer-head"},
+		{sql: "SELECT 1", want: unknownQueryName},
+	}
+
+	for _, tt := range tests {
+		if got := tr.name(tt.sql); got != tt.want {
+			t.Errorf("name(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	// Every other field is empty and shares one key, plus the two generations
+	if got := len(*tr.names.Load()); got != 3 {
+		t.Errorf("len(names) = %d, want 3", got)
+	}
+}
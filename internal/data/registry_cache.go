@@ -0,0 +1,345 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ethutils"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+type (
+	RegistryCacheOpts struct {
+		Logg            *slog.Logger
+		PgDataSource    *PgChainData
+		ChainDataSource *Chain
+		// Registries are always cached, in addition to the registries of
+		// swap pools when DiscoverRegistries is set
+		Registries         []string
+		DiscoverRegistries bool
+		// File persists the cache across restarts, empty disables persistence
+		File            string
+		RefreshInterval time.Duration
+		// BatchSize is the number of entries or tokens per RPC batch when
+		// reading entries and fetching details
+		BatchSize int
+	}
+
+	// RegistryCache holds the token details of every token in a set of token
+	// registries. Walking a registry takes a round trip per entry batch, so it
+	// is done in the background and a refresh only reads the entries added
+	// since the last one and fetches details of tokens not already cached.
+	RegistryCache struct {
+		logg               *slog.Logger
+		pgDataSource       *PgChainData
+		chainDataSource    *Chain
+		registries         []string
+		discoverRegistries bool
+		file               string
+		refreshInterval    time.Duration
+		batchSize          int
+		entries            atomic.Pointer[map[string]*RegistryTokens]
+	}
+
+	// RegistryTokens is a registry's tokens in registry order.
+	RegistryTokens struct {
+		Registry string              `json:"registry"`
+		Tokens   []*api.TokenDetails `json:"tokens"`
+		// Entries is every entry read from the registry, in registry order and
+		// including tokens without details, so a refresh continues after them
+		Entries   []string  `json:"entries"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		// WalkedAt is when the registry was last read from its first entry
+		WalkedAt time.Time `json:"walkedAt"`
+	}
+)
+
+const (
+	defaultRegistryCacheRefreshInterval = 10 * time.Minute
+	// registryWalkInterval is how often a registry is read from its first
+	// entry. Registries only shrink when a token is removed, and a removal
+	// followed by an addition keeps the count, so it is only seen then.
+	registryWalkInterval = 24 * time.Hour
+)
+
+func NewRegistryCache(o RegistryCacheOpts) *RegistryCache {
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = defaultRegistryCacheRefreshInterval
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultTokenDetailsBatchSize
+	}
+
+	registries := make([]string, 0, len(o.Registries))
+	for _, registry := range o.Registries {
+		registries = append(registries, common.HexToAddress(registry).Hex())
+	}
+
+	c := &RegistryCache{
+		logg:               o.Logg,
+		pgDataSource:       o.PgDataSource,
+		chainDataSource:    o.ChainDataSource,
+		registries:         registries,
+		discoverRegistries: o.DiscoverRegistries,
+		file:               o.File,
+		refreshInterval:    o.RefreshInterval,
+		batchSize:          o.BatchSize,
+	}
+	c.entries.Store(&map[string]*RegistryTokens{})
+
+	return c
+}
+
+// Load reads the persisted cache so registries are served before the first
+// refresh completes. A missing file is not an error.
+func (c *RegistryCache) Load() error {
+	if c.file == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(c.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var persisted []*RegistryTokens
+	if err := json.Unmarshal(b, &persisted); err != nil {
+		return err
+	}
+
+	entries := make(map[string]*RegistryTokens, len(persisted))
+	for _, v := range persisted {
+		entries[v.Registry] = v
+	}
+	c.entries.Store(&entries)
+	c.logg.Debug("registry cache loaded", "registries", len(entries))
+
+	return nil
+}
+
+// Start refreshes the cache immediately and then periodically until ctx is
+// cancelled.
+func (c *RegistryCache) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Refresh(ctx); err != nil {
+			c.logg.Error("failed to refresh registry cache", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh reads the entries added to every registry since the last refresh
+// and fetches details for tokens not already cached. A registry that fails
+// to refresh keeps its previous tokens. The cache is only persisted when a
+// registry changed.
+func (c *RegistryCache) Refresh(ctx context.Context) error {
+	registries, err := c.registryAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		now      = time.Now().UTC()
+		previous = *c.entries.Load()
+		entries  = make(map[string]*RegistryTokens, len(registries))
+		changed  bool
+	)
+	for _, registry := range registries {
+		refreshed, registryChanged, err := c.refreshRegistry(ctx, registry, previous[registry], now)
+		if err != nil {
+			c.logg.Error("failed to refresh registry", "registry", registry, "error", err)
+			if prev, ok := previous[registry]; ok {
+				entries[registry] = prev
+			}
+			continue
+		}
+		entries[registry] = refreshed
+		changed = changed || registryChanged
+	}
+	c.entries.Store(&entries)
+
+	if !changed && len(entries) == len(previous) {
+		return nil
+	}
+	return c.persist(entries)
+}
+
+// Tokens returns the cached tokens of a registry.
+func (c *RegistryCache) Tokens(registry string) (*RegistryTokens, bool) {
+	entry, ok := (*c.entries.Load())[registry]
+	return entry, ok
+}
+
+func (c *RegistryCache) registryAddresses(ctx context.Context) ([]string, error) {
+	registries := append([]string{}, c.registries...)
+	if !c.discoverRegistries {
+		return registries, nil
+	}
+
+	discovered, err := c.pgDataSource.TokenRegistries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(registries))
+	for _, registry := range registries {
+		seen[registry] = struct{}{}
+	}
+	for _, registry := range discovered {
+		if _, ok := seen[registry]; !ok {
+			seen[registry] = struct{}{}
+			registries = append(registries, registry)
+		}
+	}
+
+	return registries, nil
+}
+
+// refreshRegistry reads the registry's entries past the previously read ones,
+// or all of them when the registry shrank or is due a walk, and reports
+// whether anything changed. Tokens whose details failed before are retried.
+func (c *RegistryCache) refreshRegistry(ctx context.Context, registry string, previous *RegistryTokens, now time.Time) (*RegistryTokens, bool, error) {
+	count, err := c.chainDataSource.TokenIndexEntryCount(ctx, registry)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var (
+		read     []string
+		cached   []*api.TokenDetails
+		walkedAt = now
+	)
+	if previous != nil {
+		cached = previous.Tokens
+		if count >= uint64(len(previous.Entries)) && now.Sub(previous.WalkedAt) < registryWalkInterval {
+			read, walkedAt = previous.Entries, previous.WalkedAt
+		}
+	}
+
+	added, err := c.chainDataSource.TokenIndexEntries(ctx, registry, uint64(len(read)), count, c.batchSize)
+	if err != nil {
+		return nil, false, err
+	}
+	entries := append(slices.Clip(read), added...)
+
+	addresses := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if common.HexToAddress(entry) != ethutils.ZeroAddress {
+			addresses = append(addresses, entry)
+		}
+	}
+
+	missing := missingTokens(addresses, cached)
+	fetched, err := c.chainDataSource.TokensDetails(ctx, missing, c.batchSize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tokens := mergeRegistryTokens(addresses, cached, fetched)
+	c.logg.Debug("registry refreshed", "registry", registry, "tokens", len(tokens), "read", len(added), "fetched", len(fetched))
+
+	changed := previous == nil || len(fetched) > 0 || !walkedAt.Equal(previous.WalkedAt) || !slices.Equal(entries, previous.Entries)
+
+	return &RegistryTokens{
+		Registry:  registry,
+		Tokens:    tokens,
+		Entries:   entries,
+		UpdatedAt: now,
+		WalkedAt:  walkedAt,
+	}, changed, nil
+}
+
+// missingTokens returns the addresses without cached details.
+func missingTokens(addresses []string, cached []*api.TokenDetails) []string {
+	known := make(map[string]struct{}, len(cached))
+	for _, t := range cached {
+		known[t.TokenAddress] = struct{}{}
+	}
+
+	var missing []string
+	for _, address := range addresses {
+		if _, ok := known[address]; !ok {
+			missing = append(missing, address)
+		}
+	}
+	return missing
+}
+
+// mergeRegistryTokens returns the details of addresses in registry order from
+// the cached and freshly fetched details. Tokens removed from the registry
+// are dropped and tokens whose details could not be fetched are left out
+// until a later refresh succeeds.
+func mergeRegistryTokens(addresses []string, cached, fetched []*api.TokenDetails) []*api.TokenDetails {
+	details := make(map[string]*api.TokenDetails, len(cached)+len(fetched))
+	for _, t := range cached {
+		details[t.TokenAddress] = t
+	}
+	for _, t := range fetched {
+		details[t.TokenAddress] = t
+	}
+
+	tokens := make([]*api.TokenDetails, 0, len(addresses))
+	for _, address := range addresses {
+		if t, ok := details[address]; ok {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// persist writes the cache through a temporary file so a crash never leaves
+// a truncated file behind.
+func (c *RegistryCache) persist(entries map[string]*RegistryTokens) error {
+	if c.file == "" {
+		return nil
+	}
+
+	persisted := make([]*RegistryTokens, 0, len(entries))
+	for _, v := range entries {
+		persisted = append(persisted, v)
+	}
+	slices.SortFunc(persisted, func(a, b *RegistryTokens) int {
+		return strings.Compare(a.Registry, b.Registry)
+	})
+
+	b, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.file), filepath.Base(c.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.file)
+}
@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestMissingTokens(t *testing.T) {
+	cached := []*api.TokenDetails{{TokenAddress: "0xA"}, {TokenAddress: "0xC"}, {TokenAddress: "0xD"}}
+
+	tests := []struct {
+		name      string
+		addresses []string
+		want      []string
+	}{
+		{name: "all cached", addresses: []string{"0xA", "0xC"}},
+		{name: "new entries in registry order", addresses: []string{"0xE", "0xA", "0xB", "0xC"}, want: []string{"0xE", "0xB"}},
+		{name: "empty registry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingTokens(tt.addresses, cached); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingTokens() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := missingTokens([]string{"0xA", "0xB"}, nil); !reflect.DeepEqual(got, []string{"0xA", "0xB"}) {
+		t.Errorf("missingTokens() without a cache = %v, want every address", got)
+	}
+}
+
+func TestMergeRegistryTokens(t *testing.T) {
+	var (
+		a       = &api.TokenDetails{TokenAddress: "0xA", TokenSymbol: "A"}
+		b       = &api.TokenDetails{TokenAddress: "0xB", TokenSymbol: "B"}
+		c       = &api.TokenDetails{TokenAddress: "0xC", TokenSymbol: "C"}
+		removed = &api.TokenDetails{TokenAddress: "0xR", TokenSymbol: "R"}
+	)
+
+	tests := []struct {
+		name      string
+		addresses []string
+		cached    []*api.TokenDetails
+		fetched   []*api.TokenDetails
+		want      []*api.TokenDetails
+	}{
+		{
+			name:      "registry order across cached and fetched",
+			addresses: []string{"0xC", "0xA", "0xB"},
+			cached:    []*api.TokenDetails{a, b},
+			fetched:   []*api.TokenDetails{c},
+			want:      []*api.TokenDetails{c, a, b},
+		},
+		{
+			name:      "tokens removed from the registry are dropped",
+			addresses: []string{"0xA", "0xB"},
+			cached:    []*api.TokenDetails{a, removed, b},
+			want:      []*api.TokenDetails{a, b},
+		},
+		{
+			name:      "tokens without details are left out",
+			addresses: []string{"0xA", "0xB", "0xC"},
+			cached:    []*api.TokenDetails{a},
+			fetched:   []*api.TokenDetails{c},
+			want:      []*api.TokenDetails{a, c},
+		},
+		{
+			name:      "fetched details replace cached ones",
+			addresses: []string{"0xA"},
+			cached:    []*api.TokenDetails{a},
+			fetched:   []*api.TokenDetails{{TokenAddress: "0xA", TokenSymbol: "A2"}},
+			want:      []*api.TokenDetails{{TokenAddress: "0xA", TokenSymbol: "A2"}},
+		},
+		{
+			name:   "empty registry",
+			cached: []*api.TokenDetails{a},
+			want:   []*api.TokenDetails{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeRegistryTokens(tt.addresses, tt.cached, tt.fetched); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeRegistryTokens() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryCachePersistLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registries.json")
+	opts := RegistryCacheOpts{
+		Logg: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		File: file,
+	}
+
+	empty := NewRegistryCache(opts)
+	if err := empty.Load(); err != nil {
+		t.Fatalf("Load() without a persisted file = %v, want nil", err)
+	}
+
+	updatedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := map[string]*RegistryTokens{
+		"0xB": {Registry: "0xB", Tokens: []*api.TokenDetails{}, UpdatedAt: updatedAt},
+		"0xA": {
+			Registry: "0xA",
+			Tokens: []*api.TokenDetails{
+				{TokenAddress: "0x2", TokenSymbol: "TWO", TokenDecimals: 6, SinkAddress: "0x9", TokenName: "Two"},
+				{TokenAddress: "0x1", TokenSymbol: "ONE", TokenDecimals: 18, SinkAddress: "0x9", TokenName: "One"},
+			},
+			UpdatedAt: updatedAt,
+		},
+	}
+	if err := empty.persist(entries); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(file + ".*"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	loaded := NewRegistryCache(opts)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for registry, want := range entries {
+		got, ok := loaded.Tokens(registry)
+		if !ok {
+			t.Errorf("registry %s not loaded", registry)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokens(%s) = %+v, want %+v", registry, got, want)
+		}
+	}
+	if _, ok := loaded.Tokens("0xC"); ok {
+		t.Error("unknown registry loaded")
+	}
+}
+
+func TestRegistryCacheRefresh(t *testing.T) {
+	rpc, chain := newFakeRPC(t)
+	file := filepath.Join(t.TempDir(), "registries.json")
+
+	var (
+		registry   = common.HexToAddress("0x1111111111111111111111111111111111111111").Hex()
+		tokenA     = common.HexToAddress("0x000000000000000000000000000000000000000A").Hex()
+		tokenB     = common.HexToAddress("0x000000000000000000000000000000000000000B").Hex()
+		tokenC     = common.HexToAddress("0x000000000000000000000000000000000000000C").Hex()
+		setEntries = func(entries ...string) {
+			rpc.Set(registry, entryCountGetter, nil, big.NewInt(int64(len(entries))))
+			for i, entry := range entries {
+				rpc.Set(registry, entryGetter, []any{big.NewInt(int64(i))}, common.HexToAddress(entry))
+			}
+		}
+		setDetails = func(address, symbol string) {
+			rpc.Set(address, nameGetter, nil, "Token "+symbol)
+			rpc.Set(address, symbolGetter, nil, symbol)
+			rpc.Set(address, decimalsGetter, nil, uint8(6))
+			rpc.Set(address, sinkAddressGetter, nil, common.Address{})
+		}
+	)
+
+	c := NewRegistryCache(RegistryCacheOpts{
+		Logg:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ChainDataSource: chain,
+		Registries:      []string{registry},
+		File:            file,
+		BatchSize:       2,
+	})
+
+	tests := []struct {
+		name        string
+		setup       func()
+		wantSymbols []string
+		wantBatches []int
+		wantPersist bool
+	}{
+		{
+			// B's details are not readable yet
+			name:        "first walk",
+			setup:       func() { setEntries(tokenA, tokenB); setDetails(tokenA, "A") },
+			wantSymbols: []string{"A"},
+			wantBatches: []int{1, 2, 8},
+			wantPersist: true,
+		},
+		{
+			name:        "only added entries are read and failed details retried",
+			setup:       func() { setEntries(tokenA, tokenB, tokenC); setDetails(tokenB, "B"); setDetails(tokenC, "C") },
+			wantSymbols: []string{"A", "B", "C"},
+			wantBatches: []int{1, 1, 8},
+			wantPersist: true,
+		},
+		{
+			name:        "unchanged",
+			setup:       func() {},
+			wantSymbols: []string{"A", "B", "C"},
+			wantBatches: []int{1},
+		},
+		{
+			// Removing an entry moves the last one into its place
+			name:        "removed entry",
+			setup:       func() { setEntries(tokenA, tokenC) },
+			wantSymbols: []string{"A", "C"},
+			wantBatches: []int{1, 2},
+			wantPersist: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Fatal(err)
+			}
+			before := len(rpc.CallBatches())
+
+			if err := c.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			cached, ok := c.Tokens(registry)
+			if !ok {
+				t.Fatal("registry not cached")
+			}
+			var symbols []string
+			for _, token := range cached.Tokens {
+				symbols = append(symbols, token.TokenSymbol)
+			}
+			if !slices.Equal(symbols, tt.wantSymbols) {
+				t.Errorf("tokens = %v, want %v", symbols, tt.wantSymbols)
+			}
+			if batches := rpc.CallBatches()[before:]; !slices.Equal(batches, tt.wantBatches) {
+				t.Errorf("batch sizes = %v, want %v", batches, tt.wantBatches)
+			}
+			if _, err := os.Stat(file); (err == nil) != tt.wantPersist {
+				t.Errorf("persisted = %t, want %t", err == nil, tt.wantPersist)
+			}
+		})
+	}
+}
+
+func TestRegistryCacheWalkInterval(t *testing.T) {
+	rpc, chain := newFakeRPC(t)
+
+	var (
+		registry = common.HexToAddress("0x1111111111111111111111111111111111111111").Hex()
+		tokenA   = common.HexToAddress("0x000000000000000000000000000000000000000A").Hex()
+		tokenB   = common.HexToAddress("0x000000000000000000000000000000000000000B").Hex()
+	)
+	// A was replaced by B at the same position, so the count is unchanged
+	rpc.Set(registry, entryCountGetter, nil, big.NewInt(1))
+	rpc.Set(registry, entryGetter, []any{big.NewInt(0)}, common.HexToAddress(tokenB))
+	rpc.Set(tokenB, nameGetter, nil, "Token B")
+	rpc.Set(tokenB, symbolGetter, nil, "B")
+	rpc.Set(tokenB, decimalsGetter, nil, uint8(6))
+
+	c := NewRegistryCache(RegistryCacheOpts{Logg: slog.New(slog.NewTextHandler(io.Discard, nil)), ChainDataSource: chain})
+	now := time.Now().UTC()
+	previous := &RegistryTokens{
+		Registry: registry,
+		Tokens:   []*api.TokenDetails{{TokenAddress: tokenA, TokenSymbol: "A"}},
+		Entries:  []string{tokenA},
+	}
+
+	previous.WalkedAt = now.Add(-time.Hour)
+	if refreshed, changed, err := c.refreshRegistry(context.Background(), registry, previous, now); err != nil || changed || refreshed.Tokens[0].TokenSymbol != "A" {
+		t.Errorf("refresh within the walk interval = %v, %t, %v, want A unchanged", refreshed, changed, err)
+	}
+
+	previous.WalkedAt = now.Add(-registryWalkInterval)
+	refreshed, changed, err := c.refreshRegistry(context.Background(), registry, previous, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(refreshed.Tokens) != 1 || refreshed.Tokens[0].TokenSymbol != "B" || !refreshed.WalkedAt.Equal(now) {
+		t.Errorf("refresh after the walk interval = %+v, %t, want B walked now", refreshed, changed)
+	}
+}
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+type (
+	// tokenSearchCache holds token search candidates by pool filter, empty
+	// for any pool. A search runs on every USSD keystroke and fuzzy matching
+	// needs every candidate, while the candidates and their activity change
+	// slowly. Cached candidates are shared, callers must not modify them.
+	tokenSearchCache struct {
+		mu      sync.Mutex
+		ttl     time.Duration
+		entries map[string]tokenSearchEntry
+	}
+
+	tokenSearchEntry struct {
+		candidates []*api.TokenMatch
+		expiresAt  time.Time
+	}
+)
+
+const (
+	tokenSearchCacheTTL = time.Minute
+	// maxTokenSearchCacheEntries bounds the pool filters cached
+	maxTokenSearchCacheEntries = 1024
+)
+
+func newTokenSearchCache(ttl time.Duration) *tokenSearchCache {
+	return &tokenSearchCache{
+		ttl:     ttl,
+		entries: make(map[string]tokenSearchEntry),
+	}
+}
+
+func (c *tokenSearchCache) get(pool string, now time.Time) ([]*api.TokenMatch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[pool]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.candidates, true
+}
+
+// set caches candidates, dropping expired entries once the cache is full and
+// every entry if that is not enough.
+func (c *tokenSearchCache) set(pool string, candidates []*api.TokenMatch, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[pool]; !ok && len(c.entries) >= maxTokenSearchCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxTokenSearchCacheEntries {
+			clear(c.entries)
+		}
+	}
+
+	c.entries[pool] = tokenSearchEntry{candidates: candidates, expiresAt: now.Add(c.ttl)}
+}
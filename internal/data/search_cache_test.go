@@ -0,0 +1,51 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+)
+
+func TestTokenSearchCache(t *testing.T) {
+	c := newTokenSearchCache(time.Minute)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	all, pool := "", "0x1"
+	candidates := []*api.TokenMatch{{TokenAddress: "0xA"}}
+
+	if _, ok := c.get(all, now); ok {
+		t.Fatal("empty cache hit")
+	}
+
+	c.set(all, candidates, now)
+	if got, ok := c.get(all, now.Add(59*time.Second)); !ok || len(got) != 1 {
+		t.Errorf("get() before expiry = %v, %v, want the candidates", got, ok)
+	}
+	if _, ok := c.get(pool, now); ok {
+		t.Error("filters share an entry")
+	}
+	if _, ok := c.get(all, now.Add(time.Minute)); ok {
+		t.Error("get() after expiry hit")
+	}
+}
+
+func TestTokenSearchCacheBounded(t *testing.T) {
+	c := newTokenSearchCache(time.Minute)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range maxTokenSearchCacheEntries {
+		c.set(string(rune(i)), nil, now)
+	}
+	// Expired entries make room first
+	c.set("late", nil, now.Add(time.Minute))
+	if got := len(c.entries); got != 1 {
+		t.Errorf("entries after dropping expired ones = %d, want 1", got)
+	}
+
+	for i := range maxTokenSearchCacheEntries {
+		c.set("0x"+string(rune(i)), nil, now.Add(time.Minute))
+	}
+	if got := len(c.entries); got > maxTokenSearchCacheEntries {
+		t.Errorf("entries = %d, want at most %d", got, maxTokenSearchCacheEntries)
+	}
+}
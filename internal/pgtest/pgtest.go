@@ -0,0 +1,399 @@
+// Package pgtest provides a fake Postgres server for tests.
+package pgtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grassrootseconomics/ussd-data-service/internal/data"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/knadh/goyesql/v2"
+)
+
+type (
+	// Server is a minimal Postgres server answering the queries in
+	// queries.sql with canned rows, so handlers can be tested end to end
+	// without a database. It only speaks the simple query protocol, which the
+	// data source is switched to through its DSN, so arguments arrive
+	// interpolated into the SQL.
+	Server struct {
+		t         *testing.T
+		listener  net.Listener
+		pgQueries *data.PgQueries
+		queries   []queryPattern
+
+		mu       sync.Mutex
+		results  map[string]resultSet
+		delays   map[string]time.Duration
+		executed []string
+		conns    map[net.Conn]struct{}
+	}
+
+	queryPattern struct {
+		name    string
+		pattern *regexp.Regexp
+	}
+
+	// resultSet is the columns and text encoded rows of a query, NULL is
+	// a nil value.
+	resultSet struct {
+		columns []pgproto3.FieldDescription
+		rows    [][][]byte
+	}
+)
+
+const (
+	oidBool        = 16
+	oidInt8        = 20
+	oidText        = 25
+	oidTextArray   = 1009
+	oidTimestamptz = 1184
+)
+
+var placeholderPattern = regexp.MustCompile(`\\\$\d+`)
+
+// NewServer starts a fake server answering the queries in queriesFile and
+// returns a data source connected to it.
+func NewServer(t *testing.T, queriesFile string) (*Server, *data.PgChainData) {
+	t.Helper()
+
+	f := Start(t, queriesFile)
+	pg, err := data.NewPgChainDataSource(data.PgChainDataOpts{
+		Logg:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DSN:     f.DSN(),
+		Queries: f.Queries(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pg.Close)
+
+	return f, pg
+}
+
+// Start starts a fake server answering the queries in queriesFile, for
+// tests that connect data sources themselves, e.g. to replicas.
+func Start(t *testing.T, queriesFile string) *Server {
+	t.Helper()
+
+	parsed, err := goyesql.ParseFile(queriesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgQueries := &data.PgQueries{}
+	if err := goyesql.ScanToStruct(pgQueries, parsed, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Server{
+		t:         t,
+		listener:  listener,
+		pgQueries: pgQueries,
+		results:   make(map[string]resultSet),
+		delays:    make(map[string]time.Duration),
+		conns:     make(map[net.Conn]struct{}),
+	}
+
+	v := reflect.ValueOf(pgQueries).Elem()
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Tag.Get("query")
+		// Arguments are interpolated by the client as a quoted string or a
+		// bare literal, padded with spaces
+		pattern := placeholderPattern.ReplaceAllString(regexp.QuoteMeta(v.Field(i).String()), `\s*(?:'(?:[^']|'')*'|[^\s,;)]+)\s*`)
+		f.queries = append(f.queries, queryPattern{name: name, pattern: regexp.MustCompile(`^` + pattern + `$`)})
+	}
+
+	go f.serve()
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// DSN connects to the server with the simple query protocol.
+func (f *Server) DSN() string {
+	return fmt.Sprintf("postgres://test@%s/test?sslmode=disable&default_query_exec_mode=simple_protocol", f.listener.Addr())
+}
+
+// Queries are the queries the server answers.
+func (f *Server) Queries() *data.PgQueries {
+	return f.pgQueries
+}
+
+// Set answers the named query with rows, structs (or pointers to structs)
+// whose db tagged fields are the columns. columns is a zero row of the same
+// type, so queries can be answered with no rows.
+func (f *Server) Set(name string, columns any, rows ...any) {
+	f.t.Helper()
+
+	result := resultSet{columns: encodeColumns(f.t, reflect.TypeOf(columns))}
+	for _, row := range rows {
+		result.rows = append(result.rows, encodeRow(f.t, reflect.ValueOf(row)))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[name] = result
+}
+
+// SetDelay delays the answers to the named query, e.g. to run past a timeout.
+func (f *Server) SetDelay(name string, delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delays[name] = delay
+}
+
+// Statements returns the query names and other statements executed so far.
+func (f *Server) Statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.executed...)
+}
+
+// Close stops the server and drops its connections, so the database is
+// unreachable from then on.
+func (f *Server) Close() {
+	f.listener.Close()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *Server) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.serveConn(conn)
+	}
+}
+
+func (f *Server) serveConn(conn net.Conn) {
+	f.mu.Lock()
+	f.conns[conn] = struct{}{}
+	f.mu.Unlock()
+
+	defer func() {
+		conn.Close()
+
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+	}()
+
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	for name, value := range map[string]string{
+		"server_version":              "16.0",
+		"server_encoding":             "UTF8",
+		"client_encoding":             "UTF8",
+		"standard_conforming_strings": "on",
+		"DateStyle":                   "ISO, MDY",
+		"TimeZone":                    "UTC",
+		"integer_datetimes":           "on",
+	} {
+		backend.Send(&pgproto3.ParameterStatus{Name: name, Value: value})
+	}
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	txStatus := byte('I')
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			txStatus = f.answer(backend, strings.TrimSpace(msg.String), txStatus)
+		case *pgproto3.Terminate:
+			return
+		default:
+			backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "0A000", Message: fmt.Sprintf("fake postgres: unsupported message %T", msg)})
+		}
+
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+		if err := backend.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// answer answers a single statement and returns the transaction status.
+func (f *Server) answer(backend *pgproto3.Backend, sql string, txStatus byte) byte {
+	command := strings.ToUpper(strings.Fields(sql + " ")[0])
+	switch {
+	case sql == "" || strings.HasPrefix(sql, "--"):
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		return txStatus
+	case command == "BEGIN" || command == "SET" || command == "COMMIT" || command == "ROLLBACK":
+		f.record(sql)
+		backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(command)})
+		switch command {
+		case "BEGIN":
+			return 'T'
+		case "COMMIT", "ROLLBACK":
+			return 'I'
+		}
+		return txStatus
+	}
+
+	name, result, delay, err := f.lookup(sql)
+	if err != nil {
+		backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: err.Error()})
+		return txStatus
+	}
+	f.record(name)
+	time.Sleep(delay)
+
+	backend.Send(&pgproto3.RowDescription{Fields: result.columns})
+	for _, row := range result.rows {
+		backend.Send(&pgproto3.DataRow{Values: row})
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT " + strconv.Itoa(len(result.rows)))})
+
+	return txStatus
+}
+
+func (f *Server) lookup(sql string) (string, resultSet, time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, q := range f.queries {
+		if !q.pattern.MatchString(sql) {
+			continue
+		}
+		result, ok := f.results[q.name]
+		if !ok {
+			return q.name, result, 0, fmt.Errorf("fake postgres: no result set for %s", q.name)
+		}
+		return q.name, result, f.delays[q.name], nil
+	}
+
+	return "", resultSet{}, 0, errors.New("fake postgres: unknown query " + sql)
+}
+
+func (f *Server) record(statement string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.executed = append(f.executed, statement)
+}
+
+func encodeColumns(t *testing.T, typ reflect.Type) []pgproto3.FieldDescription {
+	t.Helper()
+
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var columns []pgproto3.FieldDescription
+	for i := range typ.NumField() {
+		name := typ.Field(i).Tag.Get("db")
+		if name == "" || name == "-" {
+			continue
+		}
+		columns = append(columns, pgproto3.FieldDescription{
+			Name:         []byte(name),
+			DataTypeOID:  oid(t, typ.Field(i).Type),
+			DataTypeSize: -1,
+			TypeModifier: -1,
+		})
+	}
+	return columns
+}
+
+func oid(t *testing.T, typ reflect.Type) uint32 {
+	t.Helper()
+
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == reflect.TypeFor[time.Time]():
+		return oidTimestamptz
+	case typ.Kind() == reflect.String:
+		return oidText
+	case typ.Kind() == reflect.Bool:
+		return oidBool
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		return oidInt8
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.String:
+		return oidTextArray
+	}
+
+	t.Fatalf("fake postgres: unsupported column type %s", typ)
+	return 0
+}
+
+func encodeRow(t *testing.T, v reflect.Value) [][]byte {
+	t.Helper()
+
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	var row [][]byte
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Tag.Get("db")
+		if name == "" || name == "-" {
+			continue
+		}
+		row = append(row, encodeValue(v.Field(i)))
+	}
+	return row
+}
+
+// encodeValue text encodes a value the way Postgres sends it.
+func encodeValue(v reflect.Value) []byte {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return []byte(value.UTC().Format("2006-01-02 15:04:05.999999-07"))
+	case bool:
+		if value {
+			return []byte("t")
+		}
+		return []byte("f")
+	case []string:
+		quoted := make([]string, len(value))
+		for i, s := range value {
+			quoted[i] = strconv.Quote(s)
+		}
+		return []byte("{" + strings.Join(quoted, ",") + "}")
+	}
+
+	return []byte(fmt.Sprint(v.Interface()))
+}
@@ -0,0 +1,192 @@
+// Package rpctest provides a fake JSON-RPC node for tests.
+package rpctest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/lmittmann/w3"
+)
+
+type (
+	// Server is a JSON-RPC server answering eth_call with canned return data
+	// and other methods with canned results. Calls without an answer revert,
+	// so tests only set the calls that succeed.
+	Server struct {
+		t      *testing.T
+		server *httptest.Server
+
+		mu      sync.Mutex
+		returns map[string]hexutil.Bytes
+		results map[string]any
+		delay   time.Duration
+		batches [][]string
+	}
+
+	request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	call struct {
+		To    common.Address `json:"to"`
+		Input hexutil.Bytes  `json:"input"`
+		Data  hexutil.Bytes  `json:"data"`
+	}
+)
+
+// NewServer starts a fake RPC node, closed when the test ends.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		t:       t,
+		returns: make(map[string]hexutil.Bytes),
+		results: make(map[string]any),
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// URL is the RPC endpoint.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Set answers calls of fn on contract with args with the encoded returns.
+func (s *Server) Set(contract string, fn *w3.Func, args []any, returns ...any) {
+	s.t.Helper()
+
+	input, err := fn.EncodeArgs(args...)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	output, err := abi.Arguments(fn.Returns).Pack(returns...)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.returns[key(common.HexToAddress(contract), input)] = output
+}
+
+// SetResult answers every call of a method other than eth_call with result,
+// encoded as JSON.
+func (s *Server) SetResult(method string, result any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[method] = result
+}
+
+// SetDelay delays every response, e.g. to run past a deadline.
+func (s *Server) SetDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// CallBatches returns the number of calls in each batch received so far.
+func (s *Server) CallBatches() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batch := true
+	var requests []request
+	if err := json.Unmarshal(body, &requests); err != nil {
+		batch = false
+		requests = make([]request, 1)
+		if err := json.Unmarshal(body, &requests[0]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	responses := make([]map[string]any, len(requests))
+	keys := make([]string, len(requests))
+	for i, req := range requests {
+		responses[i] = map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		key, result, ok := s.answer(req)
+		keys[i] = key
+		if ok {
+			responses[i]["result"] = result
+		} else {
+			responses[i]["error"] = map[string]any{"code": 3, "message": "execution reverted", "data": "0x"}
+		}
+	}
+
+	s.mu.Lock()
+	s.batches = append(s.batches, keys)
+	delay := s.delay
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+	json.NewEncoder(w).Encode(responses[0])
+}
+
+func (s *Server) answer(req request) (string, any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Method != "eth_call" {
+		result, ok := s.results[req.Method]
+		return req.Method, result, ok
+	}
+	if len(req.Params) == 0 {
+		return req.Method, nil, false
+	}
+
+	var c call
+	if err := json.Unmarshal(req.Params[0], &c); err != nil {
+		return req.Method, nil, false
+	}
+	input := c.Input
+	if len(input) == 0 {
+		input = c.Data
+	}
+
+	k := key(c.To, input)
+	output, ok := s.returns[k]
+	return k, output, ok
+}
+
+func key(contract common.Address, input []byte) string {
+	return strings.ToLower(contract.Hex()) + hexutil.Encode(input)
+}
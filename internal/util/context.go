@@ -0,0 +1,34 @@
+package util
+
+import (
+	"context"
+	"log/slog"
+)
+
+type (
+	loggerCtxKey    struct{}
+	requestIDCtxKey struct{}
+)
+
+// ContextWithLogger attaches a request scoped logger to ctx.
+func ContextWithLogger(ctx context.Context, logg *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, logg)
+}
+
+// LoggerFromContext returns the request scoped logger, or fallback when ctx
+// carries none (e.g. background workers).
+func LoggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logg, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return logg
+	}
+	return fallback
+}
+
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return requestID
+}
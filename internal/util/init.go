@@ -1,6 +1,8 @@
 package util
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
@@ -12,6 +14,32 @@ import (
 	"github.com/knadh/koanf/v2"
 )
 
+var (
+	// logLevel backs every logger returned by InitLogger so the level can be
+	// changed at runtime with SetLogLevel.
+	logLevel = new(slog.LevelVar)
+	// defaultLogLevel is the level InitLogger picked from the environment,
+	// restored by ResetLogLevel.
+	defaultLogLevel = slog.LevelInfo
+)
+
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
 func InitLogger() *slog.Logger {
 	loggOpts := logg.LoggOpts{
 		FormatType: logg.Logfmt,
@@ -27,27 +55,66 @@ func InitLogger() *slog.Logger {
 		loggOpts.FormatType = logg.Human
 	}
 
-	return logg.NewLogg(loggOpts)
+	defaultLogLevel = loggOpts.LogLevel
+	logLevel.Set(loggOpts.LogLevel)
+	// The wrapped handler lets everything through and levelHandler filters
+	// on logLevel instead. LevelDebug itself is kept as is because it also
+	// enables source locations.
+	if loggOpts.LogLevel != slog.LevelDebug {
+		loggOpts.LogLevel = slog.LevelDebug - 1
+	}
+
+	return slog.New(&levelHandler{
+		Handler: logg.NewLogg(loggOpts).Handler(),
+		level:   logLevel,
+	})
+}
+
+// SetLogLevel changes the level of every logger returned by InitLogger.
+// It accepts debug, info, warn or error.
+func SetLogLevel(level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	logLevel.Set(l)
+
+	return nil
+}
+
+// ResetLogLevel restores the level InitLogger picked from the environment.
+func ResetLogLevel() {
+	logLevel.Set(defaultLogLevel)
 }
 
 func InitConfig(lo *slog.Logger, confFilePath string) *koanf.Koanf {
+	ko, err := LoadConfig(confFilePath)
+	if err != nil {
+		lo.Error("could not load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	return ko
+}
+
+// LoadConfig parses the config file and applies DATA_ prefixed env var
+// overrides.
+func LoadConfig(confFilePath string) (*koanf.Koanf, error) {
 	var (
 		ko = koanf.New(".")
 	)
 
 	confFile := file.Provider(confFilePath)
 	if err := ko.Load(confFile, toml.Parser()); err != nil {
-		lo.Error("could not parse configuration file", "error", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("could not parse configuration file: %w", err)
 	}
 
 	if err := ko.Load(env.Provider("DATA_", ".", func(s string) string {
 		return strings.ReplaceAll(strings.ToLower(
 			strings.TrimPrefix(s, "DATA_")), "__", ".")
 	}), nil); err != nil {
-		lo.Error("could not override config from env vars", "error", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("could not override config from env vars: %w", err)
 	}
 
-	return ko
+	return ko, nil
 }
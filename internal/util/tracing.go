@@ -0,0 +1,95 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	serviceName = "ussd-data-service"
+
+	TracingExporterOTLP = "otlp"
+	TracingExporterFile = "file"
+)
+
+type TracingOpts struct {
+	Build        string
+	Exporter     string
+	OTLPEndpoint string
+	OTLPInsecure bool
+	File         string
+	SampleRatio  float64
+}
+
+// InitTracer installs the global tracer provider and W3C trace context
+// propagator. The returned func flushes and stops the exporter. A SampleRatio
+// of 0 never samples new traces and 1 samples every trace.
+func InitTracer(o TracingOpts) (func(context.Context) error, error) {
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing sample ratio %v is not between 0 and 1", o.SampleRatio)
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		file     *os.File
+		err      error
+	)
+
+	switch o.Exporter {
+	case TracingExporterOTLP:
+		exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(o.OTLPEndpoint)}
+		if o.OTLPInsecure {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), exporterOpts...)
+	case TracingExporterFile:
+		file, err = os.OpenFile(o.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", o.Exporter)
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(o.Build),
+		)),
+	)
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func(ctx context.Context) error {
+		err := tracerProvider.Shutdown(ctx)
+		if file != nil {
+			// The provider has flushed every span to the file by now
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
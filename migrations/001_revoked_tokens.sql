@@ -0,0 +1,18 @@
+-- Token denylist read by the revoked-tokens query when denylist.postgres is
+-- enabled. The indexer does not manage this schema, apply it to the
+-- federation database before enabling the Postgres denylist.
+CREATE SCHEMA IF NOT EXISTS ussd_data;
+
+CREATE TABLE IF NOT EXISTS ussd_data.revoked_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    -- Revokes a single token by its jti claim
+    jti TEXT,
+    -- Revokes every token issued to a subject
+    subject TEXT,
+    -- Entries stop applying once the revoked tokens have expired anyway
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (jti IS NOT NULL OR subject IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON ussd_data.revoked_tokens (expires_at);
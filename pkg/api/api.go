@@ -2,16 +2,115 @@ package api
 
 import "time"
 
+// Error codes returned in ErrResponse.Code. They are stable and safe for
+// clients to branch on, unlike Description.
+const (
+	ErrCodeBadInput               = "BAD_INPUT"
+	ErrCodeNotFound               = "NOT_FOUND"
+	ErrCodeMethodNotAllowed       = "METHOD_NOT_ALLOWED"
+	ErrCodeAuthRequired           = "AUTH_REQUIRED"
+	ErrCodeInvalidToken           = "INVALID_TOKEN"
+	ErrCodeTokenRevoked           = "TOKEN_REVOKED"
+	ErrCodeUpstreamRPCUnavailable = "UPSTREAM_RPC_UNAVAILABLE"
+	ErrCodeUpstreamRPCReverted    = "UPSTREAM_RPC_REVERTED"
+	ErrCodeDatabaseUnavailable    = "DATABASE_UNAVAILABLE"
+	ErrCodeDatabaseError          = "DATABASE_ERROR"
+	ErrCodeTimeout                = "TIMEOUT"
+	ErrCodeCancelled              = "CANCELLED"
+	ErrCodeInternal               = "INTERNAL"
+)
+
+// Sort orders accepted by the holdings and pool swap from lists.
+const (
+	// HoldingsSortStable lists stables first, then by most recent interaction
+	HoldingsSortStable = "stable"
+	// HoldingsSortRecent lists the most recently used tokens first
+	HoldingsSortRecent = "recent"
+	// HoldingsSortBalance lists the largest balances first, compared in whole tokens
+	HoldingsSortBalance = "balance"
+	// HoldingsSortSymbol lists tokens alphabetically by symbol
+	HoldingsSortSymbol = "symbol"
+)
+
+// Statement formats and row kinds. Opening and closing rows carry a token's
+// balance at the start and end of the range, the other kinds are ledger
+// entries with the running balance after them.
+const (
+	StatementFormatCSV    = "csv"
+	StatementFormatNDJSON = "ndjson"
+
+	StatementKindOpening  = "opening"
+	StatementKindTransfer = "transfer"
+	StatementKindMint     = "mint"
+	StatementKindSwap     = "swap"
+	StatementKindClosing  = "closing"
+
+	StatementDirectionIn  = "in"
+	StatementDirectionOut = "out"
+)
+
+// Sources of a pool token limit.
+const (
+	PoolLimitSourceRouter  = "pool_router"
+	PoolLimitSourceLimiter = "limiter"
+	PoolLimitSourceNone    = "none"
+)
+
+// Pool analytics ranking metrics.
+const (
+	PoolMetricSwaps  = "swaps"
+	PoolMetricUsers  = "users"
+	PoolMetricVolume = "volume"
+)
+
+// Token search match kinds, from best to worst.
+const (
+	TokenMatchExact    = "exact"
+	TokenMatchPrefix   = "prefix"
+	TokenMatchContains = "contains"
+	TokenMatchFuzzy    = "fuzzy"
+)
+
 type (
 	OKResponse struct {
 		Ok          bool           `json:"ok"`
 		Description string         `json:"description"`
 		Result      map[string]any `json:"result"`
+		Freshness   *Freshness     `json:"freshness,omitempty"`
+		// Partial is set when a dependency missed the deadline and Result only
+		// holds what could be fetched in time (e.g. holdings without balances).
+		Partial bool `json:"partial,omitempty"`
+	}
+
+	// Freshness describes how far the chain indexer behind DB backed results is
+	// lagging the RPC head.
+	Freshness struct {
+		IndexedBlock uint64    `json:"indexedBlock"`
+		HeadBlock    uint64    `json:"headBlock"`
+		LagBlocks    uint64    `json:"lagBlocks"`
+		Stale        bool      `json:"stale"`
+		CheckedAt    time.Time `json:"checkedAt"`
 	}
 
 	ErrResponse struct {
 		Ok          bool   `json:"ok"`
+		Code        string `json:"code,omitempty"`
 		Description string `json:"description"`
+		RequestID   string `json:"requestId,omitempty"`
+	}
+
+	HealthResponse struct {
+		Ok     bool           `json:"ok"`
+		Status string         `json:"status"`
+		Checks []*HealthCheck `json:"checks,omitempty"`
+	}
+
+	HealthCheck struct {
+		Name      string  `json:"name"`
+		Ok        bool    `json:"ok"`
+		LatencyMs float64 `json:"latencyMs"`
+		Detail    string  `json:"detail,omitempty"`
+		Error     string  `json:"error,omitempty"`
 	}
 
 	Last10TxResponse struct {
@@ -31,6 +130,12 @@ type (
 		TokenSymbol   string `json:"tokenSymbol" db:"token_symbol"`
 		TokenDecimals string `json:"tokenDecimals" db:"token_decimals"`
 		Balance       string `json:"balance"`
+		// Empty marks a token the account interacted with but no longer holds,
+		// only listed when empty tokens are requested
+		Empty bool `json:"empty,omitempty"`
+		// LastInteraction and StableRank are only used for sorting
+		LastInteraction time.Time `json:"-" db:"last_interaction"`
+		StableRank      int       `json:"-" db:"stable_rank"`
 	}
 
 	TokenDetails struct {
@@ -63,4 +168,165 @@ type (
 		InTokenLimit  string `json:"inTokenLimit" db:"in_token_limit"`
 		OutTokenLimit string `json:"outTokenLimit" db:"out_token_limit"`
 	}
+
+	TokenMatch struct {
+		TokenAddress  string `json:"tokenAddress" db:"token_address"`
+		TokenSymbol   string `json:"tokenSymbol" db:"token_symbol"`
+		TokenName     string `json:"tokenName" db:"token_name"`
+		TokenDecimals uint8  `json:"tokenDecimals" db:"token_decimals"`
+		// Activity is the token's transfer count among the last 10k transfers
+		Activity int64 `json:"activity" db:"activity"`
+		// Match is one of the TokenMatch constants
+		Match string `json:"match"`
+	}
+
+	PoolMatch struct {
+		PoolAddress     string `json:"poolAddress" db:"contract_address"`
+		PoolName        string `json:"poolName" db:"pool_name"`
+		PoolSymbol      string `json:"poolSymbol" db:"pool_symbol"`
+		LimiterAddress  string `json:"limiterAddress" db:"token_limiter_address"`
+		VoucherRegistry string `json:"voucherRegistry" db:"token_registry_address"`
+		// Activity is the pool's swap count among the last 1k swaps
+		Activity int64 `json:"activity" db:"activity"`
+		// Match is one of the TokenMatch constants
+		Match string `json:"match"`
+	}
+
+	StatementEntry struct {
+		Date          time.Time `json:"date" db:"date_block"`
+		TxHash        string    `json:"txHash,omitempty" db:"tx_hash"`
+		Kind          string    `json:"kind" db:"kind"`
+		Direction     string    `json:"direction,omitempty" db:"direction"`
+		TokenAddress  string    `json:"tokenAddress" db:"token_address"`
+		TokenSymbol   string    `json:"tokenSymbol" db:"token_symbol"`
+		TokenDecimals string    `json:"tokenDecimals" db:"token_decimals"`
+		Counterparty  string    `json:"counterparty,omitempty" db:"counterparty"`
+		Amount        string    `json:"amount,omitempty" db:"amount"`
+		// Balance is the token's balance after the row
+		Balance string `json:"balance" db:"balance"`
+	}
+
+	TokenValuationRate struct {
+		TokenAddress  string `json:"tokenAddress" db:"token_address"`
+		PoolAddress   string `json:"poolAddress" db:"pool_address"`
+		TokenRate     uint64 `json:"tokenRate" db:"token_rate"`
+		ReferenceRate uint64 `json:"referenceRate" db:"reference_rate"`
+	}
+
+	PortfolioValuation struct {
+		ReferenceTokenAddress  string `json:"referenceTokenAddress"`
+		ReferenceTokenSymbol   string `json:"referenceTokenSymbol"`
+		ReferenceTokenDecimals uint8  `json:"referenceTokenDecimals"`
+		// TotalValue sums the priced holdings in the reference token's smallest unit
+		TotalValue string          `json:"totalValue"`
+		Holdings   []*HoldingValue `json:"holdings"`
+	}
+
+	HoldingValue struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenDecimals string `json:"tokenDecimals"`
+		Balance       string `json:"balance"`
+		// Priced is false when no pool rates the token against the reference
+		// token or its balance could not be fetched, Value is then left out
+		Priced bool   `json:"priced"`
+		Value  string `json:"value,omitempty"`
+		// PoolAddress is the pool whose exchange rates were used, left out for
+		// the reference token itself
+		PoolAddress string `json:"poolAddress,omitempty"`
+	}
+
+	PoolState struct {
+		PoolAddress    string            `json:"poolAddress"`
+		PoolSymbol     string            `json:"poolSymbol"`
+		LimiterAddress string            `json:"limiterAddress"`
+		Tokens         []*PoolTokenState `json:"tokens"`
+	}
+
+	PoolTokenState struct {
+		TokenAddress  string `json:"tokenAddress" db:"contract_address"`
+		TokenSymbol   string `json:"tokenSymbol" db:"token_symbol"`
+		TokenDecimals string `json:"tokenDecimals" db:"token_decimals"`
+		// ExchangeRate is the pool's rate for the token, 0 when not set
+		ExchangeRate uint64 `json:"exchangeRate" db:"exchange_rate"`
+		// Balance is the pool's on-chain balance
+		Balance string `json:"balance"`
+		// Limit is the most the pool accepts, from the pool router or read
+		// from the limiter contract when the pool router has none
+		Limit       string `json:"limit" db:"token_limit"`
+		LimitSource string `json:"limitSource"`
+		// RemainingCapacity is Limit minus Balance, 0 once the balance reaches
+		// the limit
+		RemainingCapacity string `json:"remainingCapacity"`
+	}
+
+	// PoolActivityRow is a pool's swaps of one input token on one UTC day.
+	PoolActivityRow struct {
+		PoolAddress   string    `json:"poolAddress" db:"pool_address"`
+		PoolName      string    `json:"poolName" db:"pool_name"`
+		PoolSymbol    string    `json:"poolSymbol" db:"pool_symbol"`
+		Day           time.Time `json:"day" db:"day"`
+		TokenAddress  string    `json:"tokenAddress" db:"token_address"`
+		TokenSymbol   string    `json:"tokenSymbol" db:"token_symbol"`
+		TokenDecimals uint8     `json:"tokenDecimals" db:"token_decimals"`
+		SwapCount     int64     `json:"swapCount" db:"swap_count"`
+		Volume        string    `json:"volume" db:"volume"`
+	}
+
+	// PoolActivityUsers counts a pool's unique swap initiators on one UTC day,
+	// or over the whole window when Day is nil.
+	PoolActivityUsers struct {
+		PoolAddress string     `json:"poolAddress" db:"pool_address"`
+		Day         *time.Time `json:"day" db:"day"`
+		UniqueUsers int64      `json:"uniqueUsers" db:"unique_users"`
+	}
+
+	PoolAnalytics struct {
+		// Metric is one of the PoolMetric constants pools are ranked by
+		Metric string    `json:"metric"`
+		From   time.Time `json:"from"`
+		To     time.Time `json:"to"`
+		// ReferenceTokenAddress is set when volumes are valued in a reference token
+		ReferenceTokenAddress  string          `json:"referenceTokenAddress,omitempty"`
+		ReferenceTokenSymbol   string          `json:"referenceTokenSymbol,omitempty"`
+		ReferenceTokenDecimals uint8           `json:"referenceTokenDecimals,omitempty"`
+		Pools                  []*PoolActivity `json:"pools"`
+	}
+
+	PoolActivity struct {
+		PoolAddress string `json:"poolAddress"`
+		PoolName    string `json:"poolName"`
+		PoolSymbol  string `json:"poolSymbol"`
+		SwapCount   int64  `json:"swapCount"`
+		UniqueUsers int64  `json:"uniqueUsers"`
+		// Volume sums the priced token volumes in the reference token, left
+		// out without a reference token
+		Volume string             `json:"volume,omitempty"`
+		Tokens []*PoolTokenVolume `json:"tokens"`
+		Days   []*PoolActivityDay `json:"days"`
+	}
+
+	// PoolTokenVolume is a pool's swap input volume of one token.
+	PoolTokenVolume struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		SwapCount     int64  `json:"swapCount"`
+		// Volume is in the token's smallest unit
+		Volume string `json:"volume"`
+		// Priced is false without a reference token or when no pool rates the
+		// token against it, Value is then left out
+		Priced bool   `json:"priced"`
+		Value  string `json:"value,omitempty"`
+	}
+
+	// PoolActivityDay is a daily bucket, days without swaps are included.
+	PoolActivityDay struct {
+		Date        time.Time `json:"date"`
+		SwapCount   int64     `json:"swapCount"`
+		UniqueUsers int64     `json:"uniqueUsers"`
+		// Volume sums the priced token volumes of the day in the reference
+		// token, left out without a reference token
+		Volume string `json:"volume,omitempty"`
+	}
 )
@@ -0,0 +1,451 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultClientTimeout      = 15 * time.Second
+	defaultClientMaxRetries   = 2
+	defaultClientRetryBackoff = 200 * time.Millisecond
+	clientPathPrefix          = "/api/v1"
+)
+
+type (
+	// TokenProvider returns the bearer token for a request. It is called on
+	// every attempt so implementations can refresh expiring tokens.
+	TokenProvider interface {
+		Token(ctx context.Context) (string, error)
+	}
+
+	// TokenProviderFunc adapts a function to a TokenProvider.
+	TokenProviderFunc func(ctx context.Context) (string, error)
+
+	// StaticToken is a TokenProvider that always returns the same token.
+	StaticToken string
+
+	ClientOpts struct {
+		// BaseURL is the service root, e.g. http://localhost:5003
+		BaseURL       string
+		TokenProvider TokenProvider
+		// HTTPClient defaults to a client with a 15s timeout. Statement uses a
+		// copy without the timeout, exports are bounded by their ctx instead.
+		HTTPClient *http.Client
+		// MaxRetries is the number of retries after the first attempt, -1 disables retries
+		MaxRetries int
+		// RetryBackoff is doubled after every retry
+		RetryBackoff time.Duration
+	}
+
+	// Client is a typed client for the /api/v1 routes. Every method is a GET
+	// and is retried on transport errors and 502, 503 and 504 responses.
+	Client struct {
+		baseURL       string
+		tokenProvider TokenProvider
+		httpClient    *http.Client
+		// streamClient is httpClient without its overall timeout, which
+		// would also cut off reading a long streamed body
+		streamClient *http.Client
+		maxRetries   int
+		retryBackoff time.Duration
+	}
+
+	// HoldingsOpts are the list options of TokenHoldings and PoolSwapFrom.
+	HoldingsOpts struct {
+		// IncludeEmpty also lists tokens the account no longer holds, flagged as empty
+		IncludeEmpty bool
+		// Sort is one of the HoldingsSort constants, empty keeps the default order
+		Sort string
+	}
+
+	// TokenSearchOpts optionally restrict and limit TokenSearch results.
+	TokenSearchOpts struct {
+		// Pool keeps tokens allowed in the pool
+		Pool string
+		// Registry keeps the token registry's entries, the registry must be
+		// cached by the service
+		Registry string
+		// Limit defaults to 10 on the server
+		Limit int
+	}
+
+	// PoolSearchOpts optionally filter and limit PoolSearch results.
+	PoolSearchOpts struct {
+		// Limit defaults to 10 on the server
+		Limit int
+	}
+
+	// PoolAnalyticsOpts selects the window, ranking and pools of
+	// PoolAnalytics. Zero values keep the server defaults.
+	PoolAnalyticsOpts struct {
+		// Days defaults to 7 on the server
+		Days int
+		// Metric is one of the PoolMetric constants, the volume metric
+		// requires Reference
+		Metric string
+		// Reference values volumes in this token
+		Reference string
+		// Pool only reports this pool
+		Pool string
+		// Limit defaults to 5 on the server
+		Limit int
+	}
+
+	// StatementOpts selects the range and format of Statement.
+	StatementOpts struct {
+		From time.Time
+		// To defaults to now on the server
+		To time.Time
+		// Format is one of the StatementFormat constants, empty is CSV
+		Format string
+	}
+
+	// Error is returned for non 2xx responses.
+	Error struct {
+		StatusCode  int
+		Code        string
+		Description string
+		RequestID   string
+	}
+)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("ussd-data-service: %d %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("ussd-data-service: %d: %s", e.StatusCode, e.Description)
+}
+
+func NewClient(o ClientOpts) *Client {
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: defaultClientTimeout}
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = defaultClientMaxRetries
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = defaultClientRetryBackoff
+	}
+
+	streamClient := *o.HTTPClient
+	streamClient.Timeout = 0
+
+	return &Client{
+		baseURL:       strings.TrimSuffix(o.BaseURL, "/"),
+		tokenProvider: o.TokenProvider,
+		httpClient:    o.HTTPClient,
+		streamClient:  &streamClient,
+		maxRetries:    o.MaxRetries,
+		retryBackoff:  o.RetryBackoff,
+	}
+}
+
+func (c *Client) Last10Tx(ctx context.Context, address string) (*Response[TransfersResult], error) {
+	return get[TransfersResult](ctx, c, nil, "transfers", "last10", address)
+}
+
+func (c *Client) TokenHoldings(ctx context.Context, address string, opts HoldingsOpts) (*Response[HoldingsResult], error) {
+	return get[HoldingsResult](ctx, c, opts.query(), "holdings", address)
+}
+
+// PortfolioValue values the account's holdings in referenceToken.
+func (c *Client) PortfolioValue(ctx context.Context, address, referenceToken string) (*Response[PortfolioValueResult], error) {
+	return get[PortfolioValueResult](ctx, c, nil, "holdings", address, "value", referenceToken)
+}
+
+// Statement downloads the account's statement. The returned body is streamed
+// and must be closed, only the request itself is retried. The HTTP client's
+// timeout doesn't apply, a long export runs until ctx is done, so bound it
+// with a ctx deadline (the server gives up after 2 minutes).
+func (c *Client) Statement(ctx context.Context, address string, opts StatementOpts) (io.ReadCloser, error) {
+	reqURL := c.baseURL + clientPathPrefix + "/statement/" + url.PathEscape(address) + "?" + opts.query().Encode()
+
+	var resp *http.Response
+	err := c.retry(ctx, func() error {
+		var err error
+		resp, err = c.send(ctx, c.streamClient, reqURL, "*/*")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// TokenSearch searches tokens by symbol or name, best matches first.
+func (c *Client) TokenSearch(ctx context.Context, query string, opts TokenSearchOpts) (*Response[TokenSearchResult], error) {
+	return get[TokenSearchResult](ctx, c, opts.query(query), "token", "search")
+}
+
+func (c *Client) TokenDetails(ctx context.Context, tokenAddress string) (*Response[TokenDetailsResult], error) {
+	return get[TokenDetailsResult](ctx, c, nil, "token", tokenAddress)
+}
+
+// RegistryTokens lists the tokens of a registry cached by the service.
+func (c *Client) RegistryTokens(ctx context.Context, registryAddress string) (*Response[RegistryTokensResult], error) {
+	return get[RegistryTokensResult](ctx, c, nil, "registry", registryAddress, "tokens")
+}
+
+func (c *Client) PoolDetails(ctx context.Context, poolAddress string) (*Response[PoolDetailsResult], error) {
+	return get[PoolDetailsResult](ctx, c, nil, "pool", poolAddress)
+}
+
+// PoolState returns the pool's balance, limit, exchange rate and remaining
+// capacity for every allowed token.
+func (c *Client) PoolState(ctx context.Context, poolAddress string) (*Response[PoolStateResult], error) {
+	return get[PoolStateResult](ctx, c, nil, "pool", poolAddress, "state")
+}
+
+// PoolAnalytics ranks pools by their recent swap activity with daily buckets.
+func (c *Client) PoolAnalytics(ctx context.Context, opts PoolAnalyticsOpts) (*Response[PoolAnalyticsResult], error) {
+	return get[PoolAnalyticsResult](ctx, c, opts.query(), "pool", "analytics")
+}
+
+// PoolSearch searches pools by symbol or name, best matches first.
+func (c *Client) PoolSearch(ctx context.Context, query string, opts PoolSearchOpts) (*Response[PoolSearchResult], error) {
+	return get[PoolSearchResult](ctx, c, opts.query(query), "pool", "search")
+}
+
+func (c *Client) PoolReverseDetails(ctx context.Context, poolSymbol string) (*Response[PoolDetailsResult], error) {
+	return get[PoolDetailsResult](ctx, c, nil, "pool", "reverse", poolSymbol)
+}
+
+func (c *Client) TopPools(ctx context.Context) (*Response[TopPoolsResult], error) {
+	return get[TopPoolsResult](ctx, c, nil, "pool", "top")
+}
+
+func (c *Client) PoolSwapFrom(ctx context.Context, poolAddress, userAddress string, opts HoldingsOpts) (*Response[SwapListResult], error) {
+	return get[SwapListResult](ctx, c, opts.query(), "pool", poolAddress, "from", userAddress)
+}
+
+func (c *Client) PoolSwapFromCheck(ctx context.Context, poolAddress, tokenAddress string) (*Response[SwapFromCheckResult], error) {
+	return get[SwapFromCheckResult](ctx, c, nil, "pool", poolAddress, "check", tokenAddress)
+}
+
+// PoolSwapTo lists the tokens the pool can swap into, only stables (without
+// balances) when stablesOnly is set.
+func (c *Client) PoolSwapTo(ctx context.Context, poolAddress string, stablesOnly bool) (*Response[SwapListResult], error) {
+	var query url.Values
+	if stablesOnly {
+		query = url.Values{"stables": {"true"}}
+	}
+	return get[SwapListResult](ctx, c, query, "pool", poolAddress, "to", "")
+}
+
+func (c *Client) ResolveAlias(ctx context.Context, alias string) (*Response[AliasResult], error) {
+	return get[AliasResult](ctx, c, nil, "alias", alias)
+}
+
+func (c *Client) CreditSend(ctx context.Context, poolAddress, fromToken, toToken, userAddress string) (*Response[CreditSendResult], error) {
+	return get[CreditSendResult](ctx, c, nil, "credit-send", poolAddress, fromToken, toToken, userAddress)
+}
+
+func (c *Client) ReverseQuote(ctx context.Context, poolAddress, fromToken, toToken, outputAmount string) (*Response[ReverseQuoteResult], error) {
+	return get[ReverseQuoteResult](ctx, c, nil, "pool", "reverse-quote", poolAddress, fromToken, toToken, outputAmount)
+}
+
+func (c *Client) PoolMaxLimit(ctx context.Context, poolAddress, fromToken, toToken, userAddress string) (*Response[MaxLimitResult], error) {
+	return get[MaxLimitResult](ctx, c, nil, "pool", poolAddress, "limit", fromToken, toToken, userAddress)
+}
+
+func (c *Client) AbsoluteCredit(ctx context.Context, poolAddress, tokenAddress, userAddress string) (*Response[AbsoluteCreditResult], error) {
+	return get[AbsoluteCreditResult](ctx, c, nil, "absolute-credit", poolAddress, tokenAddress, userAddress)
+}
+
+func (o HoldingsOpts) query() url.Values {
+	query := url.Values{}
+	if o.IncludeEmpty {
+		query.Set("include_empty", "true")
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	return query
+}
+
+func (o TokenSearchOpts) query(search string) url.Values {
+	query := url.Values{"q": {search}}
+	if o.Pool != "" {
+		query.Set("pool", o.Pool)
+	}
+	if o.Registry != "" {
+		query.Set("registry", o.Registry)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+func (o PoolSearchOpts) query(search string) url.Values {
+	query := url.Values{"q": {search}}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+func (o PoolAnalyticsOpts) query() url.Values {
+	query := url.Values{}
+	if o.Days > 0 {
+		query.Set("days", strconv.Itoa(o.Days))
+	}
+	if o.Metric != "" {
+		query.Set("metric", o.Metric)
+	}
+	if o.Reference != "" {
+		query.Set("reference", o.Reference)
+	}
+	if o.Pool != "" {
+		query.Set("pool", o.Pool)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+func (o StatementOpts) query() url.Values {
+	query := url.Values{"from": {o.From.UTC().Format(time.RFC3339)}}
+	if !o.To.IsZero() {
+		query.Set("to", o.To.UTC().Format(time.RFC3339))
+	}
+	if o.Format != "" {
+		query.Set("format", o.Format)
+	}
+	return query
+}
+
+func get[T any](ctx context.Context, c *Client, query url.Values, segments ...string) (*Response[T], error) {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	reqURL := c.baseURL + clientPathPrefix + "/" + strings.Join(escaped, "/")
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	var resp Response[T]
+	if err := c.do(ctx, reqURL, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) do(ctx context.Context, reqURL string, v any) error {
+	return c.retry(ctx, func() error {
+		resp, err := c.send(ctx, c.httpClient, reqURL, "application/json")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, v)
+	})
+}
+
+// retry calls fn until it succeeds, fails with a final error or runs out of
+// retries.
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	backoff := c.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes a single GET and returns the response of a 2xx status, any other
+// status is returned as *Error with the body closed.
+func (c *Client) send(ctx context.Context, httpClient *http.Client, reqURL, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	if c.tokenProvider != nil {
+		token, err := c.tokenProvider.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+
+		apiErr := &Error{StatusCode: resp.StatusCode, Description: http.StatusText(resp.StatusCode)}
+		var errResp ErrResponse
+		if body, err := io.ReadAll(resp.Body); err == nil && json.Unmarshal(body, &errResp) == nil && errResp.Description != "" {
+			apiErr.Code = errResp.Code
+			apiErr.Description = errResp.Description
+			apiErr.RequestID = errResp.RequestID
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// retryable reports transport errors and gateway style responses. Context
+// errors are final since the caller gave up, and so is a reverted RPC call
+// since it reverts again.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway:
+			return apiErr.Code != ErrCodeUpstreamRPCReverted
+		case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
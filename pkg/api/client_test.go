@@ -0,0 +1,463 @@
+package api_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grassrootseconomics/ethutils"
+	internalapi "github.com/grassrootseconomics/ussd-data-service/internal/api"
+	"github.com/grassrootseconomics/ussd-data-service/internal/pgtest"
+	"github.com/grassrootseconomics/ussd-data-service/pkg/api"
+	"github.com/kamikazechaser/common/httputil"
+)
+
+// newTestServer serves the real router built from o in-process. Without data
+// sources only routes that fail validation or don't touch them can succeed.
+func newTestServer(t *testing.T, o internalapi.APIOpts, wrap func(http.Handler) http.Handler) (*httptest.Server, string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o.VerifyingKey = pub
+	o.Logg = slog.New(slog.NewTextHandler(io.Discard, nil))
+	apiServer, err := internalapi.New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := apiServer.Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, internalapi.JWTCustomClaims{Service: true}).SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, token
+}
+
+// failFirst answers the first n requests with status, and the error code
+// when set, before passing through.
+func failFirst(n int32, status int, code string, calls *atomic.Int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= n {
+				if code == "" {
+					w.WriteHeader(status)
+					return
+				}
+				httputil.JSON(w, status, api.ErrResponse{Code: code, Description: http.StatusText(status)})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	server, token := newTestServer(t, internalapi.APIOpts{}, nil)
+
+	tests := []struct {
+		name          string
+		tokenProvider api.TokenProvider
+		call          func(c *api.Client) error
+		wantStatus    int
+		wantCode      string
+	}{
+		{
+			name:          "resolve alias",
+			tokenProvider: api.StaticToken(token),
+			call: func(c *api.Client) error {
+				resp, err := c.ResolveAlias(context.Background(), "alice")
+				if err != nil {
+					return err
+				}
+				if resp.Result.Address != ethutils.ZeroAddress.Hex() {
+					t.Errorf("address = %s, want zero address", resp.Result.Address)
+				}
+				return nil
+			},
+		},
+		{
+			name:          "invalid address",
+			tokenProvider: api.StaticToken(token),
+			call: func(c *api.Client) error {
+				_, err := c.TokenHoldings(context.Background(), "0xinvalid", api.HoldingsOpts{})
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   api.ErrCodeBadInput,
+		},
+		{
+			name:          "pool analytics volume without reference",
+			tokenProvider: api.StaticToken(token),
+			call: func(c *api.Client) error {
+				_, err := c.PoolAnalytics(context.Background(), api.PoolAnalyticsOpts{Metric: api.PoolMetricVolume})
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   api.ErrCodeBadInput,
+		},
+		{
+			name:          "statement range",
+			tokenProvider: api.StaticToken(token),
+			call: func(c *api.Client) error {
+				body, err := c.Statement(context.Background(), "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439", api.StatementOpts{})
+				if err == nil {
+					body.Close()
+				}
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   api.ErrCodeBadInput,
+		},
+		{
+			name: "missing token",
+			call: func(c *api.Client) error {
+				_, err := c.TopPools(context.Background())
+				return err
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   api.ErrCodeAuthRequired,
+		},
+		{
+			name: "token provider",
+			tokenProvider: api.TokenProviderFunc(func(context.Context) (string, error) {
+				return token, nil
+			}),
+			call: func(c *api.Client) error {
+				_, err := c.ReverseQuote(context.Background(), "0xinvalid", "0xinvalid", "0xinvalid", "1")
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   api.ErrCodeBadInput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := api.NewClient(api.ClientOpts{BaseURL: server.URL, TokenProvider: tt.tokenProvider})
+			err := tt.call(c)
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var apiErr *api.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *api.Error", err)
+			}
+			if apiErr.StatusCode != tt.wantStatus || apiErr.Code != tt.wantCode {
+				t.Errorf("got %d %s, want %d %s", apiErr.StatusCode, apiErr.Code, tt.wantStatus, tt.wantCode)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("request id is empty")
+			}
+		})
+	}
+}
+
+// TestClientResults decodes the typed results of routes served from a fake
+// database.
+func TestClientResults(t *testing.T) {
+	const address = "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439"
+
+	fake, pg := pgtest.NewServer(t, "../../queries.sql")
+	server, token := newTestServer(t, internalapi.APIOpts{PgDataSource: pg}, nil)
+	c := api.NewClient(api.ClientOpts{BaseURL: server.URL, TokenProvider: api.StaticToken(token)})
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	transfer := &api.Last10TxResponse{
+		Sender: address, Recipient: address, TransferValue: "1000000", ContractAddress: address,
+		TxHash: "0x01", DateBlock: day, TokenSymbol: "SRF", TokenDecimals: "6", Success: true,
+	}
+	token6 := &api.TokenDetails{TokenAddress: address, TokenSymbol: "SRF", TokenDecimals: 6, SinkAddress: address, TokenName: "Sarafu"}
+	pool := &api.PoolDetails{PoolName: "Kibera Pool", PoolSymbol: "KBR", PoolContractAdrress: address, LimiterAddress: address, VoucherRegistry: address}
+	stable := &api.TokenHoldings{TokenAddress: address, TokenSymbol: "USDm", TokenDecimals: "6", LastInteraction: day}
+
+	fake.Set("last-10-tx", api.Last10TxResponse{}, transfer)
+	fake.Set("token-details", api.TokenDetails{}, token6)
+	fake.Set("pool-details", api.PoolDetails{}, pool)
+	fake.Set("pool-reverse-details", api.PoolDetails{}, pool)
+	fake.Set("top-active-pools", api.PoolDetails{}, pool)
+	fake.Set("pool-token-allowed", struct {
+		IsAllowed bool `db:"is_allowed"`
+	}{}, struct {
+		IsAllowed bool `db:"is_allowed"`
+	}{IsAllowed: true})
+	fake.Set("pool-allowed-stables", api.TokenHoldings{}, stable)
+	fake.Set("pool-token-swap-rates", api.TokenSwapRates{}, &api.TokenSwapRates{InRate: 10_000, OutRate: 10_000, InDecimals: 6, OutDecimals: 6, InTokenLimit: "1000", OutTokenLimit: "1000"})
+	fake.Set("token-search-candidates", api.TokenMatch{}, &api.TokenMatch{TokenAddress: address, TokenSymbol: "SRF", TokenName: "Sarafu", TokenDecimals: 6, Activity: 3})
+	fake.Set("pool-search-candidates", api.PoolMatch{}, &api.PoolMatch{PoolAddress: address, PoolName: "Kibera Pool", PoolSymbol: "KBR", LimiterAddress: address, VoucherRegistry: address, Activity: 2})
+	fake.Set("pool-activity-daily", api.PoolActivityRow{}, &api.PoolActivityRow{
+		PoolAddress: address, PoolName: "Kibera Pool", PoolSymbol: "KBR", Day: day,
+		TokenAddress: address, TokenSymbol: "SRF", TokenDecimals: 6, SwapCount: 2, Volume: "2000000",
+	})
+	fake.Set("pool-activity-users", api.PoolActivityUsers{}, &api.PoolActivityUsers{PoolAddress: address, UniqueUsers: 1})
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func() (any, error)
+		want any
+	}{
+		{
+			name: "last 10 transfers",
+			call: func() (any, error) {
+				resp, err := c.Last10Tx(ctx, address)
+				return respResult(resp, err)
+			},
+			want: api.TransfersResult{Transfers: []*api.Last10TxResponse{transfer}},
+		},
+		{
+			name: "token details",
+			call: func() (any, error) {
+				resp, err := c.TokenDetails(ctx, address)
+				if err == nil {
+					// Filled in by the handler until the graph resolver exists
+					resp.Result.TokenDetails.CommodityName = ""
+					resp.Result.TokenDetails.Location = ""
+				}
+				return respResult(resp, err)
+			},
+			want: api.TokenDetailsResult{TokenDetails: token6},
+		},
+		{
+			name: "pool details",
+			call: func() (any, error) {
+				resp, err := c.PoolDetails(ctx, address)
+				return respResult(resp, err)
+			},
+			want: api.PoolDetailsResult{PoolDetails: pool},
+		},
+		{
+			name: "pool by symbol",
+			call: func() (any, error) {
+				resp, err := c.PoolReverseDetails(ctx, "KBR")
+				return respResult(resp, err)
+			},
+			want: api.PoolDetailsResult{PoolDetails: pool},
+		},
+		{
+			name: "top pools",
+			call: func() (any, error) {
+				resp, err := c.TopPools(ctx)
+				return respResult(resp, err)
+			},
+			want: api.TopPoolsResult{TopPools: []*api.PoolDetails{pool}},
+		},
+		{
+			name: "swap from check",
+			call: func() (any, error) {
+				resp, err := c.PoolSwapFromCheck(ctx, address, address)
+				return respResult(resp, err)
+			},
+			want: api.SwapFromCheckResult{CanSwapFrom: true},
+		},
+		{
+			name: "swap to stables",
+			call: func() (any, error) {
+				resp, err := c.PoolSwapTo(ctx, address, true)
+				return respResult(resp, err)
+			},
+			// The last interaction is not part of the response
+			want: api.SwapListResult{Filtered: []*api.TokenHoldings{{TokenAddress: address, TokenSymbol: "USDm", TokenDecimals: "6"}}},
+		},
+		{
+			name: "reverse quote",
+			call: func() (any, error) {
+				resp, err := c.ReverseQuote(ctx, address, address, address, "1000")
+				return respResult(resp, err)
+			},
+			want: api.ReverseQuoteResult{InputAmount: "1000", OutputAmount: "1000"},
+		},
+		{
+			name: "token search",
+			call: func() (any, error) {
+				resp, err := c.TokenSearch(ctx, "srf", api.TokenSearchOpts{})
+				return respResult(resp, err)
+			},
+			want: api.TokenSearchResult{Tokens: []*api.TokenMatch{{
+				TokenAddress: address, TokenSymbol: "SRF", TokenName: "Sarafu", TokenDecimals: 6, Activity: 3, Match: api.TokenMatchExact,
+			}}},
+		},
+		{
+			name: "pool search",
+			call: func() (any, error) {
+				resp, err := c.PoolSearch(ctx, "kibera", api.PoolSearchOpts{Limit: 5})
+				return respResult(resp, err)
+			},
+			want: api.PoolSearchResult{Pools: []*api.PoolMatch{{
+				PoolAddress: address, PoolName: "Kibera Pool", PoolSymbol: "KBR", LimiterAddress: address, VoucherRegistry: address, Activity: 2, Match: api.TokenMatchContains,
+			}}},
+		},
+		{
+			name: "pool analytics",
+			call: func() (any, error) {
+				resp, err := c.PoolAnalytics(ctx, api.PoolAnalyticsOpts{Days: 1})
+				if err != nil {
+					return nil, err
+				}
+				pools := resp.Result.Analytics.Pools
+				if len(pools) != 1 {
+					return pools, nil
+				}
+				return []any{pools[0].PoolAddress, pools[0].SwapCount, pools[0].UniqueUsers}, nil
+			},
+			want: []any{address, int64(2), int64(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// respResult returns the result of a successful response.
+func respResult[T any](resp *api.Response[T], err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Ok {
+		return nil, errors.New("response is not ok")
+	}
+	return resp.Result, nil
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int32
+		status     int
+		code       string
+		maxRetries int
+		wantCalls  int32
+		wantErr    bool
+	}{
+		{name: "recovers from unavailable", failures: 2, status: http.StatusServiceUnavailable, wantCalls: 3},
+		{name: "gives up after max retries", failures: 5, status: http.StatusBadGateway, maxRetries: 1, wantCalls: 2, wantErr: true},
+		{name: "retries disabled", failures: 1, status: http.StatusGatewayTimeout, maxRetries: -1, wantCalls: 1, wantErr: true},
+		{name: "no retry on server error", failures: 1, status: http.StatusInternalServerError, wantCalls: 1, wantErr: true},
+		{name: "retries rpc unavailable", failures: 1, status: http.StatusBadGateway, code: api.ErrCodeUpstreamRPCUnavailable, wantCalls: 2},
+		{name: "no retry on reverted call", failures: 1, status: http.StatusBadGateway, code: api.ErrCodeUpstreamRPCReverted, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server, token := newTestServer(t, internalapi.APIOpts{}, failFirst(tt.failures, tt.status, tt.code, &calls))
+
+			c := api.NewClient(api.ClientOpts{
+				BaseURL:       server.URL,
+				TokenProvider: api.StaticToken(token),
+				MaxRetries:    tt.maxRetries,
+				RetryBackoff:  time.Millisecond,
+			})
+			_, err := c.ResolveAlias(context.Background(), "alice")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClientContextCancelled(t *testing.T) {
+	var calls atomic.Int32
+	server, token := newTestServer(t, internalapi.APIOpts{}, failFirst(10, http.StatusServiceUnavailable, "", &calls))
+
+	c := api.NewClient(api.ClientOpts{
+		BaseURL:       server.URL,
+		TokenProvider: api.StaticToken(token),
+		RetryBackoff:  time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.ResolveAlias(ctx, "alice"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want deadline exceeded", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestClientStatementOutlivesTimeout(t *testing.T) {
+	const chunks = 4
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		for i := range chunks {
+			fmt.Fprintf(w, "row %d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// The export takes twice as long as the client timeout.
+	c := api.NewClient(api.ClientOpts{
+		BaseURL:    server.URL,
+		HTTPClient: &http.Client{Timeout: 100 * time.Millisecond},
+	})
+
+	body, err := c.Statement(context.Background(), "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439", api.StatementOpts{From: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading the statement: %v", err)
+	}
+	if want := "row 0\nrow 1\nrow 2\nrow 3\n"; string(got) != want {
+		t.Errorf("statement = %q, want %q", got, want)
+	}
+
+	// The ctx still bounds it.
+	ctx, cancel := context.WithTimeout(context.Background(), 75*time.Millisecond)
+	defer cancel()
+
+	body, err = c.Statement(ctx, "0x5523058cdFfe5F3c1EaDADD5015E55C6E00fb439", api.StatementOpts{From: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if _, err := io.ReadAll(body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("reading past the ctx deadline: %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import "time"
+
+type (
+	// Response is OKResponse with a typed Result.
+	Response[T any] struct {
+		Ok          bool       `json:"ok"`
+		Description string     `json:"description"`
+		Result      T          `json:"result"`
+		Freshness   *Freshness `json:"freshness,omitempty"`
+		Partial     bool       `json:"partial,omitempty"`
+	}
+
+	TransfersResult struct {
+		Transfers []*Last10TxResponse `json:"transfers"`
+	}
+
+	HoldingsResult struct {
+		Holdings []*TokenHoldings `json:"holdings"`
+	}
+
+	TokenDetailsResult struct {
+		TokenDetails *TokenDetails `json:"tokenDetails"`
+	}
+
+	PoolDetailsResult struct {
+		PoolDetails *PoolDetails `json:"poolDetails"`
+	}
+
+	TopPoolsResult struct {
+		TopPools []*PoolDetails `json:"topPools"`
+	}
+
+	SwapListResult struct {
+		Filtered []*TokenHoldings `json:"filtered"`
+	}
+
+	SwapFromCheckResult struct {
+		CanSwapFrom bool `json:"canSwapFrom"`
+	}
+
+	MaxLimitResult struct {
+		Max            string `json:"max"`
+		RelativeCredit string `json:"relativeCredit"`
+	}
+
+	AbsoluteCreditResult struct {
+		AbsoluteCredit string `json:"absoluteCredit"`
+	}
+
+	AliasResult struct {
+		Address string `json:"address"`
+	}
+
+	CreditSendResult struct {
+		MaxSAT string `json:"maxSAT"`
+		MaxRAT string `json:"maxRAT"`
+	}
+
+	ReverseQuoteResult struct {
+		InputAmount  string `json:"inputAmount"`
+		OutputAmount string `json:"outputAmount"`
+	}
+
+	PortfolioValueResult struct {
+		Valuation *PortfolioValuation `json:"valuation"`
+	}
+
+	TokenSearchResult struct {
+		Tokens []*TokenMatch `json:"tokens"`
+	}
+
+	PoolStateResult struct {
+		PoolState *PoolState `json:"poolState"`
+	}
+
+	PoolAnalyticsResult struct {
+		Analytics *PoolAnalytics `json:"analytics"`
+	}
+
+	PoolSearchResult struct {
+		Pools []*PoolMatch `json:"pools"`
+	}
+
+	RegistryTokensResult struct {
+		Registry string          `json:"registry"`
+		Tokens   []*TokenDetails `json:"tokens"`
+		// UpdatedAt is when the service last refreshed the registry
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+)
@@ -0,0 +1,232 @@
+package api
+
+import "time"
+
+// The /api/v2 models use one naming scheme across endpoints: token and pool
+// fields are always prefixed with token and pool (tokenAddress, poolName),
+// other addresses are named for their role (registryAddress, sender).
+// Addresses are EIP-55 checksummed strings, token decimals are integers and
+// amounts are base 10 strings in the token's smallest unit.
+type (
+	// V2Response is the envelope of every successful /api/v2 response.
+	V2Response[T any] struct {
+		Ok        bool       `json:"ok"`
+		Data      T          `json:"data"`
+		Freshness *Freshness `json:"freshness,omitempty"`
+		// Partial is set when a dependency missed the deadline and Data only
+		// holds what could be fetched in time, e.g. balances are null.
+		Partial bool `json:"partial,omitempty"`
+	}
+
+	// V2ErrResponse is the envelope of every failed /api/v2 response.
+	V2ErrResponse struct {
+		Ok    bool    `json:"ok"`
+		Error V2Error `json:"error"`
+	}
+
+	V2Error struct {
+		// Status repeats the HTTP status code
+		Status int `json:"status"`
+		// Code is one of the ErrCode constants
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"requestId,omitempty"`
+	}
+
+	V2Transfer struct {
+		TxHash        string    `json:"txHash"`
+		Sender        string    `json:"sender"`
+		Recipient     string    `json:"recipient"`
+		TokenAddress  string    `json:"tokenAddress"`
+		TokenSymbol   string    `json:"tokenSymbol"`
+		TokenDecimals uint8     `json:"tokenDecimals"`
+		Value         string    `json:"value"`
+		Success       bool      `json:"success"`
+		Timestamp     time.Time `json:"timestamp"`
+	}
+
+	V2TokenBalance struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		// Balance is null when it was not fetched: stables only lists and
+		// partial responses
+		Balance *string `json:"balance"`
+		// Empty marks a token the account interacted with but no longer holds
+		Empty bool `json:"empty"`
+	}
+
+	// V2Token has no commodity or location until they are indexed, v1 only
+	// returns placeholders for them.
+	V2Token struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenName     string `json:"tokenName"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		SinkAddress   string `json:"sinkAddress"`
+	}
+
+	V2Pool struct {
+		PoolAddress     string `json:"poolAddress"`
+		PoolName        string `json:"poolName"`
+		PoolSymbol      string `json:"poolSymbol"`
+		LimiterAddress  string `json:"limiterAddress"`
+		RegistryAddress string `json:"registryAddress"`
+	}
+
+	V2SwapAllowed struct {
+		Allowed bool `json:"allowed"`
+	}
+
+	V2SwapLimit struct {
+		MaxFromAmount string `json:"maxFromAmount"`
+	}
+
+	V2CreditSend struct {
+		// MaxFromAmount is the limit in the sender's token
+		MaxFromAmount string `json:"maxFromAmount"`
+		// MaxToAmount is the same limit in the recipient's token
+		MaxToAmount string `json:"maxToAmount"`
+	}
+
+	V2Quote struct {
+		FromAmount string `json:"fromAmount"`
+		ToAmount   string `json:"toAmount"`
+	}
+
+	V2Credit struct {
+		// Credit is negative when the user's balance already exceeds the pool limit
+		Credit string `json:"credit"`
+	}
+
+	V2PortfolioValue struct {
+		ReferenceTokenAddress  string `json:"referenceTokenAddress"`
+		ReferenceTokenSymbol   string `json:"referenceTokenSymbol"`
+		ReferenceTokenDecimals uint8  `json:"referenceTokenDecimals"`
+		// TotalValue sums the priced holdings in the reference token
+		TotalValue string            `json:"totalValue"`
+		Holdings   []*V2HoldingValue `json:"holdings"`
+	}
+
+	V2HoldingValue struct {
+		TokenAddress  string  `json:"tokenAddress"`
+		TokenSymbol   string  `json:"tokenSymbol"`
+		TokenDecimals uint8   `json:"tokenDecimals"`
+		Balance       *string `json:"balance"`
+		Priced        bool    `json:"priced"`
+		// Value is null when the holding is not priced
+		Value *string `json:"value"`
+		// PoolAddress is the pool whose exchange rates were used, null for the
+		// reference token itself and unpriced holdings
+		PoolAddress *string `json:"poolAddress"`
+	}
+
+	V2TokenMatch struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenName     string `json:"tokenName"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		// Activity is the token's transfer count among the last 10k transfers
+		Activity int64 `json:"activity"`
+		// Match is one of the TokenMatch constants
+		Match string `json:"match"`
+	}
+
+	V2PoolState struct {
+		PoolAddress    string              `json:"poolAddress"`
+		PoolSymbol     string              `json:"poolSymbol"`
+		LimiterAddress string              `json:"limiterAddress"`
+		Tokens         []*V2PoolTokenState `json:"tokens"`
+	}
+
+	V2PoolTokenState struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		// ExchangeRate is 0 when the pool has no rate for the token
+		ExchangeRate uint64 `json:"exchangeRate"`
+		Balance      string `json:"balance"`
+		Limit        string `json:"limit"`
+		// LimitSource is one of the PoolLimitSource constants
+		LimitSource       string `json:"limitSource"`
+		RemainingCapacity string `json:"remainingCapacity"`
+	}
+
+	V2PoolAnalytics struct {
+		// Metric is one of the PoolMetric constants pools are ranked by
+		Metric string    `json:"metric"`
+		From   time.Time `json:"from"`
+		To     time.Time `json:"to"`
+		// ReferenceToken is null when volumes are not valued
+		ReferenceToken *V2ReferenceToken `json:"referenceToken"`
+		Pools          []*V2PoolActivity `json:"pools"`
+	}
+
+	V2ReferenceToken struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+	}
+
+	V2PoolActivity struct {
+		PoolAddress string `json:"poolAddress"`
+		PoolName    string `json:"poolName"`
+		PoolSymbol  string `json:"poolSymbol"`
+		SwapCount   int64  `json:"swapCount"`
+		UniqueUsers int64  `json:"uniqueUsers"`
+		// Volume is null without a reference token
+		Volume *string              `json:"volume"`
+		Tokens []*V2PoolTokenVolume `json:"tokens"`
+		Days   []*V2PoolActivityDay `json:"days"`
+	}
+
+	V2PoolTokenVolume struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		SwapCount     int64  `json:"swapCount"`
+		Volume        string `json:"volume"`
+		Priced        bool   `json:"priced"`
+		// Value is null when the volume is not priced
+		Value *string `json:"value"`
+	}
+
+	V2PoolActivityDay struct {
+		Date        time.Time `json:"date"`
+		SwapCount   int64     `json:"swapCount"`
+		UniqueUsers int64     `json:"uniqueUsers"`
+		// Volume is null without a reference token
+		Volume *string `json:"volume"`
+	}
+
+	V2RegistryTokens struct {
+		RegistryAddress string `json:"registryAddress"`
+		// UpdatedAt is when the service last refreshed the registry
+		UpdatedAt time.Time          `json:"updatedAt"`
+		Tokens    []*V2RegistryToken `json:"tokens"`
+	}
+
+	V2RegistryToken struct {
+		TokenAddress  string `json:"tokenAddress"`
+		TokenSymbol   string `json:"tokenSymbol"`
+		TokenName     string `json:"tokenName"`
+		TokenDecimals uint8  `json:"tokenDecimals"`
+		SinkAddress   string `json:"sinkAddress"`
+	}
+
+	V2PoolMatch struct {
+		PoolAddress     string `json:"poolAddress"`
+		PoolName        string `json:"poolName"`
+		PoolSymbol      string `json:"poolSymbol"`
+		LimiterAddress  string `json:"limiterAddress"`
+		RegistryAddress string `json:"registryAddress"`
+		// Activity is the pool's swap count among the last 1k swaps
+		Activity int64 `json:"activity"`
+		// Match is one of the TokenMatch constants
+		Match string `json:"match"`
+	}
+
+	V2Alias struct {
+		Address string `json:"address"`
+	}
+)
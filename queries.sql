@@ -65,19 +65,21 @@ latest_interactions AS (
 SELECT
     t.token_symbol,
     t.contract_address,
-    t.token_decimals
-FROM
-    latest_interactions li
-JOIN
-    chain_data.tokens t ON li.contract_address = t.contract_address
-ORDER BY
+    t.token_decimals,
+    li.last_interaction_date AS last_interaction,
     CASE
         -- cUSD, USDT, cKES
         WHEN li.contract_address = '0x765DE816845861e75A25fCA122bb6898B8B1282a' THEN 1
         WHEN li.contract_address = '0x617f3112bf5397D0467D315cC709EF968D9ba546' THEN 2
         WHEN li.contract_address = '0x456a3D042C0DbD3db53D5489e98dFb038553B0d0' THEN 3
         ELSE 4
-    END,
+    END AS stable_rank
+FROM
+    latest_interactions li
+JOIN
+    chain_data.tokens t ON li.contract_address = t.contract_address
+ORDER BY
+    stable_rank,
     li.last_interaction_date DESC;
 
 --name: token-details
@@ -152,21 +154,50 @@ SELECT EXISTS (
 ) AS is_allowed;
 
 --name: pool-allowed-tokens-for-user
--- Fetches user's token holdings that are allowed in a specific pool
+-- Fetches user's token holdings that are allowed in a specific pool, sorted like token-holdings
 -- $1: user_address
 -- $2: pool_address
-SELECT DISTINCT 
-    t.token_symbol, 
-    t.contract_address, 
-    t.token_decimals
-FROM chain_data.tokens t
+WITH user_interactions AS (
+    (
+        SELECT contract_address, tx.date_block
+        FROM chain_data.token_transfer tt
+        JOIN chain_data.tx ON tt.tx_id = tx.id
+        WHERE tt.sender_address = $1 OR tt.recipient_address = $1
+    )
+    UNION ALL
+    (
+        SELECT contract_address, tx.date_block
+        FROM chain_data.token_mint tm
+        JOIN chain_data.tx ON tm.tx_id = tx.id
+        WHERE tm.minter_address = $1 OR tm.recipient_address = $1
+    )
+),
+latest_interactions AS (
+    SELECT
+        contract_address,
+        MAX(date_block) as last_interaction_date
+    FROM user_interactions
+    GROUP BY contract_address
+)
+SELECT DISTINCT
+    t.token_symbol,
+    t.contract_address,
+    t.token_decimals,
+    li.last_interaction_date AS last_interaction,
+    CASE
+        -- cUSD, USDT, cKES
+        WHEN li.contract_address = '0x765DE816845861e75A25fCA122bb6898B8B1282a' THEN 1
+        WHEN li.contract_address = '0x617f3112bf5397D0467D315cC709EF968D9ba546' THEN 2
+        WHEN li.contract_address = '0x456a3D042C0DbD3db53D5489e98dFb038553B0d0' THEN 3
+        ELSE 4
+    END AS stable_rank
+FROM latest_interactions li
+JOIN chain_data.tokens t ON li.contract_address = t.contract_address
 INNER JOIN pool_router.pool_allowed_tokens pat ON t.contract_address = pat.token_address
-LEFT JOIN chain_data.token_transfer tt ON t.contract_address = tt.contract_address
-    AND (tt.sender_address = $1 OR tt.recipient_address = $1)
-LEFT JOIN chain_data.token_mint tm ON t.contract_address = tm.contract_address
-    AND (tm.minter_address = $1 OR tm.recipient_address = $1)
 WHERE pat.pool_address = $2
-    AND (tt.contract_address IS NOT NULL OR tm.contract_address IS NOT NULL);
+ORDER BY
+    stable_rank,
+    last_interaction DESC;
 
 --name: pool-allowed-tokens
 -- Fetches all tokens allowed in a specific pool
@@ -227,4 +258,266 @@ WHERE in_token.pool_address = $1
 SELECT
     COALESCE(token_limit, '0') as token_limit
 FROM pool_router.pool_token_limits
-WHERE pool_address = $1 AND token_address = $2;
\ No newline at end of file
+WHERE pool_address = $1 AND token_address = $2;
+--name: revoked-tokens
+-- Fetches revoked service token ids (jti) and subjects
+SELECT
+    COALESCE(jti, '') AS jti,
+    COALESCE(subject, '') AS subject
+FROM ussd_data.revoked_tokens
+WHERE expires_at IS NULL OR expires_at > NOW();
+
+--name: indexer-head
+-- Fetches the newest block indexed into chain_data.tx
+SELECT
+    COALESCE(MAX(block_number), 0) AS block_number
+FROM chain_data.tx;
+
+--name: token-valuation-rates
+-- Fetches each token's exchange rate against a reference token, taken from the
+-- most active pool (last 1k swaps) that rates both
+-- $1: token_addresses
+-- $2: reference_token_address
+WITH pool_activity AS (
+    SELECT ps.contract_address AS pool_address, COUNT(*) AS swap_count
+    FROM (
+        SELECT contract_address
+        FROM chain_data.pool_swap
+        ORDER BY id DESC
+        LIMIT 1000
+    ) ps
+    GROUP BY ps.contract_address
+)
+SELECT DISTINCT ON (token.token_address)
+    token.token_address,
+    token.pool_address,
+    token.exchange_rate AS token_rate,
+    reference.exchange_rate AS reference_rate
+FROM pool_router.pool_token_exchange_rates token
+JOIN pool_router.pool_token_exchange_rates reference
+    ON token.pool_address = reference.pool_address
+    AND reference.token_address = $2
+LEFT JOIN pool_activity pa ON token.pool_address = pa.pool_address
+WHERE token.token_address = ANY($1)
+ORDER BY
+    token.token_address,
+    COALESCE(pa.swap_count, 0) DESC,
+    token.pool_address;
+
+--name: statement-opening-balances
+-- Sums an account's successful transfers, mints and swaps before a date into
+-- per token balances, see statement-entries
+-- $1: public_key
+-- $2: before
+WITH swaps AS (
+    SELECT ps.tx_id, ps.contract_address, ps.token_in_address, ps.token_out_address, ps.in_value, ps.out_value
+    FROM chain_data.pool_swap ps
+    JOIN chain_data.tx ON ps.tx_id = tx.id
+    WHERE ps.initiator_address = $1 AND tx.success AND tx.date_block < $2
+),
+entries AS (
+    SELECT tx_id, 'transfer' AS kind, 'in' AS direction, contract_address AS token_address, sender_address AS counterparty, transfer_value::numeric AS amount
+    FROM chain_data.token_transfer WHERE recipient_address = $1
+    UNION ALL
+    SELECT tx_id, 'transfer', 'out', contract_address, recipient_address, transfer_value
+    FROM chain_data.token_transfer WHERE sender_address = $1
+    UNION ALL
+    SELECT tx_id, 'mint', 'in', contract_address, minter_address, mint_value
+    FROM chain_data.token_mint WHERE recipient_address = $1
+    UNION ALL
+    SELECT tx_id, 'swap', 'out', token_in_address, contract_address, in_value FROM swaps
+    UNION ALL
+    SELECT tx_id, 'swap', 'in', token_out_address, contract_address, out_value FROM swaps
+)
+SELECT
+    e.token_address,
+    tokens.token_symbol,
+    tokens.token_decimals,
+    SUM(CASE WHEN e.direction = 'in' THEN e.amount ELSE -e.amount END)::text AS balance
+FROM entries e
+JOIN chain_data.tx ON e.tx_id = tx.id
+JOIN chain_data.tokens ON e.token_address = tokens.contract_address
+WHERE tx.success AND tx.date_block < $2
+    AND NOT (e.kind = 'transfer' AND EXISTS (
+        SELECT 1 FROM swaps s WHERE s.tx_id = e.tx_id AND s.contract_address = e.counterparty
+    ))
+GROUP BY e.token_address, tokens.token_symbol, tokens.token_decimals
+HAVING SUM(CASE WHEN e.direction = 'in' THEN e.amount ELSE -e.amount END) <> 0
+ORDER BY tokens.token_symbol;
+
+--name: statement-entries
+-- Fetches an account's successful transfers, mints and swaps in a date range,
+-- oldest first. A swap is listed as an out row for the input token and an in
+-- row for the output token, the transfers to and from its pool are left out
+-- $1: public_key
+-- $2: from (inclusive)
+-- $3: to (exclusive)
+WITH swaps AS (
+    SELECT ps.tx_id, ps.contract_address, ps.token_in_address, ps.token_out_address, ps.in_value, ps.out_value
+    FROM chain_data.pool_swap ps
+    JOIN chain_data.tx ON ps.tx_id = tx.id
+    WHERE ps.initiator_address = $1 AND tx.success AND tx.date_block >= $2 AND tx.date_block < $3
+),
+entries AS (
+    SELECT tx_id, 'transfer' AS kind, 'in' AS direction, contract_address AS token_address, sender_address AS counterparty, transfer_value::numeric AS amount
+    FROM chain_data.token_transfer WHERE recipient_address = $1
+    UNION ALL
+    SELECT tx_id, 'transfer', 'out', contract_address, recipient_address, transfer_value
+    FROM chain_data.token_transfer WHERE sender_address = $1
+    UNION ALL
+    SELECT tx_id, 'mint', 'in', contract_address, minter_address, mint_value
+    FROM chain_data.token_mint WHERE recipient_address = $1
+    UNION ALL
+    SELECT tx_id, 'swap', 'out', token_in_address, contract_address, in_value FROM swaps
+    UNION ALL
+    SELECT tx_id, 'swap', 'in', token_out_address, contract_address, out_value FROM swaps
+)
+SELECT
+    tx.date_block,
+    tx.tx_hash,
+    e.kind,
+    e.direction,
+    e.token_address,
+    tokens.token_symbol,
+    tokens.token_decimals,
+    e.counterparty,
+    e.amount::text AS amount
+FROM entries e
+JOIN chain_data.tx ON e.tx_id = tx.id
+JOIN chain_data.tokens ON e.token_address = tokens.contract_address
+WHERE tx.success AND tx.date_block >= $2 AND tx.date_block < $3
+    AND NOT (e.kind = 'transfer' AND EXISTS (
+        SELECT 1 FROM swaps s WHERE s.tx_id = e.tx_id AND s.contract_address = e.counterparty
+    ))
+ORDER BY tx.date_block, tx.id, e.kind, e.direction DESC;
+
+--name: token-search-candidates
+-- Fetches the tokens known to the indexer or the pool router with their
+-- transfer count among the last 10k transfers. Matching is done by the caller
+-- $1: pool_address, empty for any pool
+WITH recent_transfers AS (
+    SELECT contract_address
+    FROM chain_data.token_transfer
+    ORDER BY id DESC
+    LIMIT 10000
+),
+activity AS (
+    SELECT contract_address, COUNT(*) AS transfer_count
+    FROM recent_transfers
+    GROUP BY contract_address
+),
+all_tokens AS (
+    SELECT DISTINCT ON (token_address) token_address, token_symbol, token_name, token_decimals
+    FROM (
+        SELECT contract_address AS token_address, token_symbol, token_name, token_decimals::int AS token_decimals, 1 AS source
+        FROM chain_data.tokens
+        UNION ALL
+        SELECT token_address, token_symbol, '' AS token_name, token_decimals::int, 2 AS source
+        FROM pool_router.tokens
+    ) t
+    ORDER BY token_address, source
+)
+SELECT
+    t.token_address,
+    t.token_symbol,
+    t.token_name,
+    t.token_decimals,
+    COALESCE(a.transfer_count, 0) AS activity
+FROM all_tokens t
+LEFT JOIN activity a ON t.token_address = a.contract_address
+WHERE $1 = '' OR t.token_address IN (
+    SELECT token_address FROM pool_router.pool_allowed_tokens WHERE pool_address = $1
+);
+
+--name: token-registries
+-- Fetches the token registries used by swap pools, cached by the registry cache
+SELECT DISTINCT token_registry_address
+FROM pool_router.swap_pools
+WHERE token_registry_address IS NOT NULL AND token_registry_address <> ''
+ORDER BY token_registry_address;
+
+--name: pool-token-state
+-- Fetches every token allowed in a pool with its exchange rate and limit. An
+-- empty token_limit means the pool router has no limit for the token
+-- $1: pool_address
+SELECT DISTINCT ON (t.token_symbol, t.token_address)
+    t.token_address AS contract_address,
+    t.token_symbol,
+    t.token_decimals,
+    COALESCE(r.exchange_rate, 0) AS exchange_rate,
+    COALESCE(l.token_limit, '') AS token_limit
+FROM pool_router.pool_allowed_tokens pat
+JOIN pool_router.tokens t ON pat.token_address = t.token_address
+LEFT JOIN pool_router.pool_token_exchange_rates r
+    ON pat.pool_address = r.pool_address AND pat.token_address = r.token_address
+LEFT JOIN pool_router.pool_token_limits l
+    ON pat.pool_address = l.pool_address AND pat.token_address = l.token_address
+WHERE pat.pool_address = $1
+ORDER BY t.token_symbol, t.token_address;
+
+--name: pool-activity-daily
+-- Fetches successful swaps per pool, UTC day and input token since a time,
+-- with the summed input volume in the token's smallest unit
+-- $1: since (inclusive)
+-- $2: pool_address, empty for every pool
+SELECT
+    p.pool_address,
+    p.pool_name,
+    p.pool_symbol,
+    date_trunc('day', tx.date_block AT TIME ZONE 'UTC') AS day,
+    ps.token_in_address AS token_address,
+    COALESCE(tokens.token_symbol, '') AS token_symbol,
+    COALESCE(tokens.token_decimals, 0)::int AS token_decimals,
+    COUNT(*) AS swap_count,
+    SUM(ps.in_value::numeric)::text AS volume
+FROM chain_data.pool_swap ps
+JOIN chain_data.tx ON ps.tx_id = tx.id
+JOIN pool_router.swap_pools p ON ps.contract_address = p.pool_address
+LEFT JOIN chain_data.tokens ON ps.token_in_address = tokens.contract_address
+WHERE tx.success AND tx.date_block >= $1
+    AND ($2 = '' OR ps.contract_address = $2)
+GROUP BY p.pool_address, p.pool_name, p.pool_symbol, 4, ps.token_in_address, tokens.token_symbol, tokens.token_decimals
+ORDER BY p.pool_address, 4, ps.token_in_address;
+
+--name: pool-activity-users
+-- Fetches the unique swap initiators per pool and UTC day since a time, and
+-- over the whole window in the rows with a NULL day
+-- $1: since (inclusive)
+-- $2: pool_address, empty for every pool
+SELECT
+    ps.contract_address AS pool_address,
+    date_trunc('day', tx.date_block AT TIME ZONE 'UTC') AS day,
+    COUNT(DISTINCT ps.initiator_address) AS unique_users
+FROM chain_data.pool_swap ps
+JOIN chain_data.tx ON ps.tx_id = tx.id
+JOIN pool_router.swap_pools p ON ps.contract_address = p.pool_address
+WHERE tx.success AND tx.date_block >= $1
+    AND ($2 = '' OR ps.contract_address = $2)
+GROUP BY GROUPING SETS (
+    (ps.contract_address, date_trunc('day', tx.date_block AT TIME ZONE 'UTC')),
+    (ps.contract_address)
+);
+
+--name: pool-search-candidates
+-- Fetches every pool with its swap count among the last 1k swaps. Matching is
+-- done by the caller
+WITH recent_swaps AS (
+    SELECT contract_address
+    FROM chain_data.pool_swap
+    ORDER BY id DESC
+    LIMIT 1000
+),
+activity AS (
+    SELECT contract_address, COUNT(*) AS swap_count
+    FROM recent_swaps
+    GROUP BY contract_address
+)
+SELECT
+    p.pool_address AS contract_address,
+    p.pool_name,
+    p.pool_symbol,
+    p.token_registry_address,
+    p.token_limiter_address,
+    COALESCE(a.swap_count, 0) AS activity
+FROM pool_router.swap_pools p
+LEFT JOIN activity a ON p.pool_address = a.contract_address;